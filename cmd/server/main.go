@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -10,12 +10,31 @@ import (
 	"time"
 
 	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/server"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/tracing"
 )
 
 func main() {
-	srv, err := server.NewServer()
+	cfg, err := server.ConfigFromEnv()
 	if err != nil {
-		log.Fatalf("Failed to create server: %v", err)
+		slog.Error("failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+	logger := cfg.Logger
+	slog.SetDefault(logger)
+
+	shutdownTracing, tracingEnabled, err := tracing.Setup(context.Background())
+	if err != nil {
+		logger.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	if tracingEnabled {
+		logger.Info("exporting traces over OTLP")
+	}
+
+	srv, err := server.NewServer(cfg)
+	if err != nil {
+		logger.Error("failed to create server", "error", err)
+		os.Exit(1)
 	}
 
 	// Handle graceful shutdown
@@ -23,12 +42,20 @@ func main() {
 	defer cancel()
 
 	// Start SpiceDB data printer goroutine
-	srv.GetProxy().StartSpiceDBDataPrinter(ctx)
+	printerDone, err := srv.GetProxy().StartSpiceDBDataPrinter(ctx, cfg.Printer)
+	if err != nil {
+		logger.Error("failed to start SpiceDB data printer", "error", err)
+		os.Exit(1)
+	}
+
+	// Start the orphaned relationship reconciler when configured
+	reconcilerDone := srv.GetProxy().StartReconciler(ctx, cfg.Reconciler)
 
 	// Start server in goroutine
 	go func() {
 		if err := srv.Start(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed: %v", err)
+			logger.Error("server failed", "error", err)
+			os.Exit(1)
 		}
 	}()
 
@@ -37,18 +64,23 @@ func main() {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
 
-	log.Println("Shutting down server...")
+	logger.Info("shutting down server")
 
-	// Cancel context to stop SpiceDB data printer
+	// Cancel context to stop SpiceDB data printer and reconciler, and wait for the printer to close its file
 	cancel()
+	<-printerDone
+	<-reconcilerDone
 
 	// Graceful shutdown
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
 
 	if err := srv.Stop(shutdownCtx); err != nil {
-		log.Printf("Server shutdown error: %v", err)
+		logger.Error("server shutdown failed", "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("failed to flush traces", "error", err)
 	}
 
-	log.Println("Server stopped")
+	logger.Info("server stopped")
 }
@@ -0,0 +1,48 @@
+package api
+
+import "net/http"
+
+// Error codes reported in Response.Code and BatchResult.Code, so clients can branch on
+// the kind of failure without matching the human-readable Error text
+const (
+	CodeUnauthenticated  = "unauthenticated"
+	CodePermissionDenied = "permission_denied"
+	CodeNotFound         = "not_found"
+	CodeInvalidRequest   = "invalid_request"
+	CodeConflict         = "conflict"
+	CodeAlreadyExists    = "already_exists"
+	CodeRateLimited      = "rate_limited"
+	CodeUnavailable      = "unavailable"
+	CodeTimeout          = "timeout"
+	CodeBackendError     = "backend_error"
+)
+
+// CodeAuthorizationTimeout reports a SpiceDB permission check that took too long, so clients
+// can retry instead of treating the request as denied
+const CodeAuthorizationTimeout = "authorization_timeout"
+
+// CodeForStatus returns the error code for an HTTP status, or "" for non-error statuses
+func CodeForStatus(status int) string {
+	switch {
+	case status < 400:
+		return ""
+	case status == http.StatusUnauthorized:
+		return CodeUnauthenticated
+	case status == http.StatusForbidden:
+		return CodePermissionDenied
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusConflict:
+		return CodeConflict
+	case status == http.StatusTooManyRequests:
+		return CodeRateLimited
+	case status == http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case status == http.StatusGatewayTimeout:
+		return CodeTimeout
+	case status < 500:
+		return CodeInvalidRequest
+	default:
+		return CodeBackendError
+	}
+}
@@ -1,18 +1,292 @@
 package api
 
+import "encoding/json"
+
 // API Request types
+
+// CreateNamespaceRequest creates a namespace. DryRun runs the permission checks and a
+// Kubernetes dry-run create without creating the namespace or its relationships.
 type CreateNamespaceRequest struct {
 	Namespace string `json:"namespace"`
+	DryRun    bool   `json:"dryRun,omitempty"`
+}
+
+// ListNamespacesRequest pages through namespaces. Limit is the page size requested from
+// Kubernetes and Continue is the token returned by the previous page; both are optional.
+// Fast looks up the caller's namespaces in SpiceDB first and ignores paging, so it fails for
+// callers holding the permission on more than proxy.MaxLookupNamespaces namespaces; only then
+// do FullyConsistent or AtLeastAsFresh (a ZedToken) control read consistency.
+type ListNamespacesRequest struct {
+	Limit           int64  `json:"limit,omitempty"`
+	Continue        string `json:"continue,omitempty"`
+	Fast            bool   `json:"fast,omitempty"`
+	FullyConsistent bool   `json:"fullyConsistent,omitempty"`
+	AtLeastAsFresh  string `json:"atLeastAsFresh,omitempty"`
+	// LabelSelector narrows the namespaces the user can view to those matching it, such as "team=payments"
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// Permission lists the namespaces the user holds this permission on, such as "edit" or "admin"; defaults to "view"
+	Permission string `json:"permission,omitempty"`
+}
+
+type GetNamespaceRequest struct {
+	Namespace string `json:"namespace"`
+}
+
+type DeleteNamespaceRequest struct {
+	Namespace string `json:"namespace"`
+}
+
+// UpdateNamespaceRequest sets the labels and annotations mapped to a value and removes those
+// mapped to null, leaving any others as they are
+type UpdateNamespaceRequest struct {
+	Namespace   string             `json:"namespace"`
+	Labels      map[string]*string `json:"labels,omitempty"`
+	Annotations map[string]*string `json:"annotations,omitempty"`
 }
 
+// GrantViewPermissionRequest names a user to grant or revoke access for. DryRun, honored
+// by /api/namespaces/grant-view, runs the permission checks without writing the grant;
+// ReturnViewers adds the namespace's viewers, read back after the grant, to the response.
 type GrantViewPermissionRequest struct {
+	Namespace     string `json:"namespace"`
+	User          string `json:"user"`
+	DryRun        bool   `json:"dryRun,omitempty"`
+	ReturnViewers bool   `json:"returnViewers,omitempty"`
+}
+
+// GrantViewBulkRequest grants view access on one namespace to several users at once
+type GrantViewBulkRequest struct {
+	Namespace string   `json:"namespace"`
+	Users     []string `json:"users"`
+}
+
+// GrantTemporaryViewPermissionRequest grants view access that expires after Duration, a Go duration such as "24h"
+type GrantTemporaryViewPermissionRequest struct {
+	Namespace string `json:"namespace"`
+	User      string `json:"user"`
+	Duration  string `json:"duration"`
+}
+
+type GrantGroupViewPermissionRequest struct {
+	Namespace string `json:"namespace"`
+	Group     string `json:"group"`
+}
+
+// GroupMemberRequest adds User to Group or removes them from it. Admin changes whether User
+// is a group admin, who can manage the group's members, instead of their membership.
+type GroupMemberRequest struct {
+	Group string `json:"group"`
+	User  string `json:"user"`
+	Admin bool   `json:"admin,omitempty"`
+}
+
+// ListGroupMembersRequest lists a group's members, with the same consistency options as
+// ListNamespaceViewersRequest
+type ListGroupMembersRequest struct {
+	Group           string `json:"group"`
+	FullyConsistent bool   `json:"fullyConsistent,omitempty"`
+	AtLeastAsFresh  string `json:"atLeastAsFresh,omitempty"`
+}
+
+type RevokeViewPermissionRequest struct {
+	Namespace string `json:"namespace"`
+	User      string `json:"user"`
+}
+
+// ListNamespaceViewersRequest lists a namespace's viewers. Set FullyConsistent or
+// AtLeastAsFresh (a ZedToken) to control read consistency, e.g. to see a grant just written.
+type ListNamespaceViewersRequest struct {
+	Namespace       string `json:"namespace"`
+	FullyConsistent bool   `json:"fullyConsistent,omitempty"`
+	AtLeastAsFresh  string `json:"atLeastAsFresh,omitempty"`
+}
+
+type GrantEditPermissionRequest struct {
 	Namespace string `json:"namespace"`
 	User      string `json:"user"`
 }
 
-// API Response type
+type TransferNamespaceOwnershipRequest struct {
+	Namespace string `json:"namespace"`
+	NewOwner  string `json:"newOwner"`
+}
+
+type CreatePodRequest struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Image     string `json:"image"`
+}
+
+type ListPodsRequest struct {
+	Namespace string `json:"namespace"`
+}
+
+type DeletePodRequest struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// CheckPodAccessRequest checks the caller's view or edit permission on a pod
+type CheckPodAccessRequest struct {
+	Namespace       string `json:"namespace"`
+	Name            string `json:"name"`
+	Permission      string `json:"permission"`
+	FullyConsistent bool   `json:"fullyConsistent,omitempty"`
+	AtLeastAsFresh  string `json:"atLeastAsFresh,omitempty"`
+}
+
+type CreateConfigMapRequest struct {
+	Namespace string            `json:"namespace"`
+	Name      string            `json:"name"`
+	Data      map[string]string `json:"data,omitempty"`
+}
+
+type ListConfigMapsRequest struct {
+	Namespace string `json:"namespace"`
+}
+
+// CreateDeploymentRequest creates a Deployment running Image; Replicas defaults to 1
+type CreateDeploymentRequest struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Image     string `json:"image"`
+	Replicas  *int32 `json:"replicas,omitempty"`
+}
+
+type ListDeploymentsRequest struct {
+	Namespace string `json:"namespace"`
+}
+
+// ResourceRequest targets any Kubernetes resource covered by the proxy rules.
+// Group is empty for the core API group; Object is the body for create and update.
+type ResourceRequest struct {
+	Group     string                 `json:"group,omitempty"`
+	Version   string                 `json:"version"`
+	Resource  string                 `json:"resource"`
+	Namespace string                 `json:"namespace,omitempty"`
+	Name      string                 `json:"name,omitempty"`
+	Object    map[string]interface{} `json:"object,omitempty"`
+}
+
+// BatchRequest runs several operations for the authenticated user in one call
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation is one batch item. Op is create-namespace, delete-namespace, grant-view,
+// grant-edit, revoke-view or create-pod; Params holds the matching endpoint's request body.
+// create-namespace and grant-view honor DryRun like their endpoints; the others reject it.
+type BatchOperation struct {
+	Op     string          `json:"op"`
+	Params json.RawMessage `json:"params"`
+}
+
+// BatchResult reports the outcome of the batch item at Index
+type BatchResult struct {
+	Index   int         `json:"index"`
+	Op      string      `json:"op"`
+	Success bool        `json:"success"`
+	Status  int         `json:"status"`
+	Data    interface{} `json:"data,omitempty"`
+	Code    string      `json:"code,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// ReadRelationshipsRequest filters the relationships returned by /api/relationships/read.
+// At least one filter is required; pass the previous response's cursor to fetch the next page.
+// Limit defaults to 100 and may be at most 1000. Count also reports how many relationships
+// match the filters across all pages, counting at most 100000.
+// Set FullyConsistent or AtLeastAsFresh (a ZedToken) to control read consistency.
+type ReadRelationshipsRequest struct {
+	ResourceType    string `json:"resourceType,omitempty"`
+	ResourceID      string `json:"resourceId,omitempty"`
+	Relation        string `json:"relation,omitempty"`
+	SubjectType     string `json:"subjectType,omitempty"`
+	Limit           uint32 `json:"limit,omitempty"`
+	Cursor          string `json:"cursor,omitempty"`
+	Count           bool   `json:"count,omitempty"`
+	FullyConsistent bool   `json:"fullyConsistent,omitempty"`
+	AtLeastAsFresh  string `json:"atLeastAsFresh,omitempty"`
+}
+
+// UserPermissionsRequest asks for a user's effective permissions. Permissions maps resource
+// types to the permissions checked on them and defaults to admin, edit and view on namespaces
+// and edit and view on pods. Limit bounds the resources reported per permission.
+type UserPermissionsRequest struct {
+	User            string              `json:"user"`
+	Permissions     map[string][]string `json:"permissions,omitempty"`
+	Limit           uint32              `json:"limit,omitempty"`
+	FullyConsistent bool                `json:"fullyConsistent,omitempty"`
+	AtLeastAsFresh  string              `json:"atLeastAsFresh,omitempty"`
+}
+
+// DeleteUserDataRequest removes every relationship in which User is the subject, for
+// offboarding. Resources User created are reassigned to ReassignTo; when it is empty and
+// User still owns resources the request is refused. Confirm must be set.
+type DeleteUserDataRequest struct {
+	User       string `json:"user"`
+	ReassignTo string `json:"reassignTo,omitempty"`
+	Confirm    bool   `json:"confirm"`
+}
+
+// MergeUserSubjectsRequest moves every relationship of user From to user Into, such as after
+// the same person authenticated under a second name
+type MergeUserSubjectsRequest struct {
+	From    string `json:"from"`
+	Into    string `json:"into"`
+	Confirm bool   `json:"confirm"`
+}
+
+// DeleteRelationshipsRequest removes every relationship matching the filter. Confirm must
+// be set, since an unnarrowed filter deletes all relationships of the resource type.
+type DeleteRelationshipsRequest struct {
+	ResourceType string `json:"resourceType"`
+	ResourceID   string `json:"resourceId,omitempty"`
+	Relation     string `json:"relation,omitempty"`
+	Confirm      bool   `json:"confirm"`
+}
+
+// WriteSchemaRequest replaces the SpiceDB schema
+type WriteSchemaRequest struct {
+	Schema string `json:"schema"`
+}
+
+// CheckPermissionRequest asks whether a subject has a permission on a resource.
+// SubjectType defaults to "user" and SubjectID to the authenticated caller; checking
+// any other subject requires a cluster admin.
+// Set FullyConsistent or AtLeastAsFresh (a ZedToken) to control read consistency.
+type CheckPermissionRequest struct {
+	ResourceType    string `json:"resourceType"`
+	ResourceID      string `json:"resourceId"`
+	Permission      string `json:"permission"`
+	SubjectType     string `json:"subjectType,omitempty"`
+	SubjectID       string `json:"subjectId,omitempty"`
+	FullyConsistent bool   `json:"fullyConsistent,omitempty"`
+	AtLeastAsFresh  string `json:"atLeastAsFresh,omitempty"`
+}
+
+// BulkCheckItem is one permission checked by a BulkCheckRequest
+type BulkCheckItem struct {
+	ResourceType string `json:"resourceType"`
+	ResourceID   string `json:"resourceId"`
+	Permission   string `json:"permission"`
+}
+
+// BulkCheckRequest checks several permissions for one subject in a single call, with the
+// same subject and consistency defaults as CheckPermissionRequest. Checking another subject
+// requires a cluster admin. Results are returned in the order of Items.
+type BulkCheckRequest struct {
+	Items           []BulkCheckItem `json:"items"`
+	SubjectType     string          `json:"subjectType,omitempty"`
+	SubjectID       string          `json:"subjectId,omitempty"`
+	FullyConsistent bool            `json:"fullyConsistent,omitempty"`
+	AtLeastAsFresh  string          `json:"atLeastAsFresh,omitempty"`
+}
+
+// API Response type. Failed responses carry a machine-readable Code and a human-readable Error.
 type Response struct {
 	Success bool        `json:"success"`
 	Data    interface{} `json:"data,omitempty"`
+	Code    string      `json:"code,omitempty"`
 	Error   string      `json:"error,omitempty"`
 }
\ No newline at end of file
@@ -3,6 +3,7 @@ package auth
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 
@@ -11,13 +12,36 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/metrics"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/tracing"
 )
 
+// contextKey is an unexported type for context keys defined in this package,
+// preventing collisions with keys defined elsewhere
+type contextKey struct{}
+
+// userContextKey is the context key for the authenticated UserInfo
+var userContextKey = contextKey{}
+
 // UserInfo represents authenticated user information
 type UserInfo struct {
 	Username string
 	Groups   []string
 	UID      string
+	// Impersonator is the authenticated caller when this identity was assumed through impersonation
+	Impersonator *UserInfo
+}
+
+// String returns the username, noting the real caller for impersonated identities,
+// e.g. "alice acting as bob"
+func (u *UserInfo) String() string {
+	if u.Impersonator != nil {
+		return fmt.Sprintf("%s acting as %s", u.Impersonator.Username, u.Username)
+	}
+	return u.Username
 }
 
 // AuthenticationResult contains auth result and user info
@@ -30,54 +54,167 @@ type AuthenticationResult struct {
 // Authenticator handles different authentication methods
 type Authenticator struct {
 	kubeClient kubernetes.Interface
+	oidc       *oidcVerifier
+	sarCache   *sarCache
+	// impersonation enables honoring Impersonate-User / Impersonate-Group headers
+	impersonation bool
+	logger        *slog.Logger
+	// audiences are the TokenReview audiences a bearer token must be valid for
+	audiences []string
+	// basicAuth enables HTTP Basic authentication, with optional static credentials by username
+	basicAuth        bool
+	basicCredentials map[string]basicCredential
+	// headerAuth reads the user from headers set by an authenticating proxy; nil disables it
+	headerAuth *headerAuth
+	// cookieAuth reads bearer tokens from a cookie; nil disables it
+	cookieAuth *CookieAuthConfig
+}
+
+// Option configures optional Authenticator behavior
+type Option func(*Authenticator) error
+
+// WithTokenAudiences requires bearer tokens validated through TokenReview to be issued for at
+// least one of audiences, such as projected service account tokens scoped to the proxy
+func WithTokenAudiences(audiences ...string) Option {
+	return func(a *Authenticator) error {
+		a.audiences = append(a.audiences, audiences...)
+		return nil
+	}
+}
+
+// WithLogger sets the logger used by the authenticator; slog.Default() is used otherwise
+func WithLogger(logger *slog.Logger) Option {
+	return func(a *Authenticator) error {
+		a.logger = logger
+		return nil
+	}
 }
 
 // NewAuthenticator creates a new authenticator with Kubernetes client
-func NewAuthenticator(kubeConfig *rest.Config) (*Authenticator, error) {
+func NewAuthenticator(kubeConfig *rest.Config, opts ...Option) (*Authenticator, error) {
 	kubeClient, err := kubernetes.NewForConfig(kubeConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
 	}
 
-	return &Authenticator{
+	a := &Authenticator{
 		kubeClient: kubeClient,
-	}, nil
+		logger:     slog.Default(),
+		headerAuth: newHeaderAuth(HeaderAuthConfig{}),
+	}
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+
+	return a, nil
 }
 
-// AuthenticateRequest extracts and validates user from HTTP request
+// AuthenticateRequest extracts and validates user from HTTP request, applying
+// Impersonate-User / Impersonate-Group headers when the caller is allowed to impersonate
 func (a *Authenticator) AuthenticateRequest(r *http.Request) *AuthenticationResult {
+	ctx, span := tracing.Start(r.Context(), "auth.AuthenticateRequest")
+	r = r.WithContext(ctx)
+
+	result := a.authenticate(r)
+	if result.Authenticated && hasImpersonationHeaders(r) {
+		result = a.impersonate(r, result.User)
+	}
+	if !result.Authenticated {
+		a.logger.DebugContext(r.Context(), "authentication failed", "path", r.URL.Path, "error", result.Error)
+		tracing.End(span, result.Error)
+		return result
+	}
+	span.SetAttributes(attribute.String("user", result.User.Username))
+	tracing.End(span, nil)
+	return result
+}
+
+// authenticate identifies the caller making the request
+func (a *Authenticator) authenticate(r *http.Request) *AuthenticationResult {
 	// Try different authentication methods in order of preference
 	
 	// 1. Try Bearer token authentication
 	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
 		if strings.HasPrefix(authHeader, "Bearer ") {
 			token := strings.TrimPrefix(authHeader, "Bearer ")
-			return a.authenticateToken(r.Context(), token)
+			return recordAuthentication("bearer", a.authenticateBearer(r.Context(), token))
 		}
 	}
 	
 	// 2. Try client certificate authentication
 	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
-		return a.authenticateCertificate(r)
+		return recordAuthentication("certificate", a.authenticateCertificate(r))
 	}
 	
-	// 3. Try custom headers (for testing/development)
-	if username := r.Header.Get("X-Remote-User"); username != "" {
-		groups := strings.Split(r.Header.Get("X-Remote-Groups"), ",")
-		return &AuthenticationResult{
-			Authenticated: true,
-			User: &UserInfo{
-				Username: username,
-				Groups:   groups,
-				UID:      username, // Use username as UID for header auth
-			},
+	// 3. Try Basic authentication when enabled
+	if a.basicAuth {
+		if username, password, ok := r.BasicAuth(); ok {
+			return recordAuthentication("basic", a.authenticateBasic(r.Context(), username, password))
 		}
 	}
 	
-	return &AuthenticationResult{
+	// 4. Try a bearer token cookie when enabled
+	if a.cookieAuth != nil {
+		if result, ok := a.authenticateCookie(r); ok {
+			return recordAuthentication("cookie", result)
+		}
+	}
+
+	// 5. Try custom headers (for testing/development)
+	if a.headerAuth != nil {
+		if result, ok := a.headerAuth.authenticate(r); ok {
+			return recordAuthentication("header", result)
+		}
+	}
+	
+	return recordAuthentication("none", &AuthenticationResult{
 		Authenticated: false,
 		Error:         fmt.Errorf("no valid authentication method found"),
+	})
+}
+
+// recordAuthentication records the outcome of an authentication method in metrics
+func recordAuthentication(method string, result *AuthenticationResult) *AuthenticationResult {
+	metrics.RecordAuthentication(method, result.Authenticated)
+	return result
+}
+
+// parseGroups splits a comma separated group list, trimming whitespace and
+// dropping empty entries. It returns nil when no groups are present.
+func parseGroups(header string) []string {
+	return splitGroups(header, ",")
+}
+
+// splitGroups splits a group list on delimiter like parseGroups
+func splitGroups(header, delimiter string) []string {
+	var groups []string
+	for _, group := range strings.Split(header, delimiter) {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// authenticateBearer validates a bearer token, trying OIDC first when configured
+// and falling back to Kubernetes TokenReview
+func (a *Authenticator) authenticateBearer(ctx context.Context, token string) *AuthenticationResult {
+	if a.oidc == nil {
+		return a.authenticateToken(ctx, token)
+	}
+
+	oidcResult := a.authenticateOIDC(ctx, token)
+	if oidcResult.Authenticated {
+		return oidcResult
 	}
+
+	result := a.authenticateToken(ctx, token)
+	if !result.Authenticated {
+		result.Error = fmt.Errorf("%v; %w", oidcResult.Error, result.Error)
+	}
+	return result
 }
 
 // authenticateToken validates a bearer token using TokenReview
@@ -85,7 +222,8 @@ func (a *Authenticator) authenticateToken(ctx context.Context, token string) *Au
 	// Use Kubernetes TokenReview to validate the token
 	tokenReview := &authenticationv1.TokenReview{
 		Spec: authenticationv1.TokenReviewSpec{
-			Token: token,
+			Token:     token,
+			Audiences: a.audiences,
 		},
 	}
 	
@@ -103,6 +241,14 @@ func (a *Authenticator) authenticateToken(ctx context.Context, token string) *Au
 			Error:         fmt.Errorf("token authentication failed: %s", result.Status.Error),
 		}
 	}
+
+	// The API server reports which of the requested audiences the token is valid for
+	if len(a.audiences) > 0 && !audiencesIntersect(a.audiences, result.Status.Audiences) {
+		return &AuthenticationResult{
+			Authenticated: false,
+			Error:         fmt.Errorf("token audiences %v do not include any of %v", result.Status.Audiences, a.audiences),
+		}
+	}
 	
 	return &AuthenticationResult{
 		Authenticated: true,
@@ -114,6 +260,18 @@ func (a *Authenticator) authenticateToken(ctx context.Context, token string) *Au
 	}
 }
 
+// audiencesIntersect reports whether any expected audience appears in got
+func audiencesIntersect(expected, got []string) bool {
+	for _, e := range expected {
+		for _, g := range got {
+			if e == g {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // authenticateCertificate extracts user info from client certificate
 func (a *Authenticator) authenticateCertificate(r *http.Request) *AuthenticationResult {
 	cert := r.TLS.PeerCertificates[0]
@@ -141,7 +299,27 @@ func (a *Authenticator) authenticateCertificate(r *http.Request) *Authentication
 }
 
 // CheckKubernetesPermission checks if user has permission for a specific Kubernetes action
-func (a *Authenticator) CheckKubernetesPermission(ctx context.Context, user *UserInfo, resource, verb, namespace string) (bool, error) {
+func (a *Authenticator) CheckKubernetesPermission(ctx context.Context, user *UserInfo, resource, verb, namespace string) (allowed bool, err error) {
+	ctx, span := tracing.Start(ctx, "auth.SubjectAccessReview",
+		attribute.String("user", user.Username),
+		attribute.String("resource", resource),
+		attribute.String("verb", verb),
+		attribute.String("namespace", namespace),
+	)
+	defer func() {
+		span.SetAttributes(attribute.Bool("allowed", allowed))
+		tracing.End(span, err)
+	}()
+
+	var cacheKey string
+	if a.sarCache != nil {
+		cacheKey = sarCacheKey(user, resource, verb, namespace)
+		if allowed, ok := a.sarCache.get(cacheKey); ok {
+			span.SetAttributes(attribute.Bool("cached", true))
+			return allowed, nil
+		}
+	}
+
 	// Use SubjectAccessReview to check permissions
 	sar := &authv1.SubjectAccessReview{
 		Spec: authv1.SubjectAccessReviewSpec{
@@ -160,7 +338,11 @@ func (a *Authenticator) CheckKubernetesPermission(ctx context.Context, user *Use
 	if err != nil {
 		return false, fmt.Errorf("subject access review failed: %w", err)
 	}
-	
+
+	if a.sarCache != nil {
+		a.sarCache.set(cacheKey, result.Status.Allowed)
+	}
+
 	return result.Status.Allowed, nil
 }
 
@@ -175,7 +357,7 @@ func (a *Authenticator) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		}
 		
 		// Add user info to request context
-		ctx := context.WithValue(r.Context(), "user", authResult.User)
+		ctx := context.WithValue(r.Context(), userContextKey, authResult.User)
 		r = r.WithContext(ctx)
 		
 		next(w, r)
@@ -184,6 +366,6 @@ func (a *Authenticator) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 
 // GetUserFromContext extracts UserInfo from request context
 func GetUserFromContext(ctx context.Context) (*UserInfo, bool) {
-	user, ok := ctx.Value("user").(*UserInfo)
+	user, ok := ctx.Value(userContextKey).(*UserInfo)
 	return user, ok
 }
\ No newline at end of file
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	authnv1 "k8s.io/api/authentication/v1"
+	"k8s.io/client-go/rest"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/internal/fakekube"
+)
+
+// newTestAuthenticator returns an authenticator whose Kubernetes API is never reached
+func newTestAuthenticator(t *testing.T, opts ...Option) *Authenticator {
+	t.Helper()
+	a, err := NewAuthenticator(&rest.Config{Host: "https://127.0.0.1:1"}, opts...)
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+	return a
+}
+
+func TestGetUserFromContextIgnoresForeignKeys(t *testing.T) {
+	// Another package storing a user under a plain string key must not be mistaken for the
+	// authenticated user
+	ctx := context.WithValue(context.Background(), "user", &UserInfo{Username: "mallory"})
+	if user, ok := GetUserFromContext(ctx); ok {
+		t.Fatalf("GetUserFromContext = %v, want no user", user)
+	}
+
+	a := newTestAuthenticator(t)
+	var got *UserInfo
+	handler := a.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = GetUserFromContext(r.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	req.Header.Set("X-Remote-User", "alice")
+	handler(httptest.NewRecorder(), req)
+	if got == nil || got.Username != "alice" {
+		t.Fatalf("user in context = %v, want alice", got)
+	}
+}
+
+func TestParseGroups(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"a,b", []string{"a", "b"}},
+		{"a, b", []string{"a", "b"}},
+		{" a ,b, ", []string{"a", "b"}},
+		{"a,,b", []string{"a", "b"}},
+		{"", nil},
+		{" ", nil},
+		{",", nil},
+	}
+	for _, tt := range tests {
+		got := parseGroups(tt.header)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseGroups(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestSplitGroups(t *testing.T) {
+	tests := []struct {
+		header    string
+		delimiter string
+		want      []string
+	}{
+		{"a;b", ";", []string{"a", "b"}},
+		{"a; b", ";", []string{"a", "b"}},
+		{"a,b", ";", []string{"a,b"}},
+		{"", ";", nil},
+		{" ", ";", nil},
+	}
+	for _, tt := range tests {
+		got := splitGroups(tt.header, tt.delimiter)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitGroups(%q, %q) = %q, want %q", tt.header, tt.delimiter, got, tt.want)
+		}
+	}
+}
+
+func TestTokenAudienceMismatchRejected(t *testing.T) {
+	kube := fakekube.New()
+	defer kube.Close()
+
+	// The audiences each token was issued for. Like an API server that does not enforce the
+	// requested audiences itself, the fake authenticates every known token and reports them.
+	issued := map[string][]string{
+		"proxy-token": {"spicedb-proxy"},
+		"api-token":   {"https://kubernetes.default.svc"},
+	}
+	var requested []string
+	kube.SetTokenReview(func(review *authnv1.TokenReview) {
+		requested = review.Spec.Audiences
+		audiences, ok := issued[review.Spec.Token]
+		if !ok {
+			review.Status.Error = "unknown token"
+			return
+		}
+		review.Status.Authenticated = true
+		review.Status.User = authnv1.UserInfo{Username: "system:serviceaccount:default:" + review.Spec.Token}
+		review.Status.Audiences = audiences
+	})
+
+	authenticate := func(a *Authenticator, token string) *AuthenticationResult {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return a.AuthenticateRequest(req)
+	}
+
+	scoped, err := NewAuthenticator(kube.RestConfig(), WithTokenAudiences("spicedb-proxy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := authenticate(scoped, "proxy-token"); !result.Authenticated {
+		t.Errorf("token for the proxy audience rejected: %v", result.Error)
+	}
+	if len(requested) != 1 || requested[0] != "spicedb-proxy" {
+		t.Errorf("TokenReview requested audiences %v, want [spicedb-proxy]", requested)
+	}
+	if result := authenticate(scoped, "api-token"); result.Authenticated {
+		t.Errorf("token for another audience authenticated as %s", result.User.Username)
+	}
+	if result := authenticate(scoped, "forged"); result.Authenticated {
+		t.Error("unknown token authenticated")
+	}
+
+	// Without configured audiences any valid token is accepted
+	unscoped, err := NewAuthenticator(kube.RestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := authenticate(unscoped, "api-token"); !result.Authenticated {
+		t.Errorf("token rejected without configured audiences: %v", result.Error)
+	}
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// BasicCredential is a static identity accepted through HTTP Basic authentication
+type BasicCredential struct {
+	Password string
+	// UID defaults to the username
+	UID    string
+	Groups []string
+}
+
+// basicCredential holds a static credential with only the password digest kept in memory
+type basicCredential struct {
+	passwordHash [sha256.Size]byte
+	uid          string
+	groups       []string
+}
+
+// WithBasicAuth enables HTTP Basic authentication, tried after bearer tokens and client
+// certificates. A username with an entry in credentials must present that password; for any
+// other username the password is validated as a bearer token through TokenReview and the
+// username is ignored, so tools that can only send Basic credentials can still use service
+// account tokens. Basic authentication is disabled unless this option is given.
+func WithBasicAuth(credentials map[string]BasicCredential) Option {
+	return func(a *Authenticator) error {
+		a.basicAuth = true
+		a.basicCredentials = make(map[string]basicCredential, len(credentials))
+		for username, cred := range credentials {
+			if username == "" || cred.Password == "" {
+				return fmt.Errorf("basic auth credentials require a username and password")
+			}
+			uid := cred.UID
+			if uid == "" {
+				uid = username
+			}
+			a.basicCredentials[username] = basicCredential{
+				passwordHash: sha256.Sum256([]byte(cred.Password)),
+				uid:          uid,
+				groups:       cred.Groups,
+			}
+		}
+		return nil
+	}
+}
+
+// LoadBasicCredentialsFile reads static Basic credentials from a CSV file in the
+// kube-apiserver format: password,user,uid[,"group1,group2"]
+func LoadBasicCredentialsFile(path string) (map[string]BasicCredential, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open basic auth file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	credentials := make(map[string]BasicCredential)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse basic auth file %s: %w", path, err)
+		}
+		// Passwords never appear in errors, only line numbers
+		line, _ := reader.FieldPos(0)
+		if len(record) < 3 {
+			return nil, fmt.Errorf("basic auth file %s line %d: expected password,user,uid[,groups]", path, line)
+		}
+		username := strings.TrimSpace(record[1])
+		if _, ok := credentials[username]; ok {
+			return nil, fmt.Errorf("basic auth file %s line %d: duplicate user %q", path, line, username)
+		}
+		cred := BasicCredential{
+			Password: record[0],
+			UID:      strings.TrimSpace(record[2]),
+		}
+		if len(record) > 3 {
+			cred.Groups = parseGroups(record[3])
+		}
+		credentials[username] = cred
+	}
+	return credentials, nil
+}
+
+// authenticateBasic validates HTTP Basic credentials against the static credentials,
+// falling back to treating the password as a bearer token
+func (a *Authenticator) authenticateBasic(ctx context.Context, username, password string) *AuthenticationResult {
+	if cred, ok := a.basicCredentials[username]; ok {
+		hash := sha256.Sum256([]byte(password))
+		if subtle.ConstantTimeCompare(hash[:], cred.passwordHash[:]) != 1 {
+			return &AuthenticationResult{
+				Authenticated: false,
+				Error:         fmt.Errorf("invalid basic auth credentials for user %q", username),
+			}
+		}
+		return &AuthenticationResult{
+			Authenticated: true,
+			User: &UserInfo{
+				Username: username,
+				Groups:   cred.groups,
+				UID:      cred.uid,
+			},
+		}
+	}
+
+	if password == "" {
+		return &AuthenticationResult{
+			Authenticated: false,
+			Error:         fmt.Errorf("basic auth password is empty"),
+		}
+	}
+	// The username is informational; the token identifies the caller
+	return a.authenticateToken(ctx, password)
+}
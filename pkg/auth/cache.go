@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSARCacheTTL     = 10 * time.Second
+	defaultSARCacheMaxSize = 1024
+)
+
+// sarCache is a TTL cache with LRU eviction for SubjectAccessReview decisions
+type sarCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	maxSize int
+	entries map[string]*list.Element
+	// order holds entries from most to least recently used
+	order *list.List
+}
+
+type sarCacheEntry struct {
+	key     string
+	allowed bool
+	expires time.Time
+}
+
+// WithSubjectAccessReviewCache caches SubjectAccessReview decisions for ttl, keeping at most
+// maxSize entries. Zero values select the defaults (10s, 1024 entries).
+func WithSubjectAccessReviewCache(ttl time.Duration, maxSize int) Option {
+	return func(a *Authenticator) error {
+		a.sarCache = newSARCache(ttl, maxSize)
+		return nil
+	}
+}
+
+func newSARCache(ttl time.Duration, maxSize int) *sarCache {
+	if ttl <= 0 {
+		ttl = defaultSARCacheTTL
+	}
+	if maxSize <= 0 {
+		maxSize = defaultSARCacheMaxSize
+	}
+	return &sarCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// sarCacheKey builds the cache key for a permission check. Groups are sorted so
+// that group ordering does not affect the key.
+func sarCacheKey(user *UserInfo, resource, verb, namespace string) string {
+	groups := append([]string(nil), user.Groups...)
+	sort.Strings(groups)
+
+	// NUL separators cannot appear in Kubernetes names, so fields cannot run into each other
+	return strings.Join([]string{
+		user.Username,
+		user.UID,
+		strings.Join(groups, ","),
+		resource,
+		verb,
+		namespace,
+	}, "\x00")
+}
+
+// get returns the cached decision for key, if present and not expired
+func (c *sarCache) get(key string) (allowed bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return false, false
+	}
+
+	entry := elem.Value.(*sarCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return false, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.allowed, true
+}
+
+// set stores a decision, evicting the least recently used entry when full
+func (c *sarCache) set(key string, allowed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(c.ttl)
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*sarCacheEntry)
+		entry.allowed = allowed
+		entry.expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&sarCacheEntry{key: key, allowed: allowed, expires: expires})
+
+	for c.order.Len() > c.maxSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*sarCacheEntry).key)
+	}
+}
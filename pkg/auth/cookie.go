@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CookieAuthConfig reads bearer tokens from a cookie, for browser clients that cannot set an
+// Authorization header on every request. The issuer should set the cookie Secure, HttpOnly
+// and SameSite so scripts and other sites cannot use it; those attributes are not sent back
+// with requests, so the proxy can only enforce the transport.
+type CookieAuthConfig struct {
+	// Name is the cookie holding the token
+	Name string
+	// RequireTLS rejects tokens from cookies on requests not made over TLS
+	RequireTLS bool
+}
+
+// WithCookieAuth accepts a bearer token from the named cookie when the request has no
+// Authorization header, client certificate or Basic credentials. The token is validated
+// like an Authorization bearer token. Cookie authentication is disabled unless this option is given.
+func WithCookieAuth(config CookieAuthConfig) Option {
+	return func(a *Authenticator) error {
+		if config.Name == "" || strings.ContainsAny(config.Name, " \t;=,") {
+			return fmt.Errorf("invalid token cookie name %q", config.Name)
+		}
+		a.cookieAuth = &config
+		return nil
+	}
+}
+
+// authenticateCookie validates the token in the configured cookie, reporting false when the
+// request carries no such cookie so other methods can be tried
+func (a *Authenticator) authenticateCookie(r *http.Request) (*AuthenticationResult, bool) {
+	cookie, err := r.Cookie(a.cookieAuth.Name)
+	if errors.Is(err, http.ErrNoCookie) || (err == nil && cookie.Value == "") {
+		return nil, false
+	}
+	if err != nil {
+		return &AuthenticationResult{Authenticated: false, Error: fmt.Errorf("invalid token cookie %s: %w", a.cookieAuth.Name, err)}, true
+	}
+	if a.cookieAuth.RequireTLS && r.TLS == nil {
+		return &AuthenticationResult{Authenticated: false, Error: fmt.Errorf("token cookie %s is only accepted over TLS", a.cookieAuth.Name)}, true
+	}
+	return a.authenticateBearer(r.Context(), cookie.Value), true
+}
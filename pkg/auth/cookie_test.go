@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authnv1 "k8s.io/api/authentication/v1"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/internal/fakekube"
+)
+
+func TestCookieAuth(t *testing.T) {
+	kube := fakekube.New()
+	defer kube.Close()
+
+	users := map[string]string{"alice-token": "alice", "bob-token": "bob"}
+	var reviewed []string
+	kube.SetTokenReview(func(review *authnv1.TokenReview) {
+		reviewed = append(reviewed, review.Spec.Token)
+		username, ok := users[review.Spec.Token]
+		if !ok {
+			review.Status.Error = "unknown token"
+			return
+		}
+		review.Status.Authenticated = true
+		review.Status.User = authnv1.UserInfo{Username: username}
+	})
+
+	newAuthenticator := func(opts ...Option) *Authenticator {
+		a, err := NewAuthenticator(kube.RestConfig(), opts...)
+		if err != nil {
+			t.Fatalf("NewAuthenticator: %v", err)
+		}
+		return a
+	}
+	request := func(cookie string, overTLS bool) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "session", Value: cookie})
+		}
+		if overTLS {
+			req.TLS = &tls.ConnectionState{}
+		}
+		return req
+	}
+
+	secure := newAuthenticator(WithCookieAuth(CookieAuthConfig{Name: "session", RequireTLS: true}))
+	if result := secure.AuthenticateRequest(request("alice-token", true)); !result.Authenticated || result.User.Username != "alice" {
+		t.Errorf("cookie over TLS = %+v, want alice", result)
+	}
+
+	reviewed = nil
+	result := secure.AuthenticateRequest(request("bob-token", false))
+	if result.Authenticated || result.Error == nil || !strings.Contains(result.Error.Error(), "only accepted over TLS") {
+		t.Errorf("cookie without TLS = %+v, want rejected as needing TLS", result)
+	}
+	if len(reviewed) != 0 {
+		t.Errorf("token from a non-TLS cookie was sent for review: %v", reviewed)
+	}
+
+	if result := secure.AuthenticateRequest(request("forged-token", true)); result.Authenticated {
+		t.Errorf("unknown cookie token authenticated as %s", result.User.Username)
+	}
+
+	// An Authorization header takes precedence over the cookie
+	req := request("alice-token", true)
+	req.Header.Set("Authorization", "Bearer bob-token")
+	if result := secure.AuthenticateRequest(req); !result.Authenticated || result.User.Username != "bob" {
+		t.Errorf("cookie and Authorization header = %+v, want bob", result)
+	}
+
+	// Without the cookie, or with an empty one, other methods are tried
+	for _, cookie := range []string{"", "session="} {
+		req := request("", false)
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		req.Header.Set("X-Remote-User", "carol")
+		if result := secure.AuthenticateRequest(req); !result.Authenticated || result.User.Username != "carol" {
+			t.Errorf("request with cookie header %q = %+v, want carol from the header", cookie, result)
+		}
+	}
+
+	plain := newAuthenticator(WithCookieAuth(CookieAuthConfig{Name: "session"}))
+	if result := plain.AuthenticateRequest(request("bob-token", false)); !result.Authenticated || result.User.Username != "bob" {
+		t.Errorf("cookie without TLS when TLS is not required = %+v, want bob", result)
+	}
+
+	// Cookies are ignored unless cookie authentication is enabled
+	if result := newAuthenticator().AuthenticateRequest(request("alice-token", true)); result.Authenticated {
+		t.Errorf("cookie authenticated %s without cookie authentication enabled", result.User.Username)
+	}
+
+	for _, name := range []string{"", "my session", "a;b", "a=b", "a,b"} {
+		if _, err := NewAuthenticator(kube.RestConfig(), WithCookieAuth(CookieAuthConfig{Name: name})); err == nil {
+			t.Errorf("cookie name %q accepted", name)
+		}
+	}
+}
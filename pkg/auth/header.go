@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultUserHeader     = "X-Remote-User"
+	defaultGroupHeader    = "X-Remote-Groups"
+	defaultGroupDelimiter = ","
+)
+
+// HeaderAuthConfig names the headers an upstream authenticating proxy uses to pass the
+// authenticated user. Header authentication trusts these headers as-is, so it must only be
+// enabled behind a proxy that strips them from client requests.
+type HeaderAuthConfig struct {
+	// UserHeader carries the username (default "X-Remote-User")
+	UserHeader string
+	// GroupHeaders carry the user's groups; every value of every header is used (default "X-Remote-Groups")
+	GroupHeaders []string
+	// GroupDelimiter separates groups within one header value (default ",")
+	GroupDelimiter string
+}
+
+// headerAuth is a HeaderAuthConfig with defaults applied
+type headerAuth struct {
+	userHeader     string
+	groupHeaders   []string
+	groupDelimiter string
+}
+
+// newHeaderAuth applies defaults to config
+func newHeaderAuth(config HeaderAuthConfig) *headerAuth {
+	h := &headerAuth{
+		userHeader:     config.UserHeader,
+		groupHeaders:   config.GroupHeaders,
+		groupDelimiter: config.GroupDelimiter,
+	}
+	if h.userHeader == "" {
+		h.userHeader = defaultUserHeader
+	}
+	if len(h.groupHeaders) == 0 {
+		h.groupHeaders = []string{defaultGroupHeader}
+	}
+	if h.groupDelimiter == "" {
+		h.groupDelimiter = defaultGroupDelimiter
+	}
+	return h
+}
+
+// WithHeaderAuth replaces the X-Remote-User / X-Remote-Groups header names used for header authentication
+func WithHeaderAuth(config HeaderAuthConfig) Option {
+	return func(a *Authenticator) error {
+		for _, name := range append([]string{config.UserHeader}, config.GroupHeaders...) {
+			if strings.ContainsAny(name, " \t:") {
+				return fmt.Errorf("invalid header auth header name %q", name)
+			}
+		}
+		a.headerAuth = newHeaderAuth(config)
+		return nil
+	}
+}
+
+// WithoutHeaderAuth disables header authentication, so requests carrying only user
+// headers are unauthenticated. Production deployments not behind an authenticating
+// proxy should use this.
+func WithoutHeaderAuth() Option {
+	return func(a *Authenticator) error {
+		a.headerAuth = nil
+		return nil
+	}
+}
+
+// authenticate reads the user from the configured headers, reporting false when the
+// user header is absent so other methods can be tried
+func (h *headerAuth) authenticate(r *http.Request) (*AuthenticationResult, bool) {
+	username := r.Header.Get(h.userHeader)
+	if username == "" {
+		return nil, false
+	}
+
+	var groups []string
+	for _, header := range h.groupHeaders {
+		for _, value := range r.Header.Values(header) {
+			groups = append(groups, splitGroups(value, h.groupDelimiter)...)
+		}
+	}
+	return &AuthenticationResult{
+		Authenticated: true,
+		User: &UserInfo{
+			Username: username,
+			Groups:   groups,
+			UID:      username, // Use username as UID for header auth
+		},
+	}, true
+}
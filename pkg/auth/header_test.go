@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestHeaderAuth(t *testing.T) {
+	request := func(headers map[string][]string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for name, values := range headers {
+			for _, v := range values {
+				req.Header.Add(name, v)
+			}
+		}
+		return req
+	}
+
+	custom := newTestAuthenticator(t, WithHeaderAuth(HeaderAuthConfig{
+		UserHeader:     "X-Forwarded-User",
+		GroupHeaders:   []string{"X-Forwarded-Groups", "X-Forwarded-Teams"},
+		GroupDelimiter: ";",
+	}))
+	result := custom.AuthenticateRequest(request(map[string][]string{
+		"X-Forwarded-User":   {"alice"},
+		"X-Forwarded-Groups": {"dev; ops", "qa"},
+		"X-Forwarded-Teams":  {"blue"},
+	}))
+	if !result.Authenticated || result.User.Username != "alice" {
+		t.Fatalf("custom headers: %+v, want alice", result)
+	}
+	if want := []string{"dev", "ops", "qa", "blue"}; !reflect.DeepEqual(result.User.Groups, want) {
+		t.Errorf("custom headers groups = %q, want %q", result.User.Groups, want)
+	}
+
+	// Once renamed, the default headers are no longer trusted
+	if result := custom.AuthenticateRequest(request(map[string][]string{"X-Remote-User": {"mallory"}})); result.Authenticated {
+		t.Errorf("default user header authenticated %s after renaming", result.User.Username)
+	}
+
+	disabled := newTestAuthenticator(t, WithoutHeaderAuth())
+	if result := disabled.AuthenticateRequest(request(map[string][]string{"X-Remote-User": {"mallory"}})); result.Authenticated {
+		t.Errorf("disabled header auth authenticated %s", result.User.Username)
+	}
+
+	if _, err := NewAuthenticator(&rest.Config{Host: "https://127.0.0.1:1"}, WithHeaderAuth(HeaderAuthConfig{UserHeader: "X-User:"})); err == nil {
+		t.Error("invalid header name accepted")
+	}
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/coreos/go-oidc"
+)
+
+const (
+	defaultOIDCUsernameClaim = "sub"
+	defaultOIDCGroupsClaim   = "groups"
+	defaultOIDCClockSkew     = 30 * time.Second
+)
+
+// OIDCConfig configures validation of OIDC ID tokens
+type OIDCConfig struct {
+	// IssuerURL is the OIDC issuer; its discovery document provides the JWKS endpoint
+	IssuerURL string
+	// ClientID must be present in the token audience
+	ClientID string
+	// UsernameClaim is the claim mapped to UserInfo.Username (default "sub")
+	UsernameClaim string
+	// GroupsClaim is the claim mapped to UserInfo.Groups (default "groups")
+	GroupsClaim string
+	// ClockSkew is the tolerance applied when checking token expiry (default 30s)
+	ClockSkew time.Duration
+}
+
+// oidcVerifier validates ID tokens against an OIDC provider. Signing keys are
+// fetched from the provider's JWKS endpoint, cached, and refreshed when a token
+// is signed with an unknown key, so provider key rotation is picked up automatically.
+type oidcVerifier struct {
+	verifier      *oidc.IDTokenVerifier
+	usernameClaim string
+	groupsClaim   string
+}
+
+// WithOIDC enables OIDC ID token authentication, tried before TokenReview for bearer tokens
+func WithOIDC(config OIDCConfig) Option {
+	return func(a *Authenticator) error {
+		verifier, err := newOIDCVerifier(context.Background(), config)
+		if err != nil {
+			return err
+		}
+		a.oidc = verifier
+		return nil
+	}
+}
+
+// newOIDCVerifier discovers the provider configuration and builds a verifier
+func newOIDCVerifier(ctx context.Context, config OIDCConfig) (*oidcVerifier, error) {
+	if config.IssuerURL == "" || config.ClientID == "" {
+		return nil, fmt.Errorf("OIDC issuer URL and client ID are required")
+	}
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = defaultOIDCUsernameClaim
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = defaultOIDCGroupsClaim
+	}
+	if config.ClockSkew == 0 {
+		config.ClockSkew = defaultOIDCClockSkew
+	}
+
+	provider, err := oidc.NewProvider(ctx, config.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider %s: %w", config.IssuerURL, err)
+	}
+
+	skew := config.ClockSkew
+	verifier := provider.Verifier(&oidc.Config{
+		ClientID: config.ClientID,
+		// Shift the clock back so tokens are accepted until exp + skew
+		Now: func() time.Time { return time.Now().Add(-skew) },
+	})
+
+	return &oidcVerifier{
+		verifier:      verifier,
+		usernameClaim: config.UsernameClaim,
+		groupsClaim:   config.GroupsClaim,
+	}, nil
+}
+
+// authenticateOIDC validates an OIDC ID token and maps its claims to UserInfo
+func (a *Authenticator) authenticateOIDC(ctx context.Context, rawToken string) *AuthenticationResult {
+	token, err := a.oidc.verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return &AuthenticationResult{
+			Authenticated: false,
+			Error:         fmt.Errorf("OIDC token verification failed: %w", err),
+		}
+	}
+
+	var claims map[string]interface{}
+	if err := token.Claims(&claims); err != nil {
+		return &AuthenticationResult{
+			Authenticated: false,
+			Error:         fmt.Errorf("failed to parse OIDC claims: %w", err),
+		}
+	}
+
+	username, ok := claims[a.oidc.usernameClaim].(string)
+	if !ok || username == "" {
+		return &AuthenticationResult{
+			Authenticated: false,
+			Error:         fmt.Errorf("OIDC token is missing the %q claim", a.oidc.usernameClaim),
+		}
+	}
+
+	return &AuthenticationResult{
+		Authenticated: true,
+		User: &UserInfo{
+			Username: username,
+			Groups:   claimStrings(claims[a.oidc.groupsClaim]),
+			UID:      token.Subject,
+		},
+	}
+}
+
+// claimStrings converts a string or list-of-strings claim into a slice
+func claimStrings(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/api"
+)
+
+// maxErrorBodySize bounds how much of a non-JSON error response is kept in Error.Message
+const maxErrorBodySize = 4096
+
+// Client calls the proxy integration HTTP API on behalf of one bearer token
+type Client struct {
+	baseURL    *url.URL
+	token      string
+	httpClient *http.Client
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests with httpClient instead of http.DefaultClient,
+// for example to set timeouts or TLS settings
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// NewClient returns a client for the API served at baseURL that authenticates with
+// token as a bearer token. An empty token sends unauthenticated requests.
+func NewClient(baseURL, token string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", baseURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", baseURL)
+	}
+
+	c := &Client{baseURL: u, token: token, httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Error is returned when the API reports a failure. Code is one of the api.Code constants.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (HTTP %d): %s", e.Code, e.StatusCode, e.Message)
+}
+
+// CreateNamespaceResult is returned by CreateNamespace
+type CreateNamespaceResult struct {
+	Namespace string `json:"namespace"`
+	User      string `json:"user"`
+}
+
+// CreateNamespace creates a namespace owned by the caller
+func (c *Client) CreateNamespace(ctx context.Context, namespace string) (*CreateNamespaceResult, error) {
+	var result CreateNamespaceResult
+	if err := c.post(ctx, "/api/namespaces/create", api.CreateNamespaceRequest{Namespace: namespace}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListNamespacesResult is one page of namespaces the caller can see
+type ListNamespacesResult struct {
+	Namespaces []string `json:"namespaces"`
+	// Continue is passed in the next request to fetch the following page; empty on the last page
+	Continue string `json:"continue"`
+	User     string `json:"user"`
+}
+
+// ListNamespaces lists the namespaces the caller can see
+func (c *Client) ListNamespaces(ctx context.Context, req api.ListNamespacesRequest) (*ListNamespacesResult, error) {
+	var result ListNamespacesResult
+	if err := c.post(ctx, "/api/namespaces/list", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GrantResult is returned by GrantView
+type GrantResult struct {
+	Namespace  string `json:"namespace"`
+	User       string `json:"user"`
+	Permission string `json:"permission"`
+	GrantedBy  string `json:"granted_by"`
+	// WrittenAt is the ZedToken the grant was written at. Pass it as AtLeastAsFresh to a
+	// later read, such as ListNamespaces, to be sure the read sees the grant.
+	WrittenAt string `json:"written_at"`
+}
+
+// GrantView grants user view access to a namespace
+func (c *Client) GrantView(ctx context.Context, namespace, user string) (*GrantResult, error) {
+	var result GrantResult
+	if err := c.post(ctx, "/api/namespaces/grant-view", api.GrantViewPermissionRequest{Namespace: namespace, User: user}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CheckPermissionResult is the SpiceDB decision returned by CheckPermission
+type CheckPermissionResult struct {
+	Allowed        bool   `json:"allowed"`
+	Permissionship string `json:"permissionship"`
+	// CheckedAt is the ZedToken the check was evaluated at
+	CheckedAt  string `json:"checked_at"`
+	Resource   string `json:"resource"`
+	Permission string `json:"permission"`
+	Subject    string `json:"subject"`
+}
+
+// CheckPermission asks whether a subject, by default the caller, has a permission on a resource
+func (c *Client) CheckPermission(ctx context.Context, req api.CheckPermissionRequest) (*CheckPermissionResult, error) {
+	var result CheckPermissionResult
+	if err := c.post(ctx, "/api/permissions/check", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// response mirrors api.Response, keeping Data raw so it can be decoded into a typed result
+type response struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	Code    string          `json:"code"`
+	Error   string          `json:"error"`
+}
+
+// post sends body as JSON to path and decodes the response data into out
+func (c *Client) post(ctx context.Context, path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	endpoint := c.baseURL.JoinPath(path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("POST %s failed: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		// Some failures, such as a wrong method, are reported as plain text
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return &Error{StatusCode: resp.StatusCode, Code: api.CodeForStatus(resp.StatusCode), Message: strings.TrimSpace(string(text))}
+	}
+
+	var decoded response
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", path, err)
+	}
+	if !decoded.Success || resp.StatusCode >= 400 {
+		code := decoded.Code
+		if code == "" {
+			code = api.CodeForStatus(resp.StatusCode)
+		}
+		return &Error{StatusCode: resp.StatusCode, Code: code, Message: decoded.Error}
+	}
+
+	if out != nil && len(decoded.Data) > 0 {
+		if err := json.Unmarshal(decoded.Data, out); err != nil {
+			return fmt.Errorf("failed to decode %s response data: %w", path, err)
+		}
+	}
+	return nil
+}
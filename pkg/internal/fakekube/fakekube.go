@@ -0,0 +1,380 @@
+// Package fakekube serves a small in-memory Kubernetes API for tests: discovery, CRUD on
+// namespaces, pods, configmaps, deployments and testresources, and TokenReview and
+// SubjectAccessReview answered by callbacks.
+package fakekube
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	authnv1 "k8s.io/api/authentication/v1"
+	authzv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// resourceKinds maps the resources served to their kind and whether they are namespaced
+var resourceKinds = map[string]struct {
+	gv         schema.GroupVersion
+	kind       string
+	namespaced bool
+}{
+	"namespaces":    {schema.GroupVersion{Version: "v1"}, "Namespace", false},
+	"pods":          {schema.GroupVersion{Version: "v1"}, "Pod", true},
+	"configmaps":    {schema.GroupVersion{Version: "v1"}, "ConfigMap", true},
+	"deployments":   {schema.GroupVersion{Group: "apps", Version: "v1"}, "Deployment", true},
+	"testresources": {schema.GroupVersion{Group: "example.com", Version: "v1alpha1"}, "TestResource", true},
+}
+
+// Server is an in-memory Kubernetes API served over TLS
+type Server struct {
+	*httptest.Server
+
+	mu                  sync.Mutex
+	objects             map[string]map[string]interface{}
+	nextRV              int
+	tokenReview         func(review *authnv1.TokenReview)
+	subjectAccessReview func(review *authzv1.SubjectAccessReview) bool
+	beforeCreate        func(resource, namespace string)
+}
+
+// New starts a server; call Close when done
+func New() *Server {
+	s := &Server{objects: make(map[string]map[string]interface{})}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// RestConfig returns a config for clients of the server, without client-side rate limiting
+// so benchmarks measure the code under test rather than the client's throttle
+func (s *Server) RestConfig() *rest.Config {
+	return &rest.Config{Host: s.URL, TLSClientConfig: rest.TLSClientConfig{Insecure: true}, QPS: -1}
+}
+
+// SetTokenReview answers TokenReviews with fn, which fills in the review status. Without
+// one every token is unauthenticated.
+func (s *Server) SetTokenReview(fn func(review *authnv1.TokenReview)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenReview = fn
+}
+
+// SetSubjectAccessReview decides SubjectAccessReviews with fn. Without one every review is allowed.
+func (s *Server) SetSubjectAccessReview(fn func(review *authzv1.SubjectAccessReview) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subjectAccessReview = fn
+}
+
+// SetBeforeCreate calls fn before each create request is served, with the store unlocked so
+// fn can Add the object to simulate a create racing the request
+func (s *Server) SetBeforeCreate(fn func(resource, namespace string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.beforeCreate = fn
+}
+
+// Add stores an object directly, bypassing the API. namespace is empty for namespaces.
+func (s *Server) Add(resource, namespace, name string, obj map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(resource, namespace, name, obj)
+}
+
+// Has reports whether an object exists
+func (s *Server) Has(resource, namespace, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.objects[collectionKey(resource, namespace)][name]
+	return ok
+}
+
+func (s *Server) store(resource, namespace, name string, obj map[string]interface{}) {
+	kind := resourceKinds[resource]
+	meta, _ := obj["metadata"].(map[string]interface{})
+	if meta == nil {
+		meta = make(map[string]interface{})
+		obj["metadata"] = meta
+	}
+	s.nextRV++
+	meta["name"] = name
+	if namespace != "" {
+		meta["namespace"] = namespace
+	}
+	meta["resourceVersion"] = strconv.Itoa(s.nextRV)
+	if _, ok := meta["creationTimestamp"]; !ok || meta["creationTimestamp"] == nil {
+		meta["creationTimestamp"] = time.Now().UTC().Format(time.RFC3339)
+	}
+	obj["apiVersion"] = kind.gv.String()
+	obj["kind"] = kind.kind
+
+	key := collectionKey(resource, namespace)
+	if s.objects[key] == nil {
+		s.objects[key] = make(map[string]interface{})
+	}
+	s.objects[key][name] = obj
+}
+
+func collectionKey(resource, namespace string) string {
+	return namespace + "/" + resource
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api":
+		writeJSON(w, http.StatusOK, metav1.APIVersions{TypeMeta: metav1.TypeMeta{Kind: "APIVersions"}, Versions: []string{"v1"}})
+		return
+	case "/apis":
+		writeJSON(w, http.StatusOK, apiGroups())
+		return
+	case "/api/v1", "/apis/apps/v1", "/apis/example.com/v1alpha1":
+		writeJSON(w, http.StatusOK, apiResources(strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/apis/"), "/api/")))
+		return
+	case "/apis/authentication.k8s.io/v1/tokenreviews":
+		s.serveTokenReview(w, r)
+		return
+	case "/apis/authorization.k8s.io/v1/subjectaccessreviews":
+		s.serveSubjectAccessReview(w, r)
+		return
+	}
+
+	resource, namespace, name, ok := parsePath(r.URL.Path)
+	if !ok {
+		writeStatus(w, apierrors.NewNotFound(schema.GroupResource{Resource: r.URL.Path}, ""))
+		return
+	}
+	gr := schema.GroupResource{Group: resourceKinds[resource].gv.Group, Resource: resource}
+
+	if r.Method == http.MethodPost && name == "" {
+		s.mu.Lock()
+		beforeCreate := s.beforeCreate
+		s.mu.Unlock()
+		if beforeCreate != nil {
+			beforeCreate(resource, namespace)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	collection := s.objects[collectionKey(resource, namespace)]
+
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		names := make([]string, 0, len(collection))
+		for n := range collection {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		items := make([]interface{}, 0, len(names))
+		for _, n := range names {
+			items = append(items, collection[n])
+		}
+		kind := resourceKinds[resource]
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"apiVersion": kind.gv.String(),
+			"kind":       kind.kind + "List",
+			"metadata":   map[string]interface{}{"resourceVersion": strconv.Itoa(s.nextRV)},
+			"items":      items,
+		})
+	case r.Method == http.MethodGet:
+		obj, ok := collection[name]
+		if !ok {
+			writeStatus(w, apierrors.NewNotFound(gr, name))
+			return
+		}
+		writeJSON(w, http.StatusOK, obj)
+	case r.Method == http.MethodPost && name == "":
+		obj, err := decodeObject(r)
+		if err != nil {
+			writeStatus(w, apierrors.NewBadRequest(err.Error()))
+			return
+		}
+		meta, _ := obj["metadata"].(map[string]interface{})
+		newName, _ := meta["name"].(string)
+		if newName == "" {
+			if prefix, _ := meta["generateName"].(string); prefix != "" {
+				newName = prefix + strconv.Itoa(s.nextRV+1)
+			}
+		}
+		if _, exists := collection[newName]; exists {
+			writeStatus(w, apierrors.NewAlreadyExists(gr, newName))
+			return
+		}
+		if isDryRun(r) {
+			writeJSON(w, http.StatusCreated, obj)
+			return
+		}
+		s.store(resource, namespace, newName, obj)
+		writeJSON(w, http.StatusCreated, obj)
+	case r.Method == http.MethodDelete && name != "":
+		obj, ok := collection[name]
+		if !ok {
+			writeStatus(w, apierrors.NewNotFound(gr, name))
+			return
+		}
+		delete(collection, name)
+		writeJSON(w, http.StatusOK, obj)
+	default:
+		writeStatus(w, apierrors.NewMethodNotSupported(gr, r.Method))
+	}
+}
+
+// parsePath splits /api/v1/[namespaces/<ns>/]<resource>[/<name>] and the /apis equivalent
+func parsePath(path string) (resource, namespace, name string, ok bool) {
+	var rest string
+	switch {
+	case strings.HasPrefix(path, "/api/v1/"):
+		rest = strings.TrimPrefix(path, "/api/v1/")
+	case strings.HasPrefix(path, "/apis/"):
+		parts := strings.SplitN(strings.TrimPrefix(path, "/apis/"), "/", 3)
+		if len(parts) < 3 {
+			return "", "", "", false
+		}
+		rest = parts[2]
+	default:
+		return "", "", "", false
+	}
+
+	segs := strings.Split(rest, "/")
+	if len(segs) >= 3 && segs[0] == "namespaces" {
+		namespace, segs = segs[1], segs[2:]
+	}
+	switch len(segs) {
+	case 1:
+		resource = segs[0]
+	case 2:
+		resource, name = segs[0], segs[1]
+	default:
+		return "", "", "", false
+	}
+	_, ok = resourceKinds[resource]
+	return resource, namespace, name, ok
+}
+
+func isDryRun(r *http.Request) bool {
+	return len(r.URL.Query()["dryRun"]) > 0
+}
+
+// decodeObject reads a JSON or protobuf body into its JSON form
+func decodeObject(r *http.Request) (map[string]interface{}, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]interface{}
+	if json.Unmarshal(body, &obj) == nil {
+		return obj, nil
+	}
+	decoded, _, err := scheme.Codecs.UniversalDeserializer().Decode(body, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(decoded)
+	if err != nil {
+		return nil, err
+	}
+	return obj, json.Unmarshal(data, &obj)
+}
+
+func (s *Server) serveTokenReview(w http.ResponseWriter, r *http.Request) {
+	var review authnv1.TokenReview
+	if err := decodeInto(r, &review); err != nil {
+		writeStatus(w, apierrors.NewBadRequest(err.Error()))
+		return
+	}
+	s.mu.Lock()
+	fn := s.tokenReview
+	s.mu.Unlock()
+	if fn != nil {
+		fn(&review)
+	}
+	review.Kind, review.APIVersion = "TokenReview", "authentication.k8s.io/v1"
+	writeJSON(w, http.StatusCreated, review)
+}
+
+func (s *Server) serveSubjectAccessReview(w http.ResponseWriter, r *http.Request) {
+	var review authzv1.SubjectAccessReview
+	if err := decodeInto(r, &review); err != nil {
+		writeStatus(w, apierrors.NewBadRequest(err.Error()))
+		return
+	}
+	s.mu.Lock()
+	fn := s.subjectAccessReview
+	s.mu.Unlock()
+	review.Status.Allowed = fn == nil || fn(&review)
+	review.Kind, review.APIVersion = "SubjectAccessReview", "authorization.k8s.io/v1"
+	writeJSON(w, http.StatusCreated, review)
+}
+
+// decodeInto reads a JSON or protobuf body into a typed object
+func decodeInto(r *http.Request, into interface{}) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if json.Unmarshal(body, into) == nil {
+		return nil
+	}
+	decoded, _, err := scheme.Codecs.UniversalDeserializer().Decode(body, nil, nil)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(decoded)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, into)
+}
+
+func apiGroups() metav1.APIGroupList {
+	list := metav1.APIGroupList{TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"}}
+	for _, gv := range []schema.GroupVersion{{Group: "apps", Version: "v1"}, {Group: "example.com", Version: "v1alpha1"}} {
+		version := metav1.GroupVersionForDiscovery{GroupVersion: gv.String(), Version: gv.Version}
+		list.Groups = append(list.Groups, metav1.APIGroup{Name: gv.Group, Versions: []metav1.GroupVersionForDiscovery{version}, PreferredVersion: version})
+	}
+	return list
+}
+
+func apiResources(groupVersion string) metav1.APIResourceList {
+	list := metav1.APIResourceList{TypeMeta: metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"}, GroupVersion: groupVersion}
+	names := make([]string, 0, len(resourceKinds))
+	for resource := range resourceKinds {
+		names = append(names, resource)
+	}
+	sort.Strings(names)
+	for _, resource := range names {
+		kind := resourceKinds[resource]
+		if kind.gv.String() != groupVersion {
+			continue
+		}
+		list.APIResources = append(list.APIResources, metav1.APIResource{
+			Name:       resource,
+			Namespaced: kind.namespaced,
+			Kind:       kind.kind,
+			Verbs:      metav1.Verbs{"create", "delete", "get", "list"},
+		})
+	}
+	return list
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeStatus(w http.ResponseWriter, err *apierrors.StatusError) {
+	status := err.Status()
+	status.Kind, status.APIVersion = "Status", "v1"
+	writeJSON(w, int(status.Code), status)
+}
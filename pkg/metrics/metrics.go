@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "spicedb_proxy_integration"
+
+// Request outcomes used as the "outcome" label
+const (
+	OutcomeSuccess         = "success"
+	OutcomeUnauthenticated = "unauthenticated"
+	OutcomeDenied          = "denied"
+	OutcomeThrottled       = "throttled"
+	OutcomeError           = "error"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP API requests by endpoint and outcome.",
+	}, []string{"endpoint", "outcome"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP API handler latency by endpoint.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint"})
+
+	permissionDeniedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "permission_denied_total",
+		Help:      "HTTP API requests rejected with a permission denial, by endpoint.",
+	}, []string{"endpoint"})
+
+	authenticationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "authentications_total",
+		Help:      "Authentication attempts by method and outcome.",
+	}, []string{"method", "outcome"})
+
+	spicedbCallsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spicedb_calls_total",
+		Help:      "SpiceDB calls by operation and outcome.",
+	}, []string{"operation", "outcome"})
+
+	spicedbCallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "spicedb_call_duration_seconds",
+		Help:      "SpiceDB call latency by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	spicedbChecksInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "spicedb_checks_in_flight",
+		Help:      "SpiceDB permission checks currently running, bounded by the check concurrency limit.",
+	})
+
+	spicedbCheckTimeoutsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spicedb_check_timeouts_total",
+		Help:      "SpiceDB permission checks cut off by the check timeout, by operation.",
+	}, []string{"operation"})
+
+	spicedbCheckConcurrencyLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "spicedb_check_concurrency_limit",
+		Help:      "Maximum number of SpiceDB permission checks run at once.",
+	})
+
+	reconcileRunsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_runs_total",
+		Help:      "Orphaned relationship reconciler runs by outcome.",
+	}, []string{"outcome"})
+
+	reconcileOrphansTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_orphaned_objects_total",
+		Help:      "Objects with SpiceDB relationships but no Kubernetes object, by resource type. Dry runs count an object on every run.",
+	}, []string{"resource_type"})
+
+	reconcilePrunedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_pruned_relationships_total",
+		Help:      "SpiceDB relationships deleted by the reconciler because their Kubernetes object is gone, by resource type.",
+	}, []string{"resource_type"})
+)
+
+// Handler returns the HTTP handler serving metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// OutcomeForStatus maps an HTTP status code to a request outcome
+func OutcomeForStatus(status int) string {
+	switch {
+	case status < 400:
+		return OutcomeSuccess
+	case status == http.StatusUnauthorized:
+		return OutcomeUnauthenticated
+	case status == http.StatusForbidden:
+		return OutcomeDenied
+	case status == http.StatusTooManyRequests:
+		return OutcomeThrottled
+	default:
+		return OutcomeError
+	}
+}
+
+// ObserveRequest records a completed HTTP API request
+func ObserveRequest(endpoint string, status int, duration time.Duration) {
+	outcome := OutcomeForStatus(status)
+	requestsTotal.WithLabelValues(endpoint, outcome).Inc()
+	requestDuration.WithLabelValues(endpoint).Observe(duration.Seconds())
+	if outcome == OutcomeDenied {
+		permissionDeniedTotal.WithLabelValues(endpoint).Inc()
+	}
+}
+
+// RecordAuthentication records an authentication attempt for the given method
+func RecordAuthentication(method string, authenticated bool) {
+	outcome := OutcomeSuccess
+	if !authenticated {
+		outcome = OutcomeUnauthenticated
+	}
+	authenticationsTotal.WithLabelValues(method, outcome).Inc()
+}
+
+// RecordReconcileRun records a completed run of the orphaned relationship reconciler
+func RecordReconcileRun(err error) {
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeError
+	}
+	reconcileRunsTotal.WithLabelValues(outcome).Inc()
+}
+
+// RecordReconcileOrphans records confirmed orphaned objects of a resource type and the relationships pruned for them
+func RecordReconcileOrphans(resourceType string, objects int, pruned uint64) {
+	reconcileOrphansTotal.WithLabelValues(resourceType).Add(float64(objects))
+	reconcilePrunedTotal.WithLabelValues(resourceType).Add(float64(pruned))
+}
+
+// SetSpiceDBCheckConcurrencyLimit records the configured bound on concurrent SpiceDB permission checks
+func SetSpiceDBCheckConcurrencyLimit(limit int) {
+	spicedbCheckConcurrencyLimit.Set(float64(limit))
+}
+
+// SpiceDBCheckStarted records a SpiceDB permission check taking a concurrency slot
+func SpiceDBCheckStarted() {
+	spicedbChecksInFlight.Inc()
+}
+
+// SpiceDBCheckFinished records a SpiceDB permission check releasing its concurrency slot
+func SpiceDBCheckFinished() {
+	spicedbChecksInFlight.Dec()
+}
+
+// RecordSpiceDBCheckTimeout records a SpiceDB permission check cut off by the check timeout
+func RecordSpiceDBCheckTimeout(operation string) {
+	spicedbCheckTimeoutsTotal.WithLabelValues(operation).Inc()
+}
+
+// ObserveSpiceDBCall records a SpiceDB call that started at start and finished with err
+func ObserveSpiceDBCall(operation string, start time.Time, err error) {
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeError
+	}
+	spicedbCallsTotal.WithLabelValues(operation, outcome).Inc()
+	spicedbCallDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/metrics"
+)
+
+// DefaultCheckConcurrency bounds concurrent SpiceDB permission checks when no limit is
+// configured. The embedded SpiceDB evaluates checks on this host, so the bound scales with it.
+func DefaultCheckConcurrency() int {
+	return 4 * runtime.NumCPU()
+}
+
+// WithCheckConcurrency bounds how many SpiceDB permission checks, made by the proxy itself or
+// by the embedded proxy while authorizing Kubernetes requests, run at once. Further checks
+// wait for a slot until their context ends. Zero or less keeps DefaultCheckConcurrency.
+func WithCheckConcurrency(limit int) Option {
+	return func(o *options) {
+		o.checkConcurrency = limit
+	}
+}
+
+// checkLimiter is a counting semaphore shared by every permissions client of a proxy
+type checkLimiter chan struct{}
+
+func newCheckLimiter(limit int) checkLimiter {
+	if limit <= 0 {
+		limit = DefaultCheckConcurrency()
+	}
+	metrics.SetSpiceDBCheckConcurrencyLimit(limit)
+	return make(checkLimiter, limit)
+}
+
+// acquire waits for a free slot, failing with the gRPC status of ctx's error if ctx ends first
+func (l checkLimiter) acquire(ctx context.Context) error {
+	select {
+	case l <- struct{}{}:
+		metrics.SpiceDBCheckStarted()
+		return nil
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (l checkLimiter) release() {
+	<-l
+	metrics.SpiceDBCheckFinished()
+}
+
+// limitedPermissionsClient runs CheckPermission and CheckBulkPermissions calls within the
+// limiter and the check timeout, which covers waiting for a slot; other calls pass straight through
+type limitedPermissionsClient struct {
+	v1.PermissionsServiceClient
+	limiter  checkLimiter
+	timeout  time.Duration
+	watchers *checkTimeoutWatchers
+}
+
+func (c limitedPermissionsClient) CheckPermission(ctx context.Context, in *v1.CheckPermissionRequest, opts ...grpc.CallOption) (*v1.CheckPermissionResponse, error) {
+	ctx, done := withCheckDeadline(ctx, c.timeout, "check_permission", c.watchers, func() []string {
+		return []string{in.GetSubject().GetObject().GetObjectId()}
+	})
+	if err := c.limiter.acquire(ctx); err != nil {
+		return nil, done(err)
+	}
+	defer c.limiter.release()
+	resp, err := c.PermissionsServiceClient.CheckPermission(ctx, in, opts...)
+	return resp, done(err)
+}
+
+func (c limitedPermissionsClient) CheckBulkPermissions(ctx context.Context, in *v1.CheckBulkPermissionsRequest, opts ...grpc.CallOption) (*v1.CheckBulkPermissionsResponse, error) {
+	ctx, done := withCheckDeadline(ctx, c.timeout, "check_bulk_permissions", c.watchers, func() []string {
+		subjects := make([]string, 0, len(in.GetItems()))
+		for _, item := range in.GetItems() {
+			subjects = append(subjects, item.GetSubject().GetObject().GetObjectId())
+		}
+		return subjects
+	})
+	if err := c.limiter.acquire(ctx); err != nil {
+		return nil, done(err)
+	}
+	defer c.limiter.release()
+	resp, err := c.PermissionsServiceClient.CheckBulkPermissions(ctx, in, opts...)
+	return resp, done(err)
+}
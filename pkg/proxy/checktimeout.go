@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/metrics"
+)
+
+// ErrAuthorizationTimeout is returned when a SpiceDB permission check outlives
+// Timeouts.SpiceDBCheck, so a slow SpiceDB is reported as such rather than as a denial
+var ErrAuthorizationTimeout = errors.New("authorization timeout")
+
+// checkTimeoutError is the error of a check cut off by Timeouts.SpiceDBCheck. It carries a
+// DeadlineExceeded gRPC status for the embedded proxy and matches ErrAuthorizationTimeout.
+type checkTimeoutError struct {
+	timeout time.Duration
+}
+
+func (e checkTimeoutError) Error() string {
+	return fmt.Sprintf("%v: SpiceDB permission check did not finish within %s", ErrAuthorizationTimeout, e.timeout)
+}
+
+func (e checkTimeoutError) Is(target error) bool {
+	return target == ErrAuthorizationTimeout
+}
+
+func (e checkTimeoutError) GRPCStatus() *status.Status {
+	return status.New(codes.DeadlineExceeded, e.Error())
+}
+
+// withCheckDeadline bounds a permission check by timeout, derived from ctx so the caller's own
+// deadline still applies. done maps the check's error to checkTimeoutError when it was this
+// deadline, not the caller's, that ended it, records the timeout and tells the watchers of
+// the checked subjects.
+func withCheckDeadline(ctx context.Context, timeout time.Duration, operation string, watchers *checkTimeoutWatchers, subjects func() []string) (context.Context, func(error) error) {
+	if timeout <= 0 {
+		return ctx, func(err error) error { return err }
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	return checkCtx, func(err error) error {
+		defer cancel()
+		if err == nil || ctx.Err() != nil || !errors.Is(checkCtx.Err(), context.DeadlineExceeded) {
+			return err
+		}
+		metrics.RecordSpiceDBCheckTimeout(operation)
+		if watchers != nil {
+			for _, subject := range subjects() {
+				watchers.notify(subject)
+			}
+		}
+		return checkTimeoutError{timeout: timeout}
+	}
+}
+
+// checkTimeoutWatchers tracks the requests sent through the embedded proxy by user subject ID.
+// The embedded proxy runs its checks on a context of its own, so a check timeout is matched
+// to the requests in flight for the subject it checked instead.
+type checkTimeoutWatchers struct {
+	mu       sync.Mutex
+	subjects map[string]map[*atomic.Bool]struct{}
+}
+
+func newCheckTimeoutWatchers() *checkTimeoutWatchers {
+	return &checkTimeoutWatchers{subjects: make(map[string]map[*atomic.Bool]struct{})}
+}
+
+// watch returns a flag set when a check of subjectID times out, until stop is called
+func (w *checkTimeoutWatchers) watch(subjectID string) (timedOut *atomic.Bool, stop func()) {
+	timedOut = new(atomic.Bool)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.subjects[subjectID] == nil {
+		w.subjects[subjectID] = make(map[*atomic.Bool]struct{})
+	}
+	w.subjects[subjectID][timedOut] = struct{}{}
+	return timedOut, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.subjects[subjectID], timedOut)
+		if len(w.subjects[subjectID]) == 0 {
+			delete(w.subjects, subjectID)
+		}
+	}
+}
+
+// notify sets the flag of every request in flight for subjectID
+func (w *checkTimeoutWatchers) notify(subjectID string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for timedOut := range w.subjects[subjectID] {
+		timedOut.Store(true)
+	}
+}
+
+// checkTimeoutTransport sends a user's requests to the embedded proxy, which answers any
+// failed check with 401 Unauthorized. When a check of the user timed out while the request
+// was in flight, that response is replaced by checkTimeoutError so callers can tell it from
+// a denial. A timed-out check of a concurrent request by the same user is indistinguishable,
+// so a denial racing it is also reported as a timeout, which a retry then settles.
+type checkTimeoutTransport struct {
+	base      http.RoundTripper
+	watchers  *checkTimeoutWatchers
+	subjectID string
+	timeout   time.Duration
+}
+
+func (t checkTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timedOut, stop := t.watchers.watch(t.subjectID)
+	defer stop()
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !timedOut.Load() {
+		return resp, err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return nil, checkTimeoutError{timeout: t.timeout}
+}
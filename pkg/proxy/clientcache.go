@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultClientCacheIdleTTL = 5 * time.Minute
+	defaultClientCacheMaxSize = 256
+)
+
+// clientCache reuses per-user Kubernetes clientsets, evicting entries that have been idle
+// longer than idleTTL and the least recently used entry when full. Each clientset wraps an
+// embedded client bound to one identity, so entries are keyed by username and groups.
+type clientCache struct {
+	mu      sync.Mutex
+	idleTTL time.Duration
+	maxSize int
+	entries map[string]*list.Element
+	// order holds entries from most to least recently used
+	order *list.List
+}
+
+type clientCacheEntry struct {
+	key      string
+	client   *kubernetes.Clientset
+	lastUsed time.Time
+}
+
+// WithClientCache reuses per-user Kubernetes clients, dropping clients idle for longer than
+// idleTTL and keeping at most maxSize. Zero values select the defaults (5m, 256 clients).
+func WithClientCache(idleTTL time.Duration, maxSize int) Option {
+	return func(o *options) {
+		o.clientCache = newClientCache(idleTTL, maxSize)
+	}
+}
+
+func newClientCache(idleTTL time.Duration, maxSize int) *clientCache {
+	if idleTTL <= 0 {
+		idleTTL = defaultClientCacheIdleTTL
+	}
+	if maxSize <= 0 {
+		maxSize = defaultClientCacheMaxSize
+	}
+	return &clientCache{
+		idleTTL: idleTTL,
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// clientCacheKey builds the cache key for an identity. Groups are sorted so that
+// group ordering does not affect the key.
+func clientCacheKey(username string, groups []string) string {
+	sorted := append([]string(nil), groups...)
+	sort.Strings(sorted)
+
+	// NUL separators cannot appear in user or group names, so fields cannot run into each other
+	return username + "\x00" + strings.Join(sorted, "\x00")
+}
+
+// get returns the cached client for key, if present and not idle past the TTL
+func (c *clientCache) get(key string) (*kubernetes.Clientset, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	now := time.Now()
+	entry := elem.Value.(*clientCacheEntry)
+	if now.Sub(entry.lastUsed) > c.idleTTL {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	entry.lastUsed = now
+	c.order.MoveToFront(elem)
+	return entry.client, true
+}
+
+// add stores client under key and returns the cached client, which is the existing one
+// when another caller stored a client for the same key first
+func (c *clientCache) add(key string, client *kubernetes.Clientset) *kubernetes.Clientset {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*clientCacheEntry)
+		entry.lastUsed = now
+		c.order.MoveToFront(elem)
+		return entry.client
+	}
+
+	c.entries[key] = c.order.PushFront(&clientCacheEntry{key: key, client: client, lastUsed: now})
+
+	// Drop idle entries from the back, then enforce the size bound
+	for oldest := c.order.Back(); oldest != nil; oldest = c.order.Back() {
+		entry := oldest.Value.(*clientCacheEntry)
+		if c.order.Len() <= c.maxSize && now.Sub(entry.lastUsed) <= c.idleTTL {
+			break
+		}
+		c.order.Remove(oldest)
+		delete(c.entries, entry.key)
+	}
+	return client
+}
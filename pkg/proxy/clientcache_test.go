@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// withTestClientCache enables a client cache holding up to maxSize clients, or the default
+// when zero, on the shared proxy for the duration of a test
+func withTestClientCache(tb testing.TB, maxSize int) {
+	tb.Helper()
+	testProxy.clientCache = newClientCache(0, maxSize)
+	tb.Cleanup(func() { testProxy.clientCache = nil })
+}
+
+func BenchmarkGetKubernetesClientForUser(b *testing.B) {
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := testProxy.GetKubernetesClientForUser("bench-user", "bench-group"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		withTestClientCache(b, 0)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := testProxy.GetKubernetesClientForUser("bench-user", "bench-group"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestClientCacheAvoidsRebuildingClients(t *testing.T) {
+	get := func() {
+		if _, err := testProxy.GetKubernetesClientForUser("alloc-user", "alloc-group"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	uncached := testing.AllocsPerRun(20, get)
+
+	withTestClientCache(t, 0)
+	first, err := testProxy.GetKubernetesClientForUser("alloc-user", "alloc-group", "alloc-team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Group order does not change the identity, so the same client is returned
+	second, err := testProxy.GetKubernetesClientForUser("alloc-user", "alloc-team", "alloc-group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("repeated calls for the same user returned different clients")
+	}
+
+	if cached := testing.AllocsPerRun(20, get); cached >= uncached {
+		t.Errorf("cached calls allocate %.0f times, uncached %.0f; want fewer", cached, uncached)
+	}
+}
+
+// TestClientCacheConcurrentUse is meant for go test -race: many goroutines fetch clients for
+// more users than the cache holds, so lookups, inserts and evictions all contend
+func TestClientCacheConcurrentUse(t *testing.T) {
+	const (
+		users      = 16
+		goroutines = 64
+		calls      = 20
+		maxSize    = 4
+	)
+	withTestClientCache(t, maxSize)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < calls; i++ {
+				user := fmt.Sprintf("race-user-%d", (g+i)%users)
+				client, err := testProxy.GetKubernetesClientForUser(user, "race-group")
+				if err != nil {
+					errs <- err
+					return
+				}
+				if client == nil {
+					errs <- fmt.Errorf("nil client for %s", user)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	cache := testProxy.clientCache
+	cache.mu.Lock()
+	entries, order := len(cache.entries), cache.order.Len()
+	cache.mu.Unlock()
+	if entries > maxSize || entries != order {
+		t.Errorf("cache holds %d entries in its map and %d in its order, want the same and at most %d", entries, order, maxSize)
+	}
+
+	// Callers racing to build a client for one identity all end up sharing one
+	clients := make([]*kubernetes.Clientset, goroutines)
+	for g := range clients {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			clients[g], _ = testProxy.GetKubernetesClientForUser("race-shared", "race-group")
+		}(g)
+	}
+	wg.Wait()
+	for g, client := range clients {
+		if client == nil || client != clients[0] {
+			t.Fatalf("goroutine %d got client %p, want the shared %p", g, client, clients[0])
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/metrics"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/requestid"
+)
+
+// ClusterObjectID is the ID of the SpiceDB cluster object every namespace created through the proxy is linked to
+const ClusterObjectID = "default"
+
+// GrantClusterAdmin makes user an admin of the cluster, which grants admin, edit and view on
+// every namespace linked to it. Granting an existing cluster admin is not an error.
+func (c *SpiceDBKubeProxy) GrantClusterAdmin(ctx context.Context, user string) error {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBWrite)
+	defer cancel()
+
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return fmt.Errorf("SpiceDB client not available")
+	}
+
+	// Touch relationship: cluster:default#admin@user:user
+	start := time.Now()
+	_, err := client.WriteRelationships(requestid.OutgoingContext(ctx), &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{
+			{
+				Operation: v1.RelationshipUpdate_OPERATION_TOUCH,
+				Relationship: &v1.Relationship{
+					Resource: &v1.ObjectReference{
+						ObjectType: "cluster",
+						ObjectId:   ClusterObjectID,
+					},
+					Relation: "admin",
+					Subject: &v1.SubjectReference{
+						Object: &v1.ObjectReference{
+							ObjectType: "user",
+							ObjectId:   user,
+						},
+					},
+				},
+			},
+		},
+	})
+	metrics.ObserveSpiceDBCall("write_relationships", start, err)
+
+	return err
+}
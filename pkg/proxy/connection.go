@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/tracing"
+)
+
+// spicedbServiceConfig retries idempotent SpiceDB reads that fail with UNAVAILABLE while the
+// connection is being re-established. Writes are not retried, since a write that failed
+// mid-flight may already have been applied.
+const spicedbServiceConfig = `{
+  "methodConfig": [{
+    "name": [
+      {"service": "authzed.api.v1.PermissionsService", "method": "CheckPermission"},
+      {"service": "authzed.api.v1.PermissionsService", "method": "CheckBulkPermissions"},
+      {"service": "authzed.api.v1.PermissionsService", "method": "ReadRelationships"},
+      {"service": "authzed.api.v1.PermissionsService", "method": "LookupResources"},
+      {"service": "authzed.api.v1.PermissionsService", "method": "LookupSubjects"},
+      {"service": "authzed.api.v1.SchemaService", "method": "ReadSchema"}
+    ],
+    "retryPolicy": {
+      "maxAttempts": 4,
+      "initialBackoff": "0.1s",
+      "maxBackoff": "1s",
+      "backoffMultiplier": 2,
+      "retryableStatusCodes": ["UNAVAILABLE"]
+    }
+  }]
+}`
+
+// spicedbDialOptions configure the proxy's own connection to the embedded SpiceDB
+func spicedbDialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		tracing.GRPCDialOption(),
+		// Calls made while the connection is down wait for it to come back, bounded by their
+		// timeouts, rather than failing immediately
+		grpc.WithDefaultCallOptions(grpc.WaitForReady(true)),
+		grpc.WithDefaultServiceConfig(spicedbServiceConfig),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff: backoff.Config{
+				BaseDelay:  100 * time.Millisecond,
+				Multiplier: 1.6,
+				Jitter:     0.2,
+				MaxDelay:   5 * time.Second,
+			},
+			MinConnectTimeout: 5 * time.Second,
+		}),
+	}
+}
+
+// SpiceDBConnectionState reports the state of the connection to SpiceDB, such as READY
+// or TRANSIENT_FAILURE while it is being re-established
+func (c *SpiceDBKubeProxy) SpiceDBConnectionState() connectivity.State {
+	return c.schemaConn.GetState()
+}
+
+// monitorConnection logs SpiceDB connection failures and recoveries until ctx ends, and
+// reconnects an idle connection right away so the next call does not wait for the dial
+func (c *SpiceDBKubeProxy) monitorConnection(ctx context.Context) {
+	state := c.schemaConn.GetState()
+	lost := false
+	for {
+		if state == connectivity.Idle {
+			c.schemaConn.Connect()
+		}
+		if !c.schemaConn.WaitForStateChange(ctx, state) {
+			return
+		}
+
+		state = c.schemaConn.GetState()
+		switch state {
+		case connectivity.Shutdown:
+			return
+		case connectivity.TransientFailure:
+			if !lost {
+				c.logger.Warn("SpiceDB connection lost, reconnecting")
+				lost = true
+			}
+		case connectivity.Ready:
+			if lost {
+				c.logger.Info("SpiceDB connection re-established")
+				lost = false
+			}
+		}
+	}
+}
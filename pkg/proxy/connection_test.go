@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// allowingPermissionsServer answers every check with HAS_PERMISSION
+type allowingPermissionsServer struct {
+	v1.UnimplementedPermissionsServiceServer
+}
+
+func (allowingPermissionsServer) CheckPermission(context.Context, *v1.CheckPermissionRequest) (*v1.CheckPermissionResponse, error) {
+	return &v1.CheckPermissionResponse{Permissionship: v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION}, nil
+}
+
+// serveSpiceDB serves allowingPermissionsServer on addr until the returned server is stopped
+func serveSpiceDB(t *testing.T, addr string) (*grpc.Server, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("listen on %s: %v", addr, err)
+	}
+	srv := grpc.NewServer()
+	v1.RegisterPermissionsServiceServer(srv, allowingPermissionsServer{})
+	go srv.Serve(lis)
+	return srv, lis.Addr().String()
+}
+
+func TestSpiceDBReconnectsAfterDroppedConnection(t *testing.T) {
+	srv, addr := serveSpiceDB(t, "127.0.0.1:0")
+
+	conn, err := grpc.NewClient(addr, spicedbDialOptions()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	p := &SpiceDBKubeProxy{
+		spicedbClient: v1.NewPermissionsServiceClient(conn),
+		schemaConn:    conn,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		timeouts:      DefaultTimeouts(),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.monitorConnection(ctx)
+
+	check := func(timeout time.Duration) error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		_, err := p.CheckPermission(ctx, "namespace", "default", "view", "user", "alice", nil)
+		return err
+	}
+	if err := check(5 * time.Second); err != nil {
+		t.Fatalf("check before the drop: %v", err)
+	}
+
+	// Drop the connection, as a SpiceDB restart would
+	srv.Stop()
+	waitForState(t, p, func(s connectivity.State) bool { return s != connectivity.Ready })
+	if err := check(200 * time.Millisecond); err == nil {
+		t.Fatal("check succeeded while SpiceDB was down")
+	}
+
+	srv, _ = serveSpiceDB(t, addr)
+	defer srv.Stop()
+
+	// The next call waits for the connection to come back instead of failing
+	if err := check(10 * time.Second); err != nil {
+		t.Fatalf("check after SpiceDB came back: %v", err)
+	}
+	if state := p.SpiceDBConnectionState(); state != connectivity.Ready {
+		t.Errorf("connection state after recovery = %s, want READY", state)
+	}
+}
+
+// waitForState polls the proxy's SpiceDB connection state until done accepts it
+func waitForState(t *testing.T, p *SpiceDBKubeProxy, done func(connectivity.State) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !done(p.SpiceDBConnectionState()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("connection state stuck at %s", p.SpiceDBConnectionState())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/metrics"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/requestid"
+)
+
+const (
+	// importBatchSize bounds the relationships created by one WriteRelationships call during an
+	// import, keeping each call within SpiceDB's default update limit
+	importBatchSize = 500
+	// maxImportLineBytes bounds a single line of an import
+	maxImportLineBytes = 1 << 20
+)
+
+// ErrInvalidImport is returned by ImportRelationships when a line is not a valid relationship
+var ErrInvalidImport = errors.New("invalid import")
+
+// exportExcludedResourceTypes are the embedded proxy's dual-write bookkeeping. They only
+// matter while a request is in flight, and restoring them could leave stale locks behind.
+var exportExcludedResourceTypes = map[string]bool{"workflow": true, "activity": true, "lock": true}
+
+// RelationshipExport is a snapshot of every relationship in SpiceDB, read page by page as it
+// is ranged over so the graph is never held in memory
+type RelationshipExport struct {
+	// Revision is the ZedToken the whole snapshot is read at
+	Revision string
+
+	proxy         *SpiceDBKubeProxy
+	resourceTypes []string
+}
+
+// ExportRelationships starts an export of the relationships of every definition in the
+// schema, except the embedded proxy's bookkeeping. Every page is read at exactly the revision
+// the schema was read at, so the export is a consistent point-in-time snapshot: writes made
+// while it runs are not included. SpiceDB only keeps old revisions for its garbage collection
+// window, so an export that runs longer than that fails instead of returning a mixed view.
+func (c *SpiceDBKubeProxy) ExportRelationships(ctx context.Context) (*RelationshipExport, error) {
+	if c.GetSpiceDBClient() == nil {
+		return nil, fmt.Errorf("SpiceDB client not available")
+	}
+
+	schema, err := c.GetSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := compileSchemaText(schema.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile SpiceDB schema: %w", err)
+	}
+
+	export := &RelationshipExport{Revision: schema.Revision, proxy: c}
+	for _, def := range compiled.ObjectDefinitions {
+		if !exportExcludedResourceTypes[def.GetName()] {
+			export.resourceTypes = append(export.resourceTypes, def.GetName())
+		}
+	}
+	return export, nil
+}
+
+// Range calls fn with each relationship of the export, grouped by resource type, and stops
+// at the first error fn or SpiceDB returns
+func (e *RelationshipExport) Range(ctx context.Context, fn func(Relationship) error) error {
+	consistency := &v1.Consistency{Requirement: &v1.Consistency_AtExactSnapshot{
+		AtExactSnapshot: &v1.ZedToken{Token: e.Revision},
+	}}
+	for _, resourceType := range e.resourceTypes {
+		q := RelationshipQuery{ResourceType: resourceType, Consistency: consistency}
+		cursor := ""
+		for {
+			readCtx, cancel := withTimeout(ctx, e.proxy.timeouts.SpiceDBRead)
+			relationships, next, err := e.proxy.readRelationshipsPage(readCtx, q, MaxRelationshipPageSize, cursor)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("failed to export %s relationships: %w", resourceType, err)
+			}
+			for _, rel := range relationships {
+				if err := fn(rel); err != nil {
+					return err
+				}
+			}
+			if len(relationships) < MaxRelationshipPageSize {
+				break
+			}
+			cursor = next
+		}
+	}
+	return nil
+}
+
+// RelationshipImport reports what ImportRelationships did
+type RelationshipImport struct {
+	// Imported counts the relationships created
+	Imported int `json:"imported"`
+	// Skipped counts the relationships that already existed, including repeats within the import
+	Skipped int `json:"skipped"`
+}
+
+// ImportRelationships creates the relationships read from r, one JSON Relationship per line
+// as written by an export, and skips those that already exist. Lines are written in batches
+// as they are read; a failure part way leaves the batches written so far in place, and since
+// existing relationships are skipped, running the import again finishes the job.
+func (c *SpiceDBKubeProxy) ImportRelationships(ctx context.Context, r io.Reader) (*RelationshipImport, error) {
+	if c.GetSpiceDBClient() == nil {
+		return nil, fmt.Errorf("SpiceDB client not available")
+	}
+
+	result := &RelationshipImport{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineBytes)
+
+	batch := make([]*v1.Relationship, 0, importBatchSize)
+	inBatch := make(map[string]bool, importBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		imported, skipped, err := c.importBatch(ctx, batch)
+		result.Imported += imported
+		result.Skipped += skipped
+		batch = batch[:0]
+		clear(inBatch)
+		// SpiceDB rejects relationships the schema does not allow before writing any of them
+		if code := status.Code(err); code == codes.InvalidArgument || code == codes.FailedPrecondition {
+			return fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		return err
+	}
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var rel Relationship
+		if err := json.Unmarshal(scanner.Bytes(), &rel); err != nil {
+			return result, fmt.Errorf("%w: line %d: %v", ErrInvalidImport, line, err)
+		}
+		if rel.ResourceType == "" || rel.ResourceID == "" || rel.Relation == "" || rel.SubjectType == "" || rel.SubjectID == "" {
+			return result, fmt.Errorf("%w: line %d: resourceType, resourceId, relation, subjectType and subjectId are required", ErrInvalidImport, line)
+		}
+		if exportExcludedResourceTypes[rel.ResourceType] {
+			return result, fmt.Errorf("%w: line %d: %s relationships belong to the embedded proxy and cannot be imported", ErrInvalidImport, line, rel.ResourceType)
+		}
+		proto, err := relationshipToProto(rel)
+		if err != nil {
+			return result, fmt.Errorf("%w: line %d: %v", ErrInvalidImport, line, err)
+		}
+
+		// SpiceDB rejects a write that touches the same relationship twice
+		if inBatch[rel.String()] {
+			result.Skipped++
+			continue
+		}
+		inBatch[rel.String()] = true
+		batch = append(batch, proto)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return result, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return result, fmt.Errorf("failed to read import: %w", err)
+	}
+	if err := flush(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// importBatch creates rels in one write. When any of them already exists the whole write
+// fails, so the batch is retried one relationship at a time to skip the existing ones.
+func (c *SpiceDBKubeProxy) importBatch(ctx context.Context, rels []*v1.Relationship) (imported, skipped int, err error) {
+	updates := make([]*v1.RelationshipUpdate, 0, len(rels))
+	for _, rel := range rels {
+		updates = append(updates, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+			Relationship: rel,
+		})
+	}
+
+	writeCtx, cancel := withTimeout(ctx, c.timeouts.SpiceDBWrite)
+	start := time.Now()
+	_, err = c.GetSpiceDBClient().WriteRelationships(requestid.OutgoingContext(writeCtx), &v1.WriteRelationshipsRequest{Updates: updates})
+	cancel()
+	if status.Code(err) == codes.AlreadyExists {
+		metrics.ObserveSpiceDBCall("write_relationships", start, nil)
+		return c.SeedRelationships(ctx, rels)
+	}
+	metrics.ObserveSpiceDBCall("write_relationships", start, err)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to import relationships: %w", err)
+	}
+	return len(rels), 0, nil
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// ComponentHealth is the health of one dependency of the proxy
+type ComponentHealth struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	// State is the connection state, reported for SpiceDB
+	State string `json:"state,omitempty"`
+}
+
+// HealthStatus is the structured result of ProxyHealth
+type HealthStatus struct {
+	OK         bool            `json:"ok"`
+	SpiceDB    ComponentHealth `json:"spicedb"`
+	Kubernetes ComponentHealth `json:"kubernetes"`
+	WorkflowDB ComponentHealth `json:"workflowDB"`
+}
+
+// ProxyHealth probes SpiceDB, the backend Kubernetes API and the workflow database.
+// It makes network calls, so callers should bound ctx.
+func (c *SpiceDBKubeProxy) ProxyHealth(ctx context.Context) HealthStatus {
+	spicedb := componentHealth(c.CheckReady(ctx))
+	spicedb.State = c.SpiceDBConnectionState().String()
+
+	status := HealthStatus{
+		SpiceDB:    spicedb,
+		Kubernetes: componentHealth(c.CheckBackendReachable(ctx)),
+		WorkflowDB: componentHealth(c.checkWorkflowDB()),
+	}
+	status.OK = status.SpiceDB.OK && status.Kubernetes.OK && status.WorkflowDB.OK
+	return status
+}
+
+func componentHealth(err error) ComponentHealth {
+	if err != nil {
+		return ComponentHealth{OK: false, Error: err.Error()}
+	}
+	return ComponentHealth{OK: true}
+}
+
+// CheckBackendReachable verifies the backend Kubernetes API answers a version request. It
+// uses the proxy's own credentials and bypasses the embedded proxy, so a failure points at
+// the link to the cluster rather than at SpiceDB.
+func (c *SpiceDBKubeProxy) CheckBackendReachable(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+	defer cancel()
+
+	if err := c.kubeClient.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Error(); err != nil {
+		return fmt.Errorf("kubernetes API unreachable: %w", err)
+	}
+	return nil
+}
+
+// checkWorkflowDB verifies the embedded proxy is running and its workflow database file
+// exists. An in-memory database lives as long as the proxy.
+func (c *SpiceDBKubeProxy) checkWorkflowDB() error {
+	select {
+	case <-c.stopped:
+		return fmt.Errorf("embedded proxy has stopped")
+	default:
+	}
+
+	if c.workflowDBPath == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(c.workflowDBPath); err != nil {
+		return fmt.Errorf("workflow database unavailable: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/metrics"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/requestid"
+)
+
+// namespaceFetchConcurrency bounds parallel Kubernetes reads in ListNamespacesWithPermission
+const namespaceFetchConcurrency = 8
+
+// DefaultListPermission is the namespace permission listings filter by when none is given
+const DefaultListPermission = "view"
+
+const (
+	// DefaultUserPermissionsLimit is the number of resources looked up per permission when a query sets no limit
+	DefaultUserPermissionsLimit = 100
+	// MaxUserPermissionsLimit bounds the resources looked up per permission
+	MaxUserPermissionsLimit = 1000
+)
+
+// MaxLookupNamespaces bounds the namespaces ListNamespacesWithPermission looks up for a user;
+// users holding more should page through ListNamespacesAsUser instead. It stays below the
+// 1000 results SpiceDB returns from one LookupResources call.
+const MaxLookupNamespaces = 500
+
+// ErrTooManyNamespaces is returned when a user holds a permission on more than MaxLookupNamespaces namespaces
+var ErrTooManyNamespaces = errors.New("too many namespaces to look up")
+
+// ErrUnknownPermission is returned when a lookup names a resource type or permission the schema does not define
+var ErrUnknownPermission = errors.New("unknown resource type or permission")
+
+// DefaultUserPermissions returns the permissions UserPermissions checks when a query names none
+func DefaultUserPermissions() map[string][]string {
+	return map[string][]string{
+		"namespace": {"admin", "edit", "view"},
+		"pod":       {"edit", "view"},
+	}
+}
+
+// UserPermissionsQuery selects the subject and permissions reported by UserPermissions
+type UserPermissionsQuery struct {
+	SubjectType string
+	SubjectID   string
+	// Permissions maps resource types to the permissions checked on them; empty selects DefaultUserPermissions
+	Permissions map[string][]string
+	// Limit bounds the resources returned per permission; zero selects DefaultUserPermissionsLimit
+	Limit uint32
+	// Consistency is the read consistency; nil uses SpiceDB's default
+	Consistency *v1.Consistency
+}
+
+// UserPermissions reports the subject's effective permissions as resource type -> resource ID ->
+// sorted permissions, with one LookupResources call per checked permission. Truncated is set
+// when any permission matched more than the limit, in which case its resources are incomplete.
+func (c *SpiceDBKubeProxy) UserPermissions(ctx context.Context, q UserPermissionsQuery) (permissions map[string]map[string][]string, truncated bool, err error) {
+	checks := q.Permissions
+	if len(checks) == 0 {
+		checks = DefaultUserPermissions()
+	}
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultUserPermissionsLimit
+	}
+	if limit > MaxUserPermissionsLimit {
+		limit = MaxUserPermissionsLimit
+	}
+
+	permissions = make(map[string]map[string][]string, len(checks))
+	for resourceType, perms := range checks {
+		resources := make(map[string][]string)
+		for _, permission := range perms {
+			// Ask for one more than the limit to learn whether the result was cut short
+			ids, err := c.lookupResources(ctx, resourceType, permission, q.SubjectType, q.SubjectID, q.Consistency, limit+1)
+			if err != nil {
+				if code := status.Code(err); code == codes.FailedPrecondition || code == codes.InvalidArgument {
+					return nil, false, fmt.Errorf("%w: %s#%s", ErrUnknownPermission, resourceType, permission)
+				}
+				return nil, false, err
+			}
+			if uint32(len(ids)) > limit {
+				ids, truncated = ids[:limit], true
+			}
+			for _, id := range ids {
+				resources[id] = append(resources[id], permission)
+			}
+		}
+		for _, granted := range resources {
+			sort.Strings(granted)
+		}
+		permissions[resourceType] = resources
+	}
+	return permissions, truncated, nil
+}
+
+// ListViewableNamespaces returns the sorted namespaces a user can view. Unlike ListNamespacesAsUser,
+// which lists every namespace and filters through the proxy, it asks SpiceDB for the user's
+// namespaces with LookupResources and only fetches those from Kubernetes, dropping any
+// that no longer exist. This is much cheaper when a user sees few namespaces in a large cluster.
+// A nil consistency uses SpiceDB's default. A non-nil selector additionally drops namespaces
+// whose labels do not match it.
+func (c *SpiceDBKubeProxy) ListViewableNamespaces(ctx context.Context, username string, consistency *v1.Consistency, selector labels.Selector) ([]string, error) {
+	return c.ListNamespacesWithPermission(ctx, username, DefaultListPermission, consistency, selector)
+}
+
+// ListNamespacesWithPermission is ListViewableNamespaces for any namespace permission, such
+// as edit or admin. A permission the schema does not define on namespaces returns
+// ErrUnknownPermission, and one held on more than MaxLookupNamespaces namespaces returns
+// ErrTooManyNamespaces.
+func (c *SpiceDBKubeProxy) ListNamespacesWithPermission(ctx context.Context, username, permission string, consistency *v1.Consistency, selector labels.Selector) ([]string, error) {
+	if selector == nil {
+		selector = labels.Everything()
+	}
+	if err := c.validateNamespacePermission(ctx, permission); err != nil {
+		return nil, err
+	}
+
+	// Ask for one more than the bound to learn whether it was exceeded
+	ids, err := c.lookupResources(ctx, "namespace", permission, "user", username, consistency, MaxLookupNamespaces+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > MaxLookupNamespaces {
+		return nil, fmt.Errorf("%w: %s holds %s on more than %d namespaces", ErrTooManyNamespaces, username, permission, MaxLookupNamespaces)
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		sem      = make(chan struct{}, namespaceFetchConcurrency)
+	)
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// SpiceDB already authorized these namespaces, so read them with the proxy's own client
+			getCtx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+			ns, err := c.kubeClient.CoreV1().Namespaces().Get(getCtx, id, metav1.GetOptions{})
+			cancel()
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				if selector.Matches(labels.Set(ns.Labels)) {
+					names = append(names, id)
+				}
+			case apierrors.IsNotFound(err):
+				// Stale relationship for a namespace deleted outside the proxy
+			case firstErr == nil:
+				firstErr = err
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// validateNamespacePermission checks that the schema defines permission, rather than a
+// relation, on namespaces. DefaultListPermission is checked against the schema by the list
+// rule at startup, so it skips the schema read.
+func (c *SpiceDBKubeProxy) validateNamespacePermission(ctx context.Context, permission string) error {
+	if permission == DefaultListPermission {
+		return nil
+	}
+
+	schema, err := c.GetSchema(ctx)
+	if err != nil {
+		return err
+	}
+	compiled, err := compileSchemaText(schema.Schema)
+	if err != nil {
+		return fmt.Errorf("failed to compile SpiceDB schema: %w", err)
+	}
+	for _, def := range compiled.ObjectDefinitions {
+		if def.GetName() != "namespace" {
+			continue
+		}
+		if rel := findRelation(def, permission); rel != nil && rel.GetUsersetRewrite() != nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: namespace#%s", ErrUnknownPermission, permission)
+}
+
+// lookupResources returns the IDs of resourceType objects on which the subject has permission,
+// at most limit of them unless limit is zero
+func (c *SpiceDBKubeProxy) lookupResources(ctx context.Context, resourceType, permission, subjectType, subjectID string, consistency *v1.Consistency, limit uint32) ([]string, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBRead)
+	defer cancel()
+
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return nil, fmt.Errorf("SpiceDB client not available")
+	}
+
+	start := time.Now()
+	stream, err := client.LookupResources(requestid.OutgoingContext(ctx), &v1.LookupResourcesRequest{
+		ResourceObjectType: resourceType,
+		Permission:         permission,
+		Subject: &v1.SubjectReference{
+			Object: &v1.ObjectReference{
+				ObjectType: subjectType,
+				ObjectId:   subjectID,
+			},
+		},
+		Consistency:   consistency,
+		OptionalLimit: limit,
+	})
+	if err != nil {
+		metrics.ObserveSpiceDBCall("lookup_resources", start, err)
+		return nil, err
+	}
+
+	var ids []string
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			metrics.ObserveSpiceDBCall("lookup_resources", start, err)
+			return nil, fmt.Errorf("failed to receive %s resources: %w", resourceType, err)
+		}
+		if msg.GetPermissionship() == v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_HAS_PERMISSION {
+			ids = append(ids, msg.GetResourceObjectId())
+		}
+	}
+	metrics.ObserveSpiceDBCall("lookup_resources", start, nil)
+	return ids, nil
+}
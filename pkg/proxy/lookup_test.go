@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+)
+
+const (
+	// benchNamespaces is the size of the cluster the namespace listing benchmarks run against
+	benchNamespaces = 5000
+	// benchViewable is how many of those namespaces the benchmark user can view
+	benchViewable = 10
+	benchUser     = "bench-viewer"
+)
+
+var benchClusterOnce sync.Once
+
+// setupBenchCluster fills the fake API with benchNamespaces namespaces, of which benchUser
+// views every benchNamespaces/benchViewable-th one
+func setupBenchCluster(b *testing.B) {
+	b.Helper()
+	benchClusterOnce.Do(func() {
+		for i := 0; i < benchNamespaces; i++ {
+			name := fmt.Sprintf("bench-%04d", i)
+			testKube.Add("namespaces", "", name, map[string]interface{}{})
+			if i%(benchNamespaces/benchViewable) == 0 {
+				if _, err := testProxy.GrantViewPermission(context.Background(), name, benchUser, time.Time{}); err != nil {
+					b.Fatalf("grant view on %s: %v", name, err)
+				}
+			}
+		}
+	})
+}
+
+// BenchmarkListNamespaces compares listing every namespace through the proxy prefilter with
+// looking the user's namespaces up in SpiceDB and fetching only those
+func BenchmarkListNamespaces(b *testing.B) {
+	setupBenchCluster(b)
+	ctx := context.Background()
+
+	b.Run("prefilter", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			names, _, err := testProxy.ListNamespacesAsUser(ctx, benchUser, nil, ListNamespacesOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(names) != benchViewable {
+				b.Fatalf("listed %d namespaces, want %d", len(names), benchViewable)
+			}
+		}
+	})
+
+	b.Run("lookup", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			names, err := testProxy.ListViewableNamespaces(ctx, benchUser, NewConsistency(true, ""), nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(names) != benchViewable {
+				b.Fatalf("listed %d namespaces, want %d", len(names), benchViewable)
+			}
+		}
+	})
+}
+
+func TestListViewableNamespacesMatchesPrefilter(t *testing.T) {
+	createNamespace(t, "fast-alice", "fast-a")
+	createNamespace(t, "fast-alice", "fast-b")
+	createNamespace(t, "fast-bob", "fast-c")
+	// A relationship left behind by a namespace deleted outside the proxy is dropped
+	if _, err := testProxy.GrantViewPermission(context.Background(), "fast-gone", "fast-alice", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	slow, _, err := testProxy.ListNamespacesAsUser(context.Background(), "fast-alice", nil, ListNamespacesOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fast, err := testProxy.ListViewableNamespaces(context.Background(), "fast-alice", NewConsistency(true, ""), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(slow) != "[fast-a fast-b]" || fmt.Sprint(fast) != fmt.Sprint(slow) {
+		t.Errorf("prefilter listed %v and lookup %v, want [fast-a fast-b] from both", slow, fast)
+	}
+}
+
+func TestListNamespacesAsUserWithPermission(t *testing.T) {
+	createNamespace(t, "perm-alice", "perm-a")
+	createNamespace(t, "perm-alice", "perm-b")
+	createNamespace(t, "perm-bob", "perm-c")
+	if _, err := testProxy.GrantViewPermission(context.Background(), "perm-c", "perm-alice", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for permission, want := range map[string]string{
+		"view":  "[perm-a perm-b perm-c]",
+		"edit":  "[perm-a perm-b]",
+		"admin": "[perm-a perm-b]",
+	} {
+		names, _, err := testProxy.ListNamespacesAsUser(context.Background(), "perm-alice", nil, ListNamespacesOptions{Permission: permission})
+		if err != nil {
+			t.Fatalf("list with %s: %v", permission, err)
+		}
+		if fmt.Sprint(names) != want {
+			t.Errorf("list with %s = %v, want %s", permission, names, want)
+		}
+	}
+}
+
+func TestNamespacesWithPermissionChecksInBatches(t *testing.T) {
+	createNamespace(t, "batch-alice", "batch-a")
+	createNamespace(t, "batch-alice", "batch-b")
+
+	// More names than one bulk check takes, with the held ones in different batches
+	names := make([]string, 0, 2*MaxBulkCheckItems+10)
+	for i := 0; i < cap(names); i++ {
+		switch i {
+		case 5:
+			names = append(names, "batch-a")
+		case MaxBulkCheckItems + 5:
+			names = append(names, "batch-b")
+		default:
+			names = append(names, fmt.Sprintf("batch-other-%d", i))
+		}
+	}
+	held, err := testProxy.namespacesWithPermission(context.Background(), "batch-alice", "edit", names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(held) != "[batch-a batch-b]" {
+		t.Errorf("held = %v, want [batch-a batch-b]", held)
+	}
+}
+
+func TestListNamespacesWithPermissionBounded(t *testing.T) {
+	const user = "bound-viewer"
+	var updates []*v1.RelationshipUpdate
+	for i := 0; i <= MaxLookupNamespaces; i++ {
+		updates = append(updates, &v1.RelationshipUpdate{
+			Operation: v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: &v1.Relationship{
+				Resource: &v1.ObjectReference{ObjectType: "namespace", ObjectId: fmt.Sprintf("bound-%04d", i)},
+				Relation: "viewer",
+				Subject:  &v1.SubjectReference{Object: &v1.ObjectReference{ObjectType: "user", ObjectId: user}},
+			},
+		})
+	}
+	// SpiceDB takes a limited number of updates per write
+	for start := 0; start < len(updates); start += 500 {
+		batch := updates[start:min(start+500, len(updates))]
+		if _, err := testProxy.GetSpiceDBClient().WriteRelationships(context.Background(), &v1.WriteRelationshipsRequest{Updates: batch}); err != nil {
+			t.Fatalf("write relationships: %v", err)
+		}
+	}
+
+	_, err := testProxy.ListViewableNamespaces(context.Background(), user, NewConsistency(true, ""), nil)
+	if !errors.Is(err, ErrTooManyNamespaces) {
+		t.Errorf("list for a user viewing %d namespaces = %v, want ErrTooManyNamespaces", MaxLookupNamespaces+1, err)
+	}
+}
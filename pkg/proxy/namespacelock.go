@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+)
+
+// namespaceLocks serializes work on the same namespace within this process. The zero value
+// is ready to use, and a namespace's entry is dropped once nobody holds or waits for it.
+type namespaceLocks struct {
+	mu    sync.Mutex
+	locks map[string]*namespaceLock
+}
+
+type namespaceLock struct {
+	held chan struct{}
+	refs int
+}
+
+// lock waits until the namespace is free or ctx ends, and returns the function releasing it
+func (l *namespaceLocks) lock(ctx context.Context, namespace string) (func(), error) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*namespaceLock)
+	}
+	entry, ok := l.locks[namespace]
+	if !ok {
+		entry = &namespaceLock{held: make(chan struct{}, 1)}
+		l.locks[namespace] = entry
+	}
+	entry.refs++
+	l.mu.Unlock()
+
+	select {
+	case entry.held <- struct{}{}:
+		return func() {
+			<-entry.held
+			l.release(namespace, entry)
+		}, nil
+	case <-ctx.Done():
+		l.release(namespace, entry)
+		return nil, ctx.Err()
+	}
+}
+
+func (l *namespaceLocks) release(namespace string, entry *namespaceLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry.refs--
+	if entry.refs == 0 {
+		delete(l.locks, namespace)
+	}
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"log/slog"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/audit"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/auth"
+)
+
+// options holds optional SpiceDBKubeProxy configuration
+type options struct {
+	authOptions   []auth.Option
+	bootstrapFile string
+	rulesPath     string
+	clientCache   *clientCache
+	logger        *slog.Logger
+	auditSink     audit.Sink
+	timeouts      Timeouts
+	// workflowDBPath is a stable workflow database path; empty selects a temporary file
+	workflowDBPath string
+	// inMemoryWorkflowDB keeps the workflow database in memory instead of a file
+	inMemoryWorkflowDB bool
+	// checkConcurrency bounds concurrent SpiceDB permission checks; zero selects DefaultCheckConcurrency
+	checkConcurrency int
+	// namespaceCreateRelationships are extra templates for the create-namespaces rule
+	namespaceCreateRelationships []string
+}
+
+// Option configures optional SpiceDBKubeProxy behavior
+type Option func(*options)
+
+// WithAuthenticatorOptions passes options through to the request authenticator
+func WithAuthenticatorOptions(opts ...auth.Option) Option {
+	return func(o *options) {
+		o.authOptions = append(o.authOptions, opts...)
+	}
+}
+
+// WithLogger sets the logger used by the proxy; slog.Default() is used otherwise
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithWorkflowDatabasePath stores the embedded proxy's workflow SQLite database at path so it
+// persists across restarts. Missing parent directories are created. Without this option a
+// unique temporary file is used and removed when the proxy stops.
+func WithWorkflowDatabasePath(path string) Option {
+	return func(o *options) {
+		o.workflowDBPath = path
+		o.inMemoryWorkflowDB = false
+	}
+}
+
+// WithInMemoryWorkflowDatabase keeps the embedded proxy's workflow database in memory, so
+// nothing is written to disk and parallel proxies never share state. Workflows in flight are
+// lost when the proxy stops, which suits tests but not deployments relying on durable dual writes.
+func WithInMemoryWorkflowDatabase() Option {
+	return func(o *options) {
+		o.inMemoryWorkflowDB = true
+		o.workflowDBPath = ""
+	}
+}
+
+// WithAuditSink sends authorization decisions to sink instead of logging them with the proxy logger
+func WithAuditSink(sink audit.Sink) Option {
+	return func(o *options) {
+		o.auditSink = sink
+	}
+}
@@ -0,0 +1,291 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// PrinterFormatText logs the snapshot as human readable lines
+	PrinterFormatText = "text"
+	// PrinterFormatJSON writes the snapshot to stdout as a single JSON object per interval
+	PrinterFormatJSON = "json"
+
+	defaultPrinterInterval = 30 * time.Second
+	// snapshotLimitPerType bounds how many relationships the printer reads per resource type
+	snapshotLimitPerType = 50
+)
+
+// snapshotResourceTypes are the definitions included in relationship snapshots
+var snapshotResourceTypes = []string{"namespace", "pod", "configmap", "deployment", "user", "group", "cluster", "testresource", "workflow", "activity", "lock"}
+
+// PrinterConfig configures the periodic SpiceDB data printer
+type PrinterConfig struct {
+	// Interval between snapshots; zero or negative disables the printer
+	Interval time.Duration
+	// Format is PrinterFormatText or PrinterFormatJSON
+	Format string
+	// File, when set, writes snapshots to this file instead of the log or stdout, as one JSON
+	// object per relationship per line regardless of Format
+	File string
+	// FileMaxBytes rotates File once a snapshot would take it past this size; zero never rotates
+	FileMaxBytes int64
+	// FileMaxBackups is the number of rotated files kept next to File, named File.1, File.2 and so on
+	FileMaxBackups int
+	// RedactSubjects replaces subject IDs with a short hash, so snapshots can still be
+	// correlated without naming users
+	RedactSubjects bool
+}
+
+// DefaultPrinterConfig returns the printer configuration used when nothing is configured
+func DefaultPrinterConfig() PrinterConfig {
+	return PrinterConfig{
+		Interval:       defaultPrinterInterval,
+		Format:         PrinterFormatText,
+		FileMaxBytes:   defaultPrinterFileMaxBytes,
+		FileMaxBackups: defaultPrinterFileMaxBackups,
+	}
+}
+
+// snapshotLine is one relationship of a snapshot written to the printer file
+type snapshotLine struct {
+	Time string `json:"time"`
+	Relationship
+}
+
+// Relationship is a JSON friendly representation of a SpiceDB relationship
+type Relationship struct {
+	ResourceType    string `json:"resourceType"`
+	ResourceID      string `json:"resourceId"`
+	Relation        string `json:"relation"`
+	SubjectType     string `json:"subjectType"`
+	SubjectID       string `json:"subjectId"`
+	SubjectRelation string `json:"subjectRelation,omitempty"`
+	// Caveat and CaveatContext are the caveat the relationship is conditional on, if any
+	Caveat        string                 `json:"caveat,omitempty"`
+	CaveatContext map[string]interface{} `json:"caveatContext,omitempty"`
+	// ExpiresAt is when SpiceDB stops honouring the relationship, if it expires
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// String formats the relationship as resource:id#relation@subject:id
+func (r Relationship) String() string {
+	s := fmt.Sprintf("%s:%s#%s@%s:%s", r.ResourceType, r.ResourceID, r.Relation, r.SubjectType, r.SubjectID)
+	if r.SubjectRelation != "" {
+		s += "#" + r.SubjectRelation
+	}
+	return s
+}
+
+// relationshipFromProto converts a SpiceDB relationship into a Relationship
+func relationshipFromProto(rel *v1.Relationship) Relationship {
+	r := Relationship{
+		ResourceType:    rel.GetResource().GetObjectType(),
+		ResourceID:      rel.GetResource().GetObjectId(),
+		Relation:        rel.GetRelation(),
+		SubjectType:     rel.GetSubject().GetObject().GetObjectType(),
+		SubjectID:       rel.GetSubject().GetObject().GetObjectId(),
+		SubjectRelation: rel.GetSubject().GetOptionalRelation(),
+	}
+	if caveat := rel.GetOptionalCaveat(); caveat != nil {
+		r.Caveat = caveat.GetCaveatName()
+		if len(caveat.GetContext().GetFields()) > 0 {
+			r.CaveatContext = caveat.GetContext().AsMap()
+		}
+	}
+	if rel.GetOptionalExpiresAt() != nil {
+		expiresAt := rel.GetOptionalExpiresAt().AsTime()
+		r.ExpiresAt = &expiresAt
+	}
+	return r
+}
+
+// relationshipToProto converts a Relationship back into a SpiceDB relationship
+func relationshipToProto(r Relationship) (*v1.Relationship, error) {
+	rel := &v1.Relationship{
+		Resource: &v1.ObjectReference{ObjectType: r.ResourceType, ObjectId: r.ResourceID},
+		Relation: r.Relation,
+		Subject: &v1.SubjectReference{
+			Object:           &v1.ObjectReference{ObjectType: r.SubjectType, ObjectId: r.SubjectID},
+			OptionalRelation: r.SubjectRelation,
+		},
+	}
+	if r.Caveat != "" {
+		caveatContext, err := structpb.NewStruct(r.CaveatContext)
+		if err != nil {
+			return nil, fmt.Errorf("invalid caveat context for %s: %w", r, err)
+		}
+		rel.OptionalCaveat = &v1.ContextualizedCaveat{CaveatName: r.Caveat, Context: caveatContext}
+	} else if len(r.CaveatContext) > 0 {
+		return nil, fmt.Errorf("caveat context for %s has no caveat", r)
+	}
+	if r.ExpiresAt != nil {
+		rel.OptionalExpiresAt = timestamppb.New(*r.ExpiresAt)
+	}
+	return rel, nil
+}
+
+// SnapshotRelationships reads up to limit relationships for each known resource type
+func (c *SpiceDBKubeProxy) SnapshotRelationships(ctx context.Context, limit uint32) ([]Relationship, error) {
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return nil, fmt.Errorf("SpiceDB client not available")
+	}
+
+	relationships := make([]Relationship, 0)
+	for _, resourceType := range snapshotResourceTypes {
+		relResp, err := client.ReadRelationships(ctx, &v1.ReadRelationshipsRequest{
+			RelationshipFilter: &v1.RelationshipFilter{
+				ResourceType: resourceType,
+			},
+			OptionalLimit: limit,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s relationships: %w", resourceType, err)
+		}
+
+		for {
+			msg, err := relResp.Recv()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, fmt.Errorf("error receiving %s relationship: %w", resourceType, err)
+			}
+			relationships = append(relationships, relationshipFromProto(msg.Relationship))
+		}
+	}
+
+	return relationships, nil
+}
+
+// StartSpiceDBDataPrinter starts a goroutine that periodically prints SpiceDB data until ctx
+// ends. The returned channel is closed once the printer has stopped and closed its file.
+func (c *SpiceDBKubeProxy) StartSpiceDBDataPrinter(ctx context.Context, cfg PrinterConfig) (<-chan struct{}, error) {
+	done := make(chan struct{})
+	if cfg.Interval <= 0 {
+		c.logger.Info("SpiceDB data printer disabled")
+		close(done)
+		return done, nil
+	}
+
+	var file *rotatingFile
+	if cfg.File != "" {
+		var err error
+		file, err = openRotatingFile(cfg.File, cfg.FileMaxBytes, cfg.FileMaxBackups)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		if file != nil {
+			defer func() {
+				if err := file.Close(); err != nil {
+					c.logger.Error("failed to close SpiceDB printer file", "file", cfg.File, "error", err)
+				}
+			}()
+			c.logger.Info("starting SpiceDB data printer", "interval", cfg.Interval, "file", cfg.File, "redactSubjects", cfg.RedactSubjects)
+		} else {
+			c.logger.Info("starting SpiceDB data printer", "interval", cfg.Interval, "format", cfg.Format, "redactSubjects", cfg.RedactSubjects)
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				c.logger.Info("SpiceDB data printer stopping")
+				return
+			case <-ticker.C:
+				c.printSpiceDBData(ctx, cfg, file)
+			}
+		}
+	}()
+	return done, nil
+}
+
+// printSpiceDBData queries and prints current SpiceDB relationships, to file when it is set.
+// The snapshot is bounded by the printer timeout so a slow SpiceDB cannot stall the printer
+// past its next tick.
+func (c *SpiceDBKubeProxy) printSpiceDBData(ctx context.Context, cfg PrinterConfig, file *rotatingFile) {
+	snapshotCtx, cancel := withTimeout(ctx, c.timeouts.Printer)
+	defer cancel()
+
+	relationships, err := c.SnapshotRelationships(snapshotCtx, snapshotLimitPerType)
+	if err != nil {
+		c.logger.Error("failed to take SpiceDB snapshot", "error", err)
+		return
+	}
+	if cfg.RedactSubjects {
+		for i := range relationships {
+			relationships[i].SubjectID = redactID(relationships[i].SubjectID)
+		}
+	}
+
+	if file != nil {
+		// The whole snapshot goes out in one write so rotation never splits it across files
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		now := time.Now().UTC().Format(time.RFC3339)
+		for _, rel := range relationships {
+			if err := enc.Encode(snapshotLine{Time: now, Relationship: rel}); err != nil {
+				c.logger.Error("failed to encode SpiceDB snapshot", "error", err)
+				return
+			}
+		}
+		if _, err := file.Write(buf.Bytes()); err != nil {
+			c.logger.Error("failed to write SpiceDB snapshot", "file", file.path, "error", err)
+		}
+		return
+	}
+
+	if cfg.Format == PrinterFormatJSON {
+		out, err := json.Marshal(map[string]interface{}{
+			"msg":           "spicedb_snapshot",
+			"time":          time.Now().UTC().Format(time.RFC3339),
+			"total":         len(relationships),
+			"relationships": relationships,
+		})
+		if err != nil {
+			c.logger.Error("failed to encode SpiceDB snapshot", "error", err)
+			return
+		}
+		fmt.Fprintln(os.Stdout, string(out))
+		return
+	}
+
+	byType := make(map[string][]string)
+	for _, rel := range relationships {
+		byType[rel.ResourceType] = append(byType[rel.ResourceType], rel.String())
+	}
+
+	for _, resourceType := range snapshotResourceTypes {
+		c.logger.Info("SpiceDB relationships",
+			"resource_type", resourceType,
+			"count", len(byType[resourceType]),
+			"relationships", byType[resourceType],
+		)
+	}
+	c.logger.Info("SpiceDB data snapshot", "total", len(relationships))
+}
+
+// redactID replaces id with the first eight bytes of its SHA-256, so the same subject reads
+// the same across snapshots
+func redactID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return "redacted:" + hex.EncodeToString(sum[:8])
+}
@@ -2,14 +2,23 @@ package proxy
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
@@ -17,67 +26,100 @@ import (
 	"github.com/authzed/spicedb-kubeapi-proxy/pkg/config/proxyrule"
 	"github.com/authzed/spicedb-kubeapi-proxy/pkg/proxy"
 	"github.com/authzed/spicedb-kubeapi-proxy/pkg/rules"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/audit"
 	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/auth"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/metrics"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/requestid"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/tracing"
+)
+
+// ErrPermissionDenied is returned when the embedded proxy rejects a request because
+// the SpiceDB authorization checks did not pass
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ErrOwnershipConflict is returned when a namespace does not have exactly one creator,
+// or its creator changed while an ownership transfer was in progress
+var ErrOwnershipConflict = errors.New("namespace ownership conflict")
+
+// ErrNamespaceExists is returned by CreateNamespaceAsUser when the namespace already exists,
+// whether it was created earlier or by a concurrent request that won the race
+var ErrNamespaceExists = errors.New("namespace already exists")
+
+const (
+	// namespaceCreateAttempts bounds the creates CreateNamespaceAsUser makes while another
+	// create of the same namespace holds the embedded proxy's lock
+	namespaceCreateAttempts = 3
+	// namespaceCreateRetryDelay is the wait before retrying such a create
+	namespaceCreateRetryDelay = 250 * time.Millisecond
 )
 
 // SpiceDBKubeProxy integrates SpiceDB authorization with Kubernetes API access
 type SpiceDBKubeProxy struct {
 	proxySrv      *proxy.Server
+	spicedbClient v1.PermissionsServiceClient
+	watchClient   v1.WatchServiceClient
+	schemaClient  v1.SchemaServiceClient
+	schemaConn    *grpc.ClientConn
 	kubeClient    *kubernetes.Clientset
-	embeddedHTTP  *http.Client
 	authenticator *auth.Authenticator
+	ruleConfigs   []proxyrule.Config
+	clientCache   *clientCache
+	logger        *slog.Logger
+	auditSink     audit.Sink
+	timeouts      Timeouts
+	// checkTimeouts matches check timeouts in the embedded proxy to the requests waiting on them
+	checkTimeouts *checkTimeoutWatchers
+	// namespaceCreates serializes CreateNamespaceAsUser calls for the same namespace
+	namespaceCreates namespaceLocks
+	// namespaceCreateRelationships are the templates the create rule writes beyond the creator and cluster link
+	namespaceCreateRelationships []string
+	// workflowDBPath is removed once the proxy stops when removeWorkflowDB is set; it is
+	// empty when the workflow database is kept in memory
+	workflowDBPath   string
+	removeWorkflowDB bool
+	// stopped is closed once the embedded proxy has stopped and cleaned up
+	stopped chan struct{}
+	// cancel stops the proxy started by Start; stopOnce guards Stop
+	cancel   context.CancelFunc
+	stopOnce sync.Once
+	stopErr  error
 }
 
 // NewSpiceDBKubeProxy creates a new proxy with embedded spicedb-kubeapi-proxy
-func NewSpiceDBKubeProxy(ctx context.Context, kubeConfig *rest.Config) (*SpiceDBKubeProxy, error) {
+func NewSpiceDBKubeProxy(ctx context.Context, kubeConfig *rest.Config, optFns ...Option) (*SpiceDBKubeProxy, error) {
+	o := &options{logger: slog.Default(), timeouts: DefaultTimeouts()}
+	for _, fn := range optFns {
+		fn(o)
+	}
+
 	// Bootstrap content for SpiceDB schema - includes required workflow definitions
+	bootstrap, bootstrapSchema, err := loadBootstrap(o.bootstrapFile)
+	if err != nil {
+		return nil, err
+	}
 	bootstrapContent := map[string][]byte{
-		"bootstrap.yaml": []byte(`schema: |-
-  use expiration
-
-  definition cluster {}
-  definition user {}
-  definition namespace {
-    relation cluster: cluster
-    relation creator: user
-    relation viewer: user
-
-    permission admin = creator
-    permission edit = creator
-    permission view = viewer + creator
-    permission no_one_at_all = nil
-  }
-  definition pod {
-    relation namespace: namespace
-    relation creator: user
-    relation viewer: user
-    permission edit = creator
-    permission view = viewer + creator
-  }
-  definition testresource {
-    relation namespace: namespace
-    relation creator: user
-    relation viewer: user
-    permission edit = creator
-    permission view = viewer + creator
-  }
-  definition lock {
-    relation workflow: workflow
-  }
-  definition workflow {
-    relation idempotency_key: activity with expiration
-  }
-  definition activity{}
-relationships: |
-`),
+		"bootstrap.yaml": bootstrap,
 	}
 
 	// Create embedded proxy options
 	opts := proxy.NewOptions(proxy.WithEmbeddedProxy, proxy.WithEmbeddedSpiceDBBootstrap(bootstrapContent))
 
-	// Set workflow database to a unique path to avoid conflicts
-	opts.WorkflowDatabasePath = fmt.Sprintf("/tmp/proxy-workflow-%d.sqlite", time.Now().UnixNano())
+	// Use the configured workflow database, or a unique temporary file to avoid conflicts.
+	// The embedded proxy keeps the database in memory when its path is empty.
+	workflowDBPath, tempWorkflowDB := o.workflowDBPath, o.workflowDBPath == "" && !o.inMemoryWorkflowDB
+	if o.inMemoryWorkflowDB {
+		workflowDBPath = ""
+	} else if tempWorkflowDB {
+		workflowDBPath = filepath.Join(os.TempDir(), fmt.Sprintf("proxy-workflow-%d.sqlite", time.Now().UnixNano()))
+	} else if err := os.MkdirAll(filepath.Dir(workflowDBPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create workflow database directory: %w", err)
+	}
+	opts.WorkflowDatabasePath = workflowDBPath
 
 	// Configure backend Kubernetes cluster
 	opts.RestConfigFunc = func() (*rest.Config, http.RoundTripper, error) {
@@ -86,78 +128,16 @@ relationships: |
 			return nil, nil, err
 		}
 		configCopy := rest.CopyConfig(kubeConfig)
-		return configCopy, transport, nil
-	}
-
-	// Define authorization rules
-	ruleConfigs := []proxyrule.Config{
-		{
-			Spec: proxyrule.Spec{
-				Matches: []proxyrule.Match{{
-					GroupVersion: "v1",
-					Resource:     "namespaces",
-					Verbs:        []string{"create"},
-				}},
-				Update: proxyrule.Update{
-					CreateRelationships: []proxyrule.StringOrTemplate{{
-						Template: "namespace:{{name}}#creator@user:{{user.name}}",
-					}},
-				},
-			},
-		},
-		{
-			Spec: proxyrule.Spec{
-				Matches: []proxyrule.Match{{
-					GroupVersion: "v1",
-					Resource:     "namespaces",
-					Verbs:        []string{"get"},
-				}},
-				Checks: []proxyrule.StringOrTemplate{{
-					Template: "namespace:{{name}}#view@user:{{user.name}}",
-				}},
-			},
-		},
-		{
-			Spec: proxyrule.Spec{
-				Matches: []proxyrule.Match{{
-					GroupVersion: "v1",
-					Resource:     "namespaces",
-					Verbs:        []string{"list"},
-				}},
-				PreFilters: []proxyrule.PreFilter{{
-					FromObjectIDNameExpr:    "{{resourceId}}",
-					LookupMatchingResources: &proxyrule.StringOrTemplate{Template: "namespace:$#view@user:{{user.name}}"},
-				}},
-			},
-		},
-		{
-			Spec: proxyrule.Spec{
-				Matches: []proxyrule.Match{{
-					GroupVersion: "v1",
-					Resource:     "pods",
-					Verbs:        []string{"create"},
-				}},
-				Update: proxyrule.Update{
-					CreateRelationships: []proxyrule.StringOrTemplate{{
-						Template: "pod:{{name}}#creator@user:{{user.name}}",
-					}, {
-						Template: "pod:{{name}}#namespace@namespace:{{namespace}}",
-					}},
-				},
-			},
-		},
-		{
-			Spec: proxyrule.Spec{
-				Matches: []proxyrule.Match{{
-					GroupVersion: "v1",
-					Resource:     "pods",
-					Verbs:        []string{"get", "list", "delete"},
-				}},
-				Checks: []proxyrule.StringOrTemplate{{
-					Template: "pod:{{name}}#edit@user:{{user.name}}",
-				}},
-			},
-		},
+		return configCopy, tracing.Transport(transport), nil
+	}
+
+	// Load authorization rules, merging any configured rule files over the defaults
+	ruleConfigs, err := loadRuleConfigs(o.rulesPath, o.logger)
+	if err != nil {
+		return nil, err
+	}
+	if err := addNamespaceCreateRelationships(ruleConfigs, o.namespaceCreateRelationships); err != nil {
+		return nil, err
 	}
 
 	matcher, err := rules.NewMapMatcher(ruleConfigs)
@@ -166,12 +146,29 @@ relationships: |
 	}
 	opts.Matcher = matcher
 
+	// Fail fast on rules naming definitions, relations or permissions the schema lacks
+	if err := validateRulesAgainstSchema(ruleConfigs, bootstrapSchema); err != nil {
+		return nil, err
+	}
+
 	// Complete configuration
 	completedConfig, err := opts.Complete(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to complete proxy configuration: %w", err)
 	}
 
+	// The embedded proxy authorizes Kubernetes requests with the permissions client the
+	// configuration was completed with, so share the check limit with it
+	checkLimit, checkTimeouts := newCheckLimiter(o.checkConcurrency), newCheckTimeoutWatchers()
+	opts.PermissionsClient = limitedPermissionsClient{PermissionsServiceClient: opts.PermissionsClient, limiter: checkLimit, timeout: o.timeouts.SpiceDBCheck, watchers: checkTimeouts}
+
+	// The proxy only exposes the permissions and watch clients, so dial the embedded SpiceDB for
+	// schema access and for permission calls that are traced
+	schemaConn, err := opts.SpiceDBOptions.EmbeddedSpiceDB.GRPCDialContext(ctx, spicedbDialOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to embedded SpiceDB: %w", err)
+	}
+
 	// Create proxy server
 	proxySrv, err := proxy.NewServer(ctx, completedConfig)
 	if err != nil {
@@ -179,75 +176,643 @@ relationships: |
 	}
 
 	// Create authenticator
-	authenticator, err := auth.NewAuthenticator(kubeConfig)
+	authenticator, err := auth.NewAuthenticator(kubeConfig, o.authOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create authenticator: %w", err)
 	}
 
+	// Direct backend client for lookups that must bypass SpiceDB authorization
+	backendConfig := rest.CopyConfig(kubeConfig)
+	backendConfig.Wrap(tracing.Transport)
+	kubeClient, err := kubernetes.NewForConfig(backendConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	auditSink := o.auditSink
+	if auditSink == nil {
+		auditSink = audit.NewSlogSink(o.logger)
+	}
+
 	return &SpiceDBKubeProxy{
 		proxySrv:      proxySrv,
+		spicedbClient: limitedPermissionsClient{PermissionsServiceClient: v1.NewPermissionsServiceClient(schemaConn), limiter: checkLimit, timeout: o.timeouts.SpiceDBCheck},
+		watchClient:   opts.WatchClient,
+		schemaClient:  v1.NewSchemaServiceClient(schemaConn),
+		schemaConn:    schemaConn,
+		kubeClient:    kubeClient,
 		authenticator: authenticator,
+		ruleConfigs:   ruleConfigs,
+		clientCache:   o.clientCache,
+		logger:        o.logger,
+		auditSink:     auditSink,
+		timeouts:      o.timeouts,
+		checkTimeouts: checkTimeouts,
+
+		namespaceCreateRelationships: o.namespaceCreateRelationships,
+
+		workflowDBPath:   workflowDBPath,
+		removeWorkflowDB: tempWorkflowDB,
+		stopped:          make(chan struct{}),
 	}, nil
 }
 
-// Start starts the embedded proxy server
+// Start starts the embedded proxy server. Cancelling ctx or calling Stop stops it.
 func (c *SpiceDBKubeProxy) Start(ctx context.Context) error {
+	ctx, c.cancel = context.WithCancel(ctx)
+
+	go c.monitorConnection(ctx)
+
 	// Start proxy server in background
 	go func() {
-		if err := c.proxySrv.Run(ctx); err != nil && ctx.Err() == nil {
-			log.Printf("Proxy server error: %v", err)
+		defer close(c.stopped)
+
+		if err := c.proxySrv.Run(ctx); err != nil {
+			if ctx.Err() == nil {
+				c.logger.Error("proxy server stopped unexpectedly", "error", err)
+			}
+		} else if err := c.proxySrv.WorkflowWorker.Shutdown(context.Background()); err != nil {
+			// Run only drains the workflow worker on failure, so drain it after a clean stop
+			// too before the database it writes to is removed
+			c.logger.Warn("workflow worker did not shut down cleanly", "error", err)
+		}
+		if c.removeWorkflowDB {
+			c.removeWorkflowDatabase()
 		}
 	}()
 
 	return nil
 }
 
-// GetKubernetesClientForUser returns a Kubernetes client for a specific user
+// Stopped returns a channel that is closed once the proxy started by Start has stopped
+// and its temporary workflow database has been removed
+func (c *SpiceDBKubeProxy) Stopped() <-chan struct{} {
+	return c.stopped
+}
+
+// Stop shuts the proxy down and waits until the embedded SpiceDB and workflow engine have
+// stopped, the worker's in-flight tasks have completed and the temporary workflow database
+// has been removed, then closes the schema connection. Callers should stop sending requests
+// first, e.g. by shutting down the HTTP server. Stop returns an error if ctx ends before
+// shutdown finishes; later calls return the result of the first.
+func (c *SpiceDBKubeProxy) Stop(ctx context.Context) error {
+	c.stopOnce.Do(func() {
+		if c.cancel == nil {
+			// Never started
+			c.stopErr = c.schemaConn.Close()
+			return
+		}
+
+		c.cancel()
+		select {
+		case <-c.stopped:
+		case <-ctx.Done():
+			c.stopErr = fmt.Errorf("timed out waiting for the embedded proxy to stop: %w", ctx.Err())
+		}
+
+		if err := c.schemaConn.Close(); err != nil && c.stopErr == nil {
+			c.stopErr = err
+		}
+	})
+	return c.stopErr
+}
+
+// removeWorkflowDatabase deletes the temporary workflow database and its SQLite sidecar files
+func (c *SpiceDBKubeProxy) removeWorkflowDatabase() {
+	for _, path := range []string{c.workflowDBPath, c.workflowDBPath + "-wal", c.workflowDBPath + "-shm", c.workflowDBPath + "-journal"} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			c.logger.Warn("failed to remove workflow database file", "path", path, "error", err)
+		}
+	}
+}
+
+// IsReady reports whether the embedded SpiceDB is serving requests by reading at most one relationship
+func (c *SpiceDBKubeProxy) IsReady(ctx context.Context) bool {
+	return c.CheckReady(ctx) == nil
+}
+
+// CheckReady pings the embedded SpiceDB by reading at most one relationship and returns
+// the reason it is not serving requests, or nil when it is ready
+func (c *SpiceDBKubeProxy) CheckReady(ctx context.Context) error {
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return fmt.Errorf("SpiceDB client not available")
+	}
+
+	// Fail fast while the connection is down instead of waiting for it to reconnect
+	stream, err := client.ReadRelationships(ctx, &v1.ReadRelationshipsRequest{
+		RelationshipFilter: &v1.RelationshipFilter{
+			ResourceType: "namespace",
+		},
+		OptionalLimit: 1,
+	}, grpc.WaitForReady(false))
+	if err != nil {
+		return err
+	}
+
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// GetKubernetesClientForUser returns a Kubernetes client for a specific user.
+// Clients are reused across calls when the client cache is enabled. It is safe for
+// concurrent use: the proxy holds no per-user state outside the client cache, which
+// is guarded by its own lock.
 func (c *SpiceDBKubeProxy) GetKubernetesClientForUser(username string, groups ...string) (*kubernetes.Clientset, error) {
-	embeddedHTTP := c.proxySrv.GetEmbeddedClient(
-		proxy.WithUser(username),
-		proxy.WithGroups(groups...),
-	)
+	var cacheKey string
+	if c.clientCache != nil {
+		cacheKey = clientCacheKey(username, groups)
+		if client, ok := c.clientCache.get(cacheKey); ok {
+			return client, nil
+		}
+	}
+
+	embeddedHTTP, err := c.embeddedClientForUser(username, groups)
+	if err != nil {
+		return nil, err
+	}
 
 	kubeClient, err := kubernetes.NewForConfigAndClient(proxy.EmbeddedRestConfig, embeddedHTTP)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
 	}
 
+	if c.clientCache != nil {
+		return c.clientCache.add(cacheKey, kubeClient), nil
+	}
 	return kubeClient, nil
 }
 
-// CreateNamespaceAsUser creates a namespace as a specific user
-func (c *SpiceDBKubeProxy) CreateNamespaceAsUser(ctx context.Context, username, namespace string) error {
-	client, err := c.GetKubernetesClientForUser(username, "users")
+// embeddedClientForUser returns a new HTTP client that sends requests through the embedded
+// proxy as the given identity. Each call gets its own client and transport, so clients are
+// never shared or mutated across users. Groups are copied, since the client keeps them for
+// every request it sends and a cached client outlives the caller's slice.
+func (c *SpiceDBKubeProxy) embeddedClientForUser(username string, groups []string) (*http.Client, error) {
+	embeddedHTTP := c.proxySrv.GetEmbeddedClient(
+		proxy.WithUser(username),
+		proxy.WithGroups(append([]string(nil), groups...)...),
+	)
+	if embeddedHTTP == nil {
+		return nil, fmt.Errorf("embedded proxy client not available")
+	}
+	embeddedHTTP.Transport = tracing.Transport(checkTimeoutTransport{
+		base:      embeddedHTTP.Transport,
+		watchers:  c.checkTimeouts,
+		subjectID: username,
+		timeout:   c.timeouts.SpiceDBCheck,
+	})
+	return embeddedHTTP, nil
+}
+
+// CreateNamespaceAsUser creates a namespace as a specific user. With dryRun the backend
+// validates the create without persisting it, and no relationships are written: the
+// embedded proxy writes the creator relationship before forwarding a create, so dry runs
+// go to the backend directly, which the create rule allows since it has no SpiceDB checks.
+//
+// Creating a namespace that exists returns ErrNamespaceExists, and the user is never
+// recorded as its creator. Such creates must not reach the embedded proxy: when its
+// relationship write fails because the namespace is already recorded, it rolls back every
+// relationship in the write, including the existing creator and cluster link. Creates of
+// the same namespace are therefore serialized in this process, and existing namespaces are
+// rejected up front. A create racing one from outside this process is handled as it comes
+// back: a backend AlreadyExists has its relationships removed, and a conflict is retried
+// while the other create is in flight, until the namespace exists or attempts run out.
+func (c *SpiceDBKubeProxy) CreateNamespaceAsUser(ctx context.Context, username string, groups []string, namespace string, dryRun bool) error {
+	ctx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+	defer cancel()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	if dryRun {
+		_, err := c.kubeClient.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+		return err
+	}
+
+	client, err := c.GetKubernetesClientForUser(username, groups...)
 	if err != nil {
 		return err
 	}
 
-	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
-	_, err = client.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
-	return err
+	unlock, err := c.namespaceCreates.lock(ctx, namespace)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := c.kubeClient.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{}); err == nil {
+		return fmt.Errorf("%w: %s", ErrNamespaceExists, namespace)
+	} else if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to check whether namespace %s exists: %w", namespace, err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		_, err = client.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+		switch {
+		case err == nil:
+			return nil
+		case apierrors.IsAlreadyExists(err):
+			c.removeNamespaceCreateRelationships(ctx, namespace, username)
+			return fmt.Errorf("%w: %s", ErrNamespaceExists, namespace)
+		case !apierrors.IsConflict(err):
+			return err
+		}
+
+		// The relationship write failed: the namespace is already recorded in SpiceDB, or
+		// another create of it holds the lock and may still fail
+		if _, getErr := c.kubeClient.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{}); getErr == nil {
+			return fmt.Errorf("%w: %s", ErrNamespaceExists, namespace)
+		}
+		if attempt == namespaceCreateAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(namespaceCreateRetryDelay):
+		}
+	}
 }
 
-// ListNamespacesAsUser lists namespaces that a user has access to
-func (c *SpiceDBKubeProxy) ListNamespacesAsUser(ctx context.Context, username string) ([]string, error) {
-	client, err := c.GetKubernetesClientForUser(username, "users")
+// removeNamespaceCreateRelationships deletes the relationships the create rule wrote for a
+// create the backend rejected because the namespace exists. The embedded proxy treats that
+// rejection as success and keeps them, which would make the user the creator of a namespace
+// they did not create. The rule writes them with CREATE, which fails when they already
+// exist, so a rejected create only reaches the backend when this request wrote them itself.
+func (c *SpiceDBKubeProxy) removeNamespaceCreateRelationships(ctx context.Context, namespace, username string) {
+	// Clean up even when the caller has gone away
+	ctx, cancel := withTimeout(context.WithoutCancel(ctx), c.timeouts.SpiceDBWrite)
+	defer cancel()
+
+	clusterLink := &v1.Relationship{
+		Resource: &v1.ObjectReference{ObjectType: "namespace", ObjectId: namespace},
+		Relation: "cluster",
+		Subject: &v1.SubjectReference{
+			Object: &v1.ObjectReference{ObjectType: "cluster", ObjectId: ClusterObjectID},
+		},
+	}
+	updates := []*v1.RelationshipUpdate{
+		{Operation: v1.RelationshipUpdate_OPERATION_DELETE, Relationship: namespaceUserRelationship(namespace, "creator", username)},
+		{Operation: v1.RelationshipUpdate_OPERATION_DELETE, Relationship: clusterLink},
+	}
+	for _, tmpl := range c.namespaceCreateRelationships {
+		// Validated at startup, so rendering only fails for namespace or user names SpiceDB rejects
+		if rel, err := namespaceCreateRelationship(tmpl, namespace, username); err == nil {
+			updates = append(updates, &v1.RelationshipUpdate{Operation: v1.RelationshipUpdate_OPERATION_DELETE, Relationship: rel})
+		}
+	}
+	start := time.Now()
+	_, err := c.GetSpiceDBClient().WriteRelationships(requestid.OutgoingContext(ctx), &v1.WriteRelationshipsRequest{Updates: updates})
+	metrics.ObserveSpiceDBCall("write_relationships", start, err)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "failed to remove relationships of a namespace that already existed",
+			"namespace", namespace, "user", username, "error", err)
+	}
+}
+
+// DeleteNamespaceAsUser deletes a namespace as a specific user
+func (c *SpiceDBKubeProxy) DeleteNamespaceAsUser(ctx context.Context, username string, groups []string, namespace string) error {
+	ctx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+	defer cancel()
+
+	client, err := c.GetKubernetesClientForUser(username, groups...)
+	if err != nil {
+		return err
+	}
+
+	err = client.CoreV1().Namespaces().Delete(ctx, namespace, metav1.DeleteOptions{})
+	return permissionError(err, fmt.Sprintf("delete namespace %s", namespace))
+}
+
+// NamespaceInfo is the basic metadata returned for a namespace
+type NamespaceInfo struct {
+	Name              string            `json:"name"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
+	CreationTimestamp time.Time         `json:"creationTimestamp"`
+	Phase             string            `json:"phase"`
+	// Creator is the SpiceDB creator, only filled in for namespace admins
+	Creator string `json:"creator,omitempty"`
+}
+
+// GetNamespaceAsUser fetches a namespace on behalf of a user. A denied request for a
+// namespace that does not exist returns the backend's NotFound error instead of ErrPermissionDenied.
+func (c *SpiceDBKubeProxy) GetNamespaceAsUser(ctx context.Context, username string, groups []string, namespace string) (*NamespaceInfo, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+	defer cancel()
+
+	client, err := c.GetKubernetesClientForUser(username, groups...)
 	if err != nil {
 		return nil, err
 	}
 
-	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	ns, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err) {
+			// SpiceDB denies namespaces it has no relationships for, so check the backend for existence
+			if _, getErr := c.kubeClient.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{}); apierrors.IsNotFound(getErr) {
+				return nil, getErr
+			}
+		}
+		return nil, permissionError(err, fmt.Sprintf("get namespace %s", namespace))
+	}
+
+	return namespaceInfo(ns), nil
+}
+
+// NamespaceUpdate changes a namespace's labels and annotations. Keys mapped to a value are
+// set, keys mapped to nil are removed, and keys left out are kept as they are.
+type NamespaceUpdate struct {
+	Labels      map[string]*string
+	Annotations map[string]*string
+}
+
+// UpdateNamespaceAsUser applies update to a namespace as a specific user with a JSON merge
+// patch and returns the patched namespace. The user needs edit on the namespace in SpiceDB,
+// so a namespace creator, editor or cluster admin.
+func (c *SpiceDBKubeProxy) UpdateNamespaceAsUser(ctx context.Context, username string, groups []string, namespace string, update NamespaceUpdate) (*NamespaceInfo, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+	defer cancel()
+
+	client, err := c.GetKubernetesClientForUser(username, groups...)
 	if err != nil {
 		return nil, err
 	}
 
+	// A null labels or annotations field would clear every key, so only send those being changed
+	metadata := make(map[string]interface{})
+	if len(update.Labels) > 0 {
+		metadata["labels"] = update.Labels
+	}
+	if len(update.Annotations) > 0 {
+		metadata["annotations"] = update.Annotations
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode namespace patch: %w", err)
+	}
+
+	ns, err := client.CoreV1().Namespaces().Patch(ctx, namespace, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return nil, permissionError(err, fmt.Sprintf("update namespace %s", namespace))
+	}
+	return namespaceInfo(ns), nil
+}
+
+// namespaceInfo returns the metadata of ns reported to users
+func namespaceInfo(ns *corev1.Namespace) *NamespaceInfo {
+	return &NamespaceInfo{
+		Name:              ns.Name,
+		Labels:            ns.Labels,
+		Annotations:       ns.Annotations,
+		CreationTimestamp: ns.CreationTimestamp.Time,
+		Phase:             string(ns.Status.Phase),
+	}
+}
+
+// CreatePodAsUser creates a pod in a namespace as a specific user and returns the created pod name.
+// The user needs edit on the namespace in SpiceDB, so a namespace creator, editor or cluster admin.
+func (c *SpiceDBKubeProxy) CreatePodAsUser(ctx context.Context, username string, groups []string, namespace string, pod *corev1.Pod) (string, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+	defer cancel()
+
+	client, err := c.GetKubernetesClientForUser(username, groups...)
+	if err != nil {
+		return "", err
+	}
+
+	created, err := client.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return "", permissionError(err, fmt.Sprintf("create pods in namespace %s", namespace))
+	}
+	return created.Name, nil
+}
+
+// ListNamespacesOptions controls paging and filtering for ListNamespacesAsUser
+type ListNamespacesOptions struct {
+	// Limit is the maximum number of namespaces requested from Kubernetes per page; zero means no limit
+	Limit int64
+	// Continue is the token returned by a previous page
+	Continue string
+	// LabelSelector, when set, only lists namespaces with matching labels. It narrows the
+	// SpiceDB prefilter rather than widening it.
+	LabelSelector string
+	// Permission keeps only namespaces on which the user holds this permission; empty means
+	// DefaultListPermission. The list rule prefilters on view, so other permissions are
+	// checked for the namespaces on each page after it is read.
+	Permission string
+}
+
+// ListNamespacesAsUser lists namespaces that a user has access to and returns the
+// continue token for the next page, which is empty on the last page.
+// The SpiceDB prefilter is applied to every page, so a page may hold fewer than
+// Limit namespaces while more pages remain.
+func (c *SpiceDBKubeProxy) ListNamespacesAsUser(ctx context.Context, username string, groups []string, opts ListNamespacesOptions) ([]string, string, error) {
+	permission := opts.Permission
+	if permission == "" {
+		permission = DefaultListPermission
+	}
+	if err := c.validateNamespacePermission(ctx, permission); err != nil {
+		return nil, "", err
+	}
+
+	ctx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+	defer cancel()
+
+	client, err := c.GetKubernetesClientForUser(username, groups...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	namespaces, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		Limit:         opts.Limit,
+		Continue:      opts.Continue,
+		LabelSelector: opts.LabelSelector,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
 	var names []string
 	for _, ns := range namespaces.Items {
 		names = append(names, ns.Name)
 	}
+	if permission != DefaultListPermission {
+		if names, err = c.namespacesWithPermission(ctx, username, permission, names); err != nil {
+			return nil, "", err
+		}
+	}
+	return names, namespaces.Continue, nil
+}
+
+// namespacesWithPermission returns the namespaces in names on which the user holds
+// permission, checking them in batches so the cost follows the page rather than every
+// namespace the user can reach
+func (c *SpiceDBKubeProxy) namespacesWithPermission(ctx context.Context, username, permission string, names []string) ([]string, error) {
+	var held []string
+	for start := 0; start < len(names); start += MaxBulkCheckItems {
+		batch := names[start:min(start+MaxBulkCheckItems, len(names))]
+		checks := make([]PermissionCheck, 0, len(batch))
+		for _, name := range batch {
+			checks = append(checks, PermissionCheck{ResourceType: "namespace", ResourceID: name, Permission: permission})
+		}
+
+		// Fully consistent, like the embedded proxy's own prefilter lookup
+		resp, err := c.CheckPermissionsBulk(ctx, checks, "user", username, NewConsistency(true, ""))
+		if err != nil {
+			return nil, err
+		}
+		for i, pair := range resp.GetPairs() {
+			if pairErr := pair.GetError(); pairErr != nil {
+				return nil, fmt.Errorf("failed to check %s on namespace %s: %s", permission, batch[i], pairErr.GetMessage())
+			}
+			if pair.GetItem().GetPermissionship() == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
+				held = append(held, batch[i])
+			}
+		}
+	}
+	return held, nil
+}
+
+// ListPodsAsUser lists the pods in a namespace that a user has access to
+func (c *SpiceDBKubeProxy) ListPodsAsUser(ctx context.Context, username string, groups []string, namespace string) ([]string, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+	defer cancel()
+
+	client, err := c.GetKubernetesClientForUser(username, groups...)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Always return a non-nil slice so an empty result encodes as [] rather than null
+	names := make([]string, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		names = append(names, pod.Name)
+	}
+	return names, nil
+}
+
+// DeletePodAsUser deletes a pod on behalf of a user. The delete-pods rule removes the pod's
+// SpiceDB relationships along with it. A denied request for a pod that does not exist
+// returns the backend's NotFound error instead of ErrPermissionDenied.
+func (c *SpiceDBKubeProxy) DeletePodAsUser(ctx context.Context, username string, groups []string, namespace, name string) error {
+	client, err := c.GetKubernetesClientForUser(username, groups...)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+	defer cancel()
+
+	if err := client.CoreV1().Pods(namespace).Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+		if apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err) {
+			// SpiceDB denies pods it has no relationships for, so check the backend for existence
+			if _, getErr := c.kubeClient.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{}); apierrors.IsNotFound(getErr) {
+				return getErr
+			}
+		}
+		return permissionError(err, fmt.Sprintf("delete pod %s/%s", namespace, name))
+	}
+	return nil
+}
+
+// CreateConfigMapAsUser creates a ConfigMap on behalf of a user and returns its name.
+// As with pods, the user needs edit on the namespace in SpiceDB.
+func (c *SpiceDBKubeProxy) CreateConfigMapAsUser(ctx context.Context, username string, groups []string, namespace string, configMap *corev1.ConfigMap) (string, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+	defer cancel()
+
+	client, err := c.GetKubernetesClientForUser(username, groups...)
+	if err != nil {
+		return "", err
+	}
+
+	created, err := client.CoreV1().ConfigMaps(namespace).Create(ctx, configMap, metav1.CreateOptions{})
+	if err != nil {
+		return "", permissionError(err, fmt.Sprintf("create configmap %s/%s", namespace, configMap.Name))
+	}
+	return created.Name, nil
+}
+
+// ListConfigMapsAsUser lists the ConfigMaps in a namespace that a user has access to
+func (c *SpiceDBKubeProxy) ListConfigMapsAsUser(ctx context.Context, username string, groups []string, namespace string) ([]string, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+	defer cancel()
+
+	client, err := c.GetKubernetesClientForUser(username, groups...)
+	if err != nil {
+		return nil, err
+	}
+
+	configMaps, err := client.CoreV1().ConfigMaps(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(configMaps.Items))
+	for _, cm := range configMaps.Items {
+		names = append(names, cm.Name)
+	}
 	return names, nil
 }
 
+// CreateDeploymentAsUser creates a Deployment on behalf of a user and returns its name.
+// As with pods, the user needs edit on the namespace in SpiceDB.
+func (c *SpiceDBKubeProxy) CreateDeploymentAsUser(ctx context.Context, username string, groups []string, namespace string, deployment *appsv1.Deployment) (string, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+	defer cancel()
+
+	client, err := c.GetKubernetesClientForUser(username, groups...)
+	if err != nil {
+		return "", err
+	}
+
+	created, err := client.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{})
+	if err != nil {
+		return "", permissionError(err, fmt.Sprintf("create deployments in namespace %s", namespace))
+	}
+	return created.Name, nil
+}
+
+// ListDeploymentsAsUser lists the Deployments in a namespace that a user has access to
+func (c *SpiceDBKubeProxy) ListDeploymentsAsUser(ctx context.Context, username string, groups []string, namespace string) ([]string, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+	defer cancel()
+
+	client, err := c.GetKubernetesClientForUser(username, groups...)
+	if err != nil {
+		return nil, err
+	}
+
+	deployments, err := client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(deployments.Items))
+	for _, d := range deployments.Items {
+		names = append(names, d.Name)
+	}
+	return names, nil
+}
+
+// permissionError converts authorization failures returned by the embedded proxy into ErrPermissionDenied
+func permissionError(err error, action string) error {
+	if apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err) {
+		return fmt.Errorf("%w: user is not allowed to %s", ErrPermissionDenied, action)
+	}
+	return err
+}
+
 // AuthenticateFromRequest authenticates a user from HTTP request
 func (c *SpiceDBKubeProxy) AuthenticateFromRequest(r *http.Request) (*auth.UserInfo, error) {
 	authResult := c.authenticator.AuthenticateRequest(r)
@@ -257,39 +822,174 @@ func (c *SpiceDBKubeProxy) AuthenticateFromRequest(r *http.Request) (*auth.UserI
 	return authResult.User, nil
 }
 
-// CheckKubernetesPermission checks if user has Kubernetes RBAC permission
+// CheckKubernetesPermission checks if user has Kubernetes RBAC permission, recording the decision in the audit sink
 func (c *SpiceDBKubeProxy) CheckKubernetesPermission(ctx context.Context, user *auth.UserInfo, resource, verb, namespace string) (bool, error) {
-	return c.authenticator.CheckKubernetesPermission(ctx, user, resource, verb, namespace)
+	checkCtx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+	defer cancel()
+
+	allowed, err := c.authenticator.CheckKubernetesPermission(checkCtx, user, resource, verb, namespace)
+	c.RecordAuthorization(ctx, audit.AuthorizerKubernetes, user, resource, verb, namespace, allowed, err)
+	return allowed, err
+}
+
+// RecordAuthorization sends an authorization decision for user to the audit sink. A non-nil
+// err records that no decision could be made.
+func (c *SpiceDBKubeProxy) RecordAuthorization(ctx context.Context, authorizer string, user *auth.UserInfo, resource, verb, namespace string, allowed bool, err error) {
+	event := audit.Event{
+		Time:          time.Now(),
+		User:          user.Username,
+		EffectiveUser: user.Username,
+		Groups:        user.Groups,
+		Resource:      resource,
+		Verb:          verb,
+		Namespace:     namespace,
+		Authorizer:    authorizer,
+		Decision:      audit.DecisionDeny,
+	}
+	if user.Impersonator != nil {
+		event.User = user.Impersonator.Username
+	}
+	if id, ok := requestid.GetRequestIDFromContext(ctx); ok {
+		event.RequestID = id
+	}
+	switch {
+	case err != nil:
+		event.Decision, event.Error = audit.DecisionError, err.Error()
+	case allowed:
+		event.Decision = audit.DecisionAllow
+	}
+	c.auditSink.Record(ctx, event)
 }
 
-// GetSpiceDBClient returns the SpiceDB permissions client from the embedded proxy
+// GetSpiceDBClient returns the traced SpiceDB permissions client for the embedded SpiceDB
 func (c *SpiceDBKubeProxy) GetSpiceDBClient() v1.PermissionsServiceClient {
-	return c.proxySrv.PermissionClient()
+	return c.spicedbClient
 }
 
-// GrantViewPermission grants view permission on a namespace to a user in SpiceDB
-func (c *SpiceDBKubeProxy) GrantViewPermission(ctx context.Context, namespace, user string) error {
+// NewConsistency returns the SpiceDB consistency requirement for a read. A non-empty ZedToken
+// requests at-least-as-fresh semantics, fullyConsistent requests a fully consistent read and
+// otherwise SpiceDB's default minimize-latency behavior is used.
+func NewConsistency(fullyConsistent bool, atLeastAsFresh string) *v1.Consistency {
+	switch {
+	case atLeastAsFresh != "":
+		return &v1.Consistency{Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: &v1.ZedToken{Token: atLeastAsFresh}}}
+	case fullyConsistent:
+		return &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}}
+	default:
+		return &v1.Consistency{Requirement: &v1.Consistency_MinimizeLatency{MinimizeLatency: true}}
+	}
+}
+
+// CheckPermission checks whether a subject has a permission on a resource in SpiceDB.
+// A nil consistency uses SpiceDB's default minimize-latency behavior.
+func (c *SpiceDBKubeProxy) CheckPermission(ctx context.Context, resourceType, resourceID, permission, subjectType, subjectID string, consistency *v1.Consistency) (*v1.CheckPermissionResponse, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBRead)
+	defer cancel()
+
 	client := c.GetSpiceDBClient()
 	if client == nil {
-		return fmt.Errorf("SpiceDB client not available")
+		return nil, fmt.Errorf("SpiceDB client not available")
 	}
 
-	// Create relationship: namespace:namespace#viewer@user:user
-	relationship := &v1.Relationship{
+	start := time.Now()
+	resp, err := client.CheckPermission(requestid.OutgoingContext(ctx), &v1.CheckPermissionRequest{
+		Consistency: consistency,
 		Resource: &v1.ObjectReference{
-			ObjectType: "namespace",
-			ObjectId:   namespace,
+			ObjectType: resourceType,
+			ObjectId:   resourceID,
 		},
-		Relation: "viewer",
+		Permission: permission,
 		Subject: &v1.SubjectReference{
 			Object: &v1.ObjectReference{
-				ObjectType: "user",
-				ObjectId:   user,
+				ObjectType: subjectType,
+				ObjectId:   subjectID,
 			},
 		},
+	})
+	metrics.ObserveSpiceDBCall("check_permission", start, err)
+
+	return resp, err
+}
+
+// MaxBulkCheckItems bounds the checks made by one CheckPermissionsBulk call
+const MaxBulkCheckItems = 100
+
+// PermissionCheck is one check made by CheckPermissionsBulk
+type PermissionCheck struct {
+	ResourceType string
+	ResourceID   string
+	Permission   string
+}
+
+// CheckPermissionsBulk checks several permissions for one subject in a single SpiceDB call,
+// all at the same revision. The response holds one pair per check, in the order given; a
+// check that failed on its own carries an error instead of a permissionship.
+func (c *SpiceDBKubeProxy) CheckPermissionsBulk(ctx context.Context, checks []PermissionCheck, subjectType, subjectID string, consistency *v1.Consistency) (*v1.CheckBulkPermissionsResponse, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBRead)
+	defer cancel()
+
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return nil, fmt.Errorf("SpiceDB client not available")
+	}
+
+	subject := &v1.SubjectReference{
+		Object: &v1.ObjectReference{
+			ObjectType: subjectType,
+			ObjectId:   subjectID,
+		},
+	}
+	items := make([]*v1.CheckBulkPermissionsRequestItem, 0, len(checks))
+	for _, check := range checks {
+		items = append(items, &v1.CheckBulkPermissionsRequestItem{
+			Resource: &v1.ObjectReference{
+				ObjectType: check.ResourceType,
+				ObjectId:   check.ResourceID,
+			},
+			Permission: check.Permission,
+			Subject:    subject,
+		})
 	}
 
-	_, err := client.WriteRelationships(ctx, &v1.WriteRelationshipsRequest{
+	start := time.Now()
+	resp, err := client.CheckBulkPermissions(requestid.OutgoingContext(ctx), &v1.CheckBulkPermissionsRequest{
+		Consistency: consistency,
+		Items:       items,
+	})
+	metrics.ObserveSpiceDBCall("check_bulk_permissions", start, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.GetPairs()) != len(checks) {
+		return nil, fmt.Errorf("SpiceDB returned %d results for %d checks", len(resp.GetPairs()), len(checks))
+	}
+
+	return resp, nil
+}
+
+// GrantViewPermission grants view permission on a namespace to a user in SpiceDB.
+// A non-zero expiresAt makes the grant temporary: SpiceDB compares it against its own
+// clock, which for the embedded SpiceDB is this host's clock, ignores the relationship
+// once it has passed and garbage collects it later, so no manual revoke is needed.
+// It returns the ZedToken the grant was written at; reads made at least as fresh as it
+// see the grant.
+func (c *SpiceDBKubeProxy) GrantViewPermission(ctx context.Context, namespace, user string, expiresAt time.Time) (string, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBWrite)
+	defer cancel()
+
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return "", fmt.Errorf("SpiceDB client not available")
+	}
+
+	// Create relationship: namespace:namespace#viewer@user:user
+	relationship := namespaceUserRelationship(namespace, "viewer", user)
+	if !expiresAt.IsZero() {
+		relationship.OptionalExpiresAt = timestamppb.New(expiresAt)
+	}
+
+	start := time.Now()
+	resp, err := client.WriteRelationships(requestid.OutgoingContext(ctx), &v1.WriteRelationshipsRequest{
 		Updates: []*v1.RelationshipUpdate{
 			{
 				Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
@@ -297,82 +997,367 @@ func (c *SpiceDBKubeProxy) GrantViewPermission(ctx context.Context, namespace, u
 			},
 		},
 	})
+	metrics.ObserveSpiceDBCall("write_relationships", start, err)
+	if err != nil {
+		return "", err
+	}
 
-	return err
+	return resp.GetWrittenAt().GetToken(), nil
 }
 
-// StartSpiceDBDataPrinter starts a goroutine that periodically prints SpiceDB data
-func (c *SpiceDBKubeProxy) StartSpiceDBDataPrinter(ctx context.Context) {
-	go func() {
-		ticker := time.NewTicker(30 * time.Second) // Print every 30 seconds
-		defer ticker.Stop()
-
-		log.Println("Starting SpiceDB data printer goroutine...")
-
-		for {
-			select {
-			case <-ctx.Done():
-				log.Println("SpiceDB data printer stopping...")
-				return
-			case <-ticker.C:
-				c.printSpiceDBData(ctx)
-			}
+// MaxGrantViewBulkUsers bounds the users granted by one GrantViewPermissionBulk call
+const MaxGrantViewBulkUsers = 100
+
+// GrantViewPermissionBulk statuses
+const (
+	BulkGrantGranted       = "granted"
+	BulkGrantAlreadyViewer = "already_viewer"
+)
+
+// BulkGrantResult is the outcome of GrantViewPermissionBulk for one user
+type BulkGrantResult struct {
+	User   string `json:"user"`
+	Status string `json:"status"`
+}
+
+// GrantViewPermissionBulk makes every user a viewer of namespace in a single SpiceDB
+// transaction, so either all the new grants are written or none are. Users that are
+// already viewers are reported as such and left untouched, keeping any expiration.
+// Duplicate users are granted once.
+func (c *SpiceDBKubeProxy) GrantViewPermissionBulk(ctx context.Context, namespace string, users []string) ([]BulkGrantResult, error) {
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return nil, fmt.Errorf("SpiceDB client not available")
+	}
+
+	existing, err := c.namespaceRelationSubjects(ctx, namespace, "viewer")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read existing viewers: %w", err)
+	}
+
+	results := make([]BulkGrantResult, 0, len(users))
+	var updates []*v1.RelationshipUpdate
+	seen := make(map[string]bool, len(users))
+	for _, user := range users {
+		if seen[user] {
+			continue
 		}
-	}()
+		seen[user] = true
+
+		if existing[user] {
+			results = append(results, BulkGrantResult{User: user, Status: BulkGrantAlreadyViewer})
+			continue
+		}
+		results = append(results, BulkGrantResult{User: user, Status: BulkGrantGranted})
+		// TOUCH keeps the write idempotent if a grant for the same user lands concurrently
+		updates = append(updates, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: namespaceUserRelationship(namespace, "viewer", user),
+		})
+	}
+	if len(updates) == 0 {
+		return results, nil
+	}
+
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBWrite)
+	defer cancel()
+
+	start := time.Now()
+	_, err = client.WriteRelationships(requestid.OutgoingContext(ctx), &v1.WriteRelationshipsRequest{Updates: updates})
+	metrics.ObserveSpiceDBCall("write_relationships", start, err)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
 }
 
-// printSpiceDBData queries and prints current SpiceDB relationships
-func (c *SpiceDBKubeProxy) printSpiceDBData(ctx context.Context) {
+// namespaceRelationSubjects returns the users directly related to namespace by relation,
+// read with full consistency
+func (c *SpiceDBKubeProxy) namespaceRelationSubjects(ctx context.Context, namespace, relation string) (map[string]bool, error) {
+	subjects := make(map[string]bool)
+	q := RelationshipQuery{
+		ResourceType: "namespace",
+		ResourceID:   namespace,
+		Relation:     relation,
+		SubjectType:  "user",
+		Limit:        MaxRelationshipPageSize,
+		Consistency:  NewConsistency(true, ""),
+	}
+	for {
+		rels, cursor, err := c.ReadRelationships(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, rel := range rels {
+			subjects[rel.SubjectID] = true
+		}
+		if cursor == "" {
+			return subjects, nil
+		}
+		q.Cursor = cursor
+	}
+}
+
+// GrantEditPermission grants edit permission on a namespace to a user in SpiceDB
+func (c *SpiceDBKubeProxy) GrantEditPermission(ctx context.Context, namespace, user string) error {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBWrite)
+	defer cancel()
+
 	client := c.GetSpiceDBClient()
 	if client == nil {
-		log.Println("SpiceDB client not available")
-		return
+		return fmt.Errorf("SpiceDB client not available")
 	}
 
-	log.Println("=== SpiceDB Data Snapshot ===")
+	// Create relationship: namespace:namespace#editor@user:user
+	start := time.Now()
+	_, err := client.WriteRelationships(requestid.OutgoingContext(ctx), &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{
+			{
+				Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+				Relationship: namespaceUserRelationship(namespace, "editor", user),
+			},
+		},
+	})
+	metrics.ObserveSpiceDBCall("write_relationships", start, err)
+
+	return err
+}
 
-	// Read relationships - we'll read a sample to see what's in the system
-	// Query for namespace relationships first, then other types
-	resourceTypes := []string{"namespace", "pod", "user", "cluster", "testresource", "workflow", "activity", "lock"}
-	
-	totalRelationshipCount := 0
-	for _, resourceType := range resourceTypes {
-		relResp, err := client.ReadRelationships(ctx, &v1.ReadRelationshipsRequest{
-			RelationshipFilter: &v1.RelationshipFilter{
-				ResourceType: resourceType,
+// GrantViewToGroup grants view permission on a namespace to every member of a group in SpiceDB
+func (c *SpiceDBKubeProxy) GrantViewToGroup(ctx context.Context, namespace, group string) error {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBWrite)
+	defer cancel()
+
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return fmt.Errorf("SpiceDB client not available")
+	}
+
+	// Create relationship: namespace:namespace#viewer@group:group#member
+	start := time.Now()
+	_, err := client.WriteRelationships(requestid.OutgoingContext(ctx), &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{
+			{
+				Operation: v1.RelationshipUpdate_OPERATION_CREATE,
+				Relationship: &v1.Relationship{
+					Resource: &v1.ObjectReference{
+						ObjectType: "namespace",
+						ObjectId:   namespace,
+					},
+					Relation: "viewer",
+					Subject: &v1.SubjectReference{
+						Object: &v1.ObjectReference{
+							ObjectType: "group",
+							ObjectId:   group,
+						},
+						OptionalRelation: "member",
+					},
+				},
 			},
-			OptionalLimit: 50, // Limit per resource type
-		})
+		},
+	})
+	metrics.ObserveSpiceDBCall("write_relationships", start, err)
+
+	return err
+}
+
+// RevokeViewPermission removes a user's explicit view grant on a namespace in SpiceDB and
+// returns the number of relationships deleted. Revoking a grant that does not exist is not an error,
+// and view derived from other relations such as creator is left untouched.
+func (c *SpiceDBKubeProxy) RevokeViewPermission(ctx context.Context, namespace, user string) (uint64, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBWrite)
+	defer cancel()
+
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return 0, fmt.Errorf("SpiceDB client not available")
+	}
+
+	// Delete relationship: namespace:namespace#viewer@user:user
+	start := time.Now()
+	resp, err := client.DeleteRelationships(requestid.OutgoingContext(ctx), &v1.DeleteRelationshipsRequest{
+		RelationshipFilter: &v1.RelationshipFilter{
+			ResourceType:       "namespace",
+			OptionalResourceId: namespace,
+			OptionalRelation:   "viewer",
+			OptionalSubjectFilter: &v1.SubjectFilter{
+				SubjectType:       "user",
+				OptionalSubjectId: user,
+			},
+		},
+	})
+	metrics.ObserveSpiceDBCall("delete_relationships", start, err)
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.RelationshipsDeletedCount, nil
+}
+
+// NamespaceCreators returns the users holding the creator relation on a namespace
+func (c *SpiceDBKubeProxy) NamespaceCreators(ctx context.Context, namespace string) ([]string, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBRead)
+	defer cancel()
+
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return nil, fmt.Errorf("SpiceDB client not available")
+	}
+
+	stream, err := client.ReadRelationships(requestid.OutgoingContext(ctx), &v1.ReadRelationshipsRequest{
+		Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+		RelationshipFilter: &v1.RelationshipFilter{
+			ResourceType:       "namespace",
+			OptionalResourceId: namespace,
+			OptionalRelation:   "creator",
+			OptionalSubjectFilter: &v1.SubjectFilter{
+				SubjectType: "user",
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var creators []string
+	for {
+		msg, err := stream.Recv()
 		if err != nil {
-			log.Printf("Error reading %s relationships: %v", resourceType, err)
-			continue
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to receive namespace creators: %w", err)
 		}
+		creators = append(creators, msg.GetRelationship().GetSubject().GetObject().GetObjectId())
+	}
+	return creators, nil
+}
 
-		log.Printf("Current %s Relationships:", resourceType)
-		resourceRelationshipCount := 0
-		for {
-			msg, err := relResp.Recv()
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-				log.Printf("Error receiving %s relationship: %v", resourceType, err)
+// TransferNamespaceOwnership replaces the namespace's creator with newOwner and returns the previous owner.
+// The delete and create are sent in one WriteRelationships call guarded by a precondition that the
+// previous creator still exists, so the namespace never ends up with zero or two creators.
+func (c *SpiceDBKubeProxy) TransferNamespaceOwnership(ctx context.Context, namespace, newOwner string) (string, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBWrite)
+	defer cancel()
+
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return "", fmt.Errorf("SpiceDB client not available")
+	}
+
+	creators, err := c.NamespaceCreators(ctx, namespace)
+	if err != nil {
+		return "", err
+	}
+	if len(creators) != 1 {
+		return "", fmt.Errorf("%w: namespace %s has %d creators", ErrOwnershipConflict, namespace, len(creators))
+	}
+	oldOwner := creators[0]
+	if oldOwner == newOwner {
+		return oldOwner, nil
+	}
+
+	start := time.Now()
+	_, err = client.WriteRelationships(requestid.OutgoingContext(ctx), &v1.WriteRelationshipsRequest{
+		Updates: []*v1.RelationshipUpdate{
+			{
+				Operation:    v1.RelationshipUpdate_OPERATION_DELETE,
+				Relationship: namespaceUserRelationship(namespace, "creator", oldOwner),
+			},
+			{
+				Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+				Relationship: namespaceUserRelationship(namespace, "creator", newOwner),
+			},
+		},
+		OptionalPreconditions: []*v1.Precondition{{
+			Operation: v1.Precondition_OPERATION_MUST_MATCH,
+			Filter: &v1.RelationshipFilter{
+				ResourceType:       "namespace",
+				OptionalResourceId: namespace,
+				OptionalRelation:   "creator",
+				OptionalSubjectFilter: &v1.SubjectFilter{
+					SubjectType:       "user",
+					OptionalSubjectId: oldOwner,
+				},
+			},
+		}},
+	})
+	metrics.ObserveSpiceDBCall("write_relationships", start, err)
+	if status.Code(err) == codes.FailedPrecondition {
+		return "", fmt.Errorf("%w: creator of namespace %s changed during transfer", ErrOwnershipConflict, namespace)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return oldOwner, nil
+}
+
+// ListNamespaceViewers returns the sorted, de-duplicated usernames that have view permission
+// on a namespace, whether through an explicit grant, a relation such as creator, or membership
+// of a group granted view. LookupSubjects expands group#member grants into their users.
+// A nil consistency uses SpiceDB's default.
+func (c *SpiceDBKubeProxy) ListNamespaceViewers(ctx context.Context, namespace string, consistency *v1.Consistency) ([]string, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBRead)
+	defer cancel()
+
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return nil, fmt.Errorf("SpiceDB client not available")
+	}
+
+	stream, err := client.LookupSubjects(requestid.OutgoingContext(ctx), &v1.LookupSubjectsRequest{
+		Resource: &v1.ObjectReference{
+			ObjectType: "namespace",
+			ObjectId:   namespace,
+		},
+		Permission:        "view",
+		SubjectObjectType: "user",
+		Consistency:       consistency,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{})
+	viewers := make([]string, 0)
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
 				break
 			}
+			return nil, fmt.Errorf("failed to receive namespace viewers: %w", err)
+		}
 
-			rel := msg.Relationship
-			log.Printf("  %s:%s#%s@%s:%s",
-				rel.Resource.ObjectType,
-				rel.Resource.ObjectId,
-				rel.Relation,
-				rel.Subject.Object.ObjectType,
-				rel.Subject.Object.ObjectId)
-			resourceRelationshipCount++
+		subject := msg.GetSubject()
+		if subject.GetPermissionship() != v1.LookupPermissionship_LOOKUP_PERMISSIONSHIP_HAS_PERMISSION {
+			continue
+		}
+		if _, ok := seen[subject.GetSubjectObjectId()]; ok {
+			continue
 		}
-		log.Printf("Total %s relationships found: %d", resourceType, resourceRelationshipCount)
-		totalRelationshipCount += resourceRelationshipCount
+		seen[subject.GetSubjectObjectId()] = struct{}{}
+		viewers = append(viewers, subject.GetSubjectObjectId())
 	}
 
-	log.Printf("Total relationships found: %d", totalRelationshipCount)
-	log.Println("=== End SpiceDB Data Snapshot ===")
+	sort.Strings(viewers)
+	return viewers, nil
+}
+
+// namespaceUserRelationship builds the namespace:<namespace>#<relation>@user:<user> relationship
+func namespaceUserRelationship(namespace, relation, user string) *v1.Relationship {
+	return &v1.Relationship{
+		Resource: &v1.ObjectReference{
+			ObjectType: "namespace",
+			ObjectId:   namespace,
+		},
+		Relation: relation,
+		Subject: &v1.SubjectReference{
+			Object: &v1.ObjectReference{
+				ObjectType: "user",
+				ObjectId:   user,
+			},
+		},
+	}
 }
@@ -0,0 +1,475 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	kubeproxy "github.com/authzed/spicedb-kubeapi-proxy/pkg/proxy"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/internal/fakekube"
+)
+
+// The embedded proxy configures process-wide logging when it is created, so every test in
+// the package shares one proxy backed by one fake Kubernetes API. Tests use namespaces of
+// their own to stay independent.
+var (
+	testKube  *fakekube.Server
+	testProxy *SpiceDBKubeProxy
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	testKube = fakekube.New()
+	defer testKube.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var err error
+	testProxy, err = NewSpiceDBKubeProxy(ctx, testKube.RestConfig(),
+		WithInMemoryWorkflowDatabase(),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create proxy: %v\n", err)
+		return 1
+	}
+	if err := testProxy.Start(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start proxy: %v\n", err)
+		return 1
+	}
+	defer func() {
+		stopCtx, stopCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer stopCancel()
+		_ = testProxy.Stop(stopCtx)
+	}()
+
+	return m.Run()
+}
+
+// createNamespace creates namespace through the proxy as its creator
+func createNamespace(t testing.TB, creator, namespace string) {
+	t.Helper()
+	if err := testProxy.CreateNamespaceAsUser(context.Background(), creator, nil, namespace, false); err != nil {
+		t.Fatalf("create namespace %s as %s: %v", namespace, creator, err)
+	}
+}
+
+// createPod creates a pod through the proxy as username
+func createPod(t testing.TB, username, namespace, name string) {
+	t.Helper()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if _, err := testProxy.CreatePodAsUser(context.Background(), username, nil, namespace, pod); err != nil {
+		t.Fatalf("create pod %s/%s as %s: %v", namespace, name, username, err)
+	}
+}
+
+func listPods(t testing.TB, username, namespace string) []string {
+	t.Helper()
+	names, err := testProxy.ListPodsAsUser(context.Background(), username, nil, namespace)
+	if err != nil {
+		t.Fatalf("list pods in %s as %s: %v", namespace, username, err)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSameNamedPodsInDifferentNamespaces(t *testing.T) {
+	createNamespace(t, "pods-alice", "pods-a")
+	createNamespace(t, "pods-bob", "pods-b")
+
+	createPod(t, "pods-alice", "pods-a", "nginx")
+	// The same name in another namespace is a different SpiceDB object, so this create must not conflict
+	createPod(t, "pods-bob", "pods-b", "nginx")
+
+	if got := listPods(t, "pods-alice", "pods-a"); len(got) != 1 || got[0] != "nginx" {
+		t.Errorf("alice lists %v in pods-a, want [nginx]", got)
+	}
+	if got := listPods(t, "pods-bob", "pods-a"); len(got) != 0 {
+		t.Errorf("bob lists %v in pods-a, want none: the nginx in pods-b must not match", got)
+	}
+	if got := listPods(t, "pods-alice", "pods-b"); len(got) != 0 {
+		t.Errorf("alice lists %v in pods-b, want none", got)
+	}
+
+	resp, err := testProxy.CheckPermission(context.Background(), "pod", "pods-b/nginx", "view", "user", "pods-alice", NewConsistency(true, ""))
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if resp.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
+		t.Error("alice can view pods-b/nginx")
+	}
+}
+
+func TestCreateConfigMapNeedsNamespaceEdit(t *testing.T) {
+	createNamespace(t, "cm-alice", "cm-ns")
+	if _, err := testProxy.GrantViewPermission(context.Background(), "cm-ns", "cm-viewer", time.Time{}); err != nil {
+		t.Fatalf("grant view: %v", err)
+	}
+
+	for _, user := range []string{"cm-viewer", "cm-stranger"} {
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "settings-" + user}}
+		_, err := testProxy.CreateConfigMapAsUser(context.Background(), user, nil, "cm-ns", cm)
+		if !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("create as %s: error = %v, want ErrPermissionDenied", user, err)
+		}
+		if testKube.Has("configmaps", "cm-ns", cm.Name) {
+			t.Errorf("configmap created by %s", user)
+		}
+	}
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "settings"}}
+	if _, err := testProxy.CreateConfigMapAsUser(context.Background(), "cm-alice", nil, "cm-ns", cm); err != nil {
+		t.Fatalf("create as creator: %v", err)
+	}
+	names, err := testProxy.ListConfigMapsAsUser(context.Background(), "cm-alice", nil, "cm-ns")
+	if err != nil || len(names) != 1 || names[0] != "settings" {
+		t.Errorf("creator lists %v, %v; want [settings]", names, err)
+	}
+}
+
+func TestDeletePodKeepsSameNamedPodElsewhere(t *testing.T) {
+	createNamespace(t, "delpod-alice", "delpod-a")
+	createNamespace(t, "delpod-bob", "delpod-b")
+	createPod(t, "delpod-alice", "delpod-a", "web")
+	createPod(t, "delpod-bob", "delpod-b", "web")
+
+	if err := testProxy.DeletePodAsUser(context.Background(), "delpod-alice", nil, "delpod-a", "web"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if testKube.Has("pods", "delpod-a", "web") {
+		t.Error("delpod-a/web still exists")
+	}
+
+	// bob's pod keeps its relationships, so bob still sees and can delete it
+	if got := listPods(t, "delpod-bob", "delpod-b"); len(got) != 1 || got[0] != "web" {
+		t.Errorf("bob lists %v in delpod-b after alice's delete, want [web]", got)
+	}
+	if err := testProxy.DeletePodAsUser(context.Background(), "delpod-bob", nil, "delpod-b", "web"); err != nil {
+		t.Errorf("bob delete of delpod-b/web: %v", err)
+	}
+}
+
+func TestDeletePodDenied(t *testing.T) {
+	createNamespace(t, "denypod-alice", "denypod")
+	createPod(t, "denypod-alice", "denypod", "web")
+	if _, err := testProxy.GrantViewPermission(context.Background(), "denypod", "denypod-viewer", time.Time{}); err != nil {
+		t.Fatalf("grant view: %v", err)
+	}
+
+	err := testProxy.DeletePodAsUser(context.Background(), "denypod-viewer", nil, "denypod", "web")
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("viewer delete error = %v, want ErrPermissionDenied", err)
+	}
+	if !testKube.Has("pods", "denypod", "web") {
+		t.Error("pod was deleted by a viewer")
+	}
+}
+
+func TestDeletePodRemovesItsRelationships(t *testing.T) {
+	createNamespace(t, "relpod-alice", "relpod")
+	createPod(t, "relpod-alice", "relpod", "web")
+
+	if err := testProxy.DeletePodAsUser(context.Background(), "relpod-alice", nil, "relpod", "web"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	resp, err := testProxy.CheckPermission(context.Background(), "pod", "relpod/web", "view", "user", "relpod-alice", NewConsistency(true, ""))
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if resp.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
+		t.Error("creator still views the deleted pod")
+	}
+}
+
+func TestDeleteMissingPodNotFound(t *testing.T) {
+	createNamespace(t, "nopod-alice", "nopod")
+
+	err := testProxy.DeletePodAsUser(context.Background(), "nopod-alice", nil, "nopod", "ghost")
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("delete of a missing pod: error = %v, want NotFound", err)
+	}
+}
+
+func TestExpiringGrantLapses(t *testing.T) {
+	createNamespace(t, "temp-alice", "temp-ns")
+
+	canView := func() bool {
+		t.Helper()
+		resp, err := testProxy.CheckPermission(context.Background(), "namespace", "temp-ns", "view", "user", "temp-guest", NewConsistency(true, ""))
+		if err != nil {
+			t.Fatalf("check: %v", err)
+		}
+		return resp.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+	}
+
+	expiresAt := time.Now().Add(time.Second)
+	if _, err := testProxy.GrantViewPermission(context.Background(), "temp-ns", "temp-guest", expiresAt); err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+	if !canView() {
+		t.Fatal("guest cannot view before the grant expires")
+	}
+
+	time.Sleep(time.Until(expiresAt) + 500*time.Millisecond)
+	if canView() {
+		t.Error("guest can still view after the grant expired")
+	}
+	if names, _, err := testProxy.ListNamespacesAsUser(context.Background(), "temp-guest", nil, ListNamespacesOptions{}); err != nil || len(names) != 0 {
+		t.Errorf("guest lists %v, %v after the grant expired; want none", names, err)
+	}
+}
+
+func TestClusterAdminDeletesAnyNamespace(t *testing.T) {
+	createNamespace(t, "admindel-alice", "admindel-ns")
+	createNamespace(t, "admindel-alice", "admindel-other")
+
+	err := testProxy.DeleteNamespaceAsUser(context.Background(), "admindel-bob", nil, "admindel-ns")
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("delete by a non-admin: error = %v, want ErrPermissionDenied", err)
+	}
+
+	if err := testProxy.GrantClusterAdmin(context.Background(), "admindel-bob"); err != nil {
+		t.Fatalf("grant cluster admin: %v", err)
+	}
+	if err := testProxy.DeleteNamespaceAsUser(context.Background(), "admindel-bob", nil, "admindel-ns"); err != nil {
+		t.Fatalf("delete by a cluster admin: %v", err)
+	}
+	if testKube.Has("namespaces", "", "admindel-ns") {
+		t.Error("namespace still exists after the cluster admin deleted it")
+	}
+	if !testKube.Has("namespaces", "", "admindel-other") {
+		t.Error("another namespace was deleted")
+	}
+}
+
+func TestPodVerbsViewerVersusCreator(t *testing.T) {
+	createNamespace(t, "podverb-alice", "podverb")
+	createPod(t, "podverb-alice", "podverb", "web")
+	rels, err := ParseSeedRelationships([]string{"pod:podverb/web#viewer@user:podverb-viewer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := testProxy.SeedRelationships(context.Background(), rels); err != nil {
+		t.Fatalf("make viewer: %v", err)
+	}
+
+	get := func(user string) error {
+		client, err := testProxy.GetKubernetesClientForUser(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.CoreV1().Pods("podverb").Get(context.Background(), "web", metav1.GetOptions{})
+		return permissionError(err, "get pod")
+	}
+
+	tests := []struct {
+		user      string
+		canGet    bool
+		canList   bool
+		canDelete bool
+	}{
+		{user: "podverb-stranger"},
+		{user: "podverb-viewer", canGet: true, canList: true},
+		{user: "podverb-alice", canGet: true, canList: true, canDelete: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user, func(t *testing.T) {
+			if err := get(tt.user); (err == nil) != tt.canGet {
+				t.Errorf("get: error = %v, want allowed %v", err, tt.canGet)
+			}
+			if got := listPods(t, tt.user, "podverb"); (len(got) == 1) != tt.canList {
+				t.Errorf("list = %v, want web listed %v", got, tt.canList)
+			}
+			err := testProxy.DeletePodAsUser(context.Background(), tt.user, nil, "podverb", "web")
+			switch {
+			case tt.canDelete && err != nil:
+				t.Errorf("delete: %v", err)
+			case !tt.canDelete && !errors.Is(err, ErrPermissionDenied):
+				t.Errorf("delete: error = %v, want ErrPermissionDenied", err)
+			}
+		})
+	}
+}
+
+func TestCreateInForeignNamespaceDenied(t *testing.T) {
+	createNamespace(t, "foreign-alice", "foreign-a")
+	createNamespace(t, "foreign-bob", "foreign-b")
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "intruder"}}
+	if _, err := testProxy.CreatePodAsUser(context.Background(), "foreign-alice", nil, "foreign-b", pod); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("pod create in another user's namespace: error = %v, want ErrPermissionDenied", err)
+	}
+	if testKube.Has("pods", "foreign-b", "intruder") {
+		t.Error("pod created in another user's namespace")
+	}
+
+	httpClient, err := testProxy.embeddedClientForUser("foreign-alice", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dynamicClient, err := dynamic.NewForConfigAndClient(kubeproxy.EmbeddedRestConfig, httpClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testResources := dynamicClient.Resource(schema.GroupVersionResource{Group: "example.com", Version: "v1alpha1", Resource: "testresources"})
+	newTestResource := func(name string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "example.com/v1alpha1",
+			"kind":       "TestResource",
+			"metadata":   map[string]interface{}{"name": name},
+		}}
+	}
+
+	_, err = testResources.Namespace("foreign-b").Create(context.Background(), newTestResource("intruder"), metav1.CreateOptions{})
+	if !errors.Is(permissionError(err, "create testresource"), ErrPermissionDenied) {
+		t.Errorf("testresource create in another user's namespace: error = %v, want a denial", err)
+	}
+	if testKube.Has("testresources", "foreign-b", "intruder") {
+		t.Error("testresource created in another user's namespace")
+	}
+
+	if _, err := testResources.Namespace("foreign-a").Create(context.Background(), newTestResource("own"), metav1.CreateOptions{}); err != nil {
+		t.Errorf("testresource create in own namespace: %v", err)
+	}
+}
+
+// namespaceRelationships returns the namespace's relationships with relation, read fully consistently
+func namespaceRelationships(t *testing.T, namespace, relation string) []Relationship {
+	t.Helper()
+	rels, _, err := testProxy.ReadRelationships(context.Background(), RelationshipQuery{
+		ResourceType: "namespace",
+		ResourceID:   namespace,
+		Relation:     relation,
+		Consistency:  NewConsistency(true, ""),
+	})
+	if err != nil {
+		t.Fatalf("read %s relationships of %s: %v", relation, namespace, err)
+	}
+	return rels
+}
+
+// isNamespaceAdmin reports whether SpiceDB grants username admin on namespace
+func isNamespaceAdmin(t *testing.T, namespace, username string) bool {
+	t.Helper()
+	resp, err := testProxy.CheckPermission(context.Background(), "namespace", namespace, "admin", "user", username, NewConsistency(true, ""))
+	if err != nil {
+		t.Fatalf("check admin: %v", err)
+	}
+	return resp.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+}
+
+func TestConcurrentNamespaceCreate(t *testing.T) {
+	const (
+		namespace = "race-ns"
+		creators  = 8
+	)
+
+	errs := make([]error, creators)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = testProxy.CreateNamespaceAsUser(context.Background(), fmt.Sprintf("race-user-%d", i), nil, namespace, false)
+		}(i)
+	}
+	wg.Wait()
+
+	winner := ""
+	for i, err := range errs {
+		user := fmt.Sprintf("race-user-%d", i)
+		switch {
+		case err == nil && winner == "":
+			winner = user
+		case err == nil:
+			t.Errorf("both %s and %s created %s", winner, user, namespace)
+		case !errors.Is(err, ErrNamespaceExists):
+			t.Errorf("create as %s: error = %v, want ErrNamespaceExists", user, err)
+		}
+	}
+	if winner == "" {
+		t.Fatalf("no create of %s succeeded", namespace)
+	}
+	if !testKube.Has("namespaces", "", namespace) {
+		t.Fatal("namespace does not exist after a successful create")
+	}
+
+	creatorsSeen, err := testProxy.NamespaceCreators(context.Background(), namespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(creatorsSeen) != fmt.Sprint([]string{winner}) {
+		t.Errorf("creators = %v, want only %s", creatorsSeen, winner)
+	}
+	for i := 0; i < creators; i++ {
+		user := fmt.Sprintf("race-user-%d", i)
+		if admin := isNamespaceAdmin(t, namespace, user); admin != (user == winner) {
+			t.Errorf("%s admin of %s = %v, want %v", user, namespace, admin, user == winner)
+		}
+	}
+	// Losing creates must not roll back the winner's link to the cluster
+	if rels := namespaceRelationships(t, namespace, "cluster"); len(rels) != 1 {
+		t.Errorf("cluster relationships = %v, want the one the winner wrote", rels)
+	}
+}
+
+func TestCreateExistingNamespaceLeavesNoCreator(t *testing.T) {
+	// A namespace created outside the proxy before the request
+	testKube.Add("namespaces", "", "outside-ns", map[string]interface{}{})
+
+	err := testProxy.CreateNamespaceAsUser(context.Background(), "outside-alice", nil, "outside-ns", false)
+	if !errors.Is(err, ErrNamespaceExists) {
+		t.Fatalf("create of an existing namespace: error = %v, want ErrNamespaceExists", err)
+	}
+	if rels := namespaceRelationships(t, "outside-ns", "creator"); len(rels) != 0 {
+		t.Errorf("creator relationships = %v, want none", rels)
+	}
+	if isNamespaceAdmin(t, "outside-ns", "outside-alice") {
+		t.Error("caller became admin of a namespace they did not create")
+	}
+}
+
+func TestCreateRacingOutsideCreateLeavesNoCreator(t *testing.T) {
+	// The namespace is created outside the proxy after the existence check, just before the
+	// backend create, so the create rule has already written its relationships
+	var once sync.Once
+	testKube.SetBeforeCreate(func(resource, _ string) {
+		if resource == "namespaces" {
+			once.Do(func() { testKube.Add("namespaces", "", "racing-ns", map[string]interface{}{}) })
+		}
+	})
+	t.Cleanup(func() { testKube.SetBeforeCreate(nil) })
+
+	err := testProxy.CreateNamespaceAsUser(context.Background(), "racing-alice", nil, "racing-ns", false)
+	if !errors.Is(err, ErrNamespaceExists) {
+		t.Fatalf("create racing an outside create: error = %v, want ErrNamespaceExists", err)
+	}
+	if rels := namespaceRelationships(t, "racing-ns", "creator"); len(rels) != 0 {
+		t.Errorf("creator relationships = %v, want the create rule's removed", rels)
+	}
+	if rels := namespaceRelationships(t, "racing-ns", "cluster"); len(rels) != 0 {
+		t.Errorf("cluster relationships = %v, want the create rule's removed", rels)
+	}
+	if isNamespaceAdmin(t, "racing-ns", "racing-alice") {
+		t.Error("caller became admin of a namespace created outside the proxy")
+	}
+}
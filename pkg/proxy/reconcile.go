@@ -0,0 +1,222 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/metrics"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/requestid"
+)
+
+// ReconcilerConfig configures the periodic pruning of relationships whose Kubernetes object is gone
+type ReconcilerConfig struct {
+	// Interval between runs; zero or negative disables the reconciler
+	Interval time.Duration
+	// DryRun only logs the relationships that would be deleted
+	DryRun bool
+}
+
+// reconciledObjectGetter fetches the Kubernetes object behind a SpiceDB object ID from the backend
+type reconciledObjectGetter func(ctx context.Context, c *SpiceDBKubeProxy, id string) error
+
+// reconciledResourceTypes maps the definitions mirrored from Kubernetes objects to how their
+// objects are fetched. Namespaced objects use namespace/name IDs, as written by the rules.
+var reconciledResourceTypes = []struct {
+	resourceType string
+	get          reconciledObjectGetter
+}{
+	{"namespace", func(ctx context.Context, c *SpiceDBKubeProxy, id string) error {
+		_, err := c.kubeClient.CoreV1().Namespaces().Get(ctx, id, metav1.GetOptions{})
+		return err
+	}},
+	{"pod", namespacedGetter(func(ctx context.Context, c *SpiceDBKubeProxy, namespace, name string) error {
+		_, err := c.kubeClient.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})},
+	{"configmap", namespacedGetter(func(ctx context.Context, c *SpiceDBKubeProxy, namespace, name string) error {
+		_, err := c.kubeClient.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})},
+	{"deployment", namespacedGetter(func(ctx context.Context, c *SpiceDBKubeProxy, namespace, name string) error {
+		_, err := c.kubeClient.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})},
+}
+
+// namespacedGetter splits a namespace/name object ID before fetching the object
+func namespacedGetter(get func(ctx context.Context, c *SpiceDBKubeProxy, namespace, name string) error) reconciledObjectGetter {
+	return func(ctx context.Context, c *SpiceDBKubeProxy, id string) error {
+		namespace, name, ok := strings.Cut(id, "/")
+		if !ok || namespace == "" || name == "" {
+			return fmt.Errorf("object ID %q is not namespace/name", id)
+		}
+		return get(ctx, c, namespace, name)
+	}
+}
+
+// reconcileResult reports one reconciler run by resource type
+type reconcileResult struct {
+	// Orphaned lists objects confirmed missing from Kubernetes in two consecutive runs
+	Orphaned map[string][]string
+	// Pruned counts the relationships deleted for them, always zero in dry-run mode
+	Pruned map[string]uint64
+}
+
+// orphanReconciler remembers objects found missing in the previous run. An object must be
+// missing in two consecutive runs before its relationships are pruned, so relationships the
+// embedded proxy writes just before creating the object are not mistaken for orphans.
+type orphanReconciler struct {
+	proxy   *SpiceDBKubeProxy
+	dryRun  bool
+	missing map[string]bool
+}
+
+// StartReconciler starts a goroutine that prunes relationships of namespaces, pods, config
+// maps and deployments deleted directly in Kubernetes, every interval until ctx ends. The
+// returned channel is closed once the reconciler has stopped.
+func (c *SpiceDBKubeProxy) StartReconciler(ctx context.Context, cfg ReconcilerConfig) <-chan struct{} {
+	done := make(chan struct{})
+	if cfg.Interval <= 0 {
+		close(done)
+		return done
+	}
+
+	r := &orphanReconciler{proxy: c, dryRun: cfg.DryRun, missing: make(map[string]bool)}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		c.logger.Info("starting orphaned relationship reconciler", "interval", cfg.Interval, "dryRun", cfg.DryRun)
+		for {
+			select {
+			case <-ctx.Done():
+				c.logger.Info("orphaned relationship reconciler stopping")
+				return
+			case <-ticker.C:
+				result, err := r.run(ctx)
+				metrics.RecordReconcileRun(err)
+				if err != nil && ctx.Err() == nil {
+					c.logger.Error("orphaned relationship reconciliation failed", "error", err)
+				}
+				for resourceType, ids := range result.Orphaned {
+					metrics.RecordReconcileOrphans(resourceType, len(ids), result.Pruned[resourceType])
+				}
+			}
+		}
+	}()
+	return done
+}
+
+// run checks every object with relationships against Kubernetes and prunes confirmed orphans.
+// Objects whose lookup fails for any reason other than not found are left alone.
+func (r *orphanReconciler) run(ctx context.Context) (reconcileResult, error) {
+	c := r.proxy
+	result := reconcileResult{Orphaned: make(map[string][]string), Pruned: make(map[string]uint64)}
+	missing := make(map[string]bool)
+	// Carry the previous run's state over for types a failed read skips
+	defer func() { r.missing = missing }()
+
+	var errs []string
+	for _, rt := range reconciledResourceTypes {
+		ids, err := c.relationshipResourceIDs(ctx, rt.resourceType)
+		if err != nil {
+			errs = append(errs, err.Error())
+			for key := range r.missing {
+				if strings.HasPrefix(key, rt.resourceType+":") {
+					missing[key] = true
+				}
+			}
+			continue
+		}
+
+		for _, id := range ids {
+			getCtx, cancel := withTimeout(ctx, c.timeouts.Kubernetes)
+			err := rt.get(getCtx, c, id)
+			cancel()
+			if err == nil {
+				continue
+			}
+			if !apierrors.IsNotFound(err) {
+				c.logger.Debug("skipping object the reconciler could not look up", "resourceType", rt.resourceType, "id", id, "error", err)
+				continue
+			}
+
+			key := rt.resourceType + ":" + id
+			missing[key] = true
+			if !r.missing[key] {
+				continue
+			}
+			result.Orphaned[rt.resourceType] = append(result.Orphaned[rt.resourceType], id)
+
+			if r.dryRun {
+				c.logger.Info("would prune relationships of missing object", "resourceType", rt.resourceType, "id", id)
+				continue
+			}
+			deleted, err := c.deleteRelationshipsMatching(ctx, &v1.RelationshipFilter{
+				ResourceType:       rt.resourceType,
+				OptionalResourceId: id,
+			})
+			result.Pruned[rt.resourceType] += deleted
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("failed to prune %s:%s: %v", rt.resourceType, id, err))
+				continue
+			}
+			delete(missing, key)
+			c.logger.Info("pruned relationships of missing object", "resourceType", rt.resourceType, "id", id, "count", deleted)
+		}
+	}
+
+	if len(errs) > 0 {
+		return result, fmt.Errorf("reconciliation incomplete: %s", strings.Join(errs, "; "))
+	}
+	return result, nil
+}
+
+// relationshipResourceIDs returns the distinct IDs of resourceType objects that have relationships
+func (c *SpiceDBKubeProxy) relationshipResourceIDs(ctx context.Context, resourceType string) ([]string, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBRead)
+	defer cancel()
+
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return nil, fmt.Errorf("SpiceDB client not available")
+	}
+
+	start := time.Now()
+	stream, err := client.ReadRelationships(requestid.OutgoingContext(ctx), &v1.ReadRelationshipsRequest{
+		Consistency:        &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+		RelationshipFilter: &v1.RelationshipFilter{ResourceType: resourceType},
+	})
+	if err != nil {
+		metrics.ObserveSpiceDBCall("read_relationships", start, err)
+		return nil, fmt.Errorf("failed to read %s relationships: %w", resourceType, err)
+	}
+
+	seen := make(map[string]bool)
+	var ids []string
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			metrics.ObserveSpiceDBCall("read_relationships", start, err)
+			return nil, fmt.Errorf("failed to receive %s relationships: %w", resourceType, err)
+		}
+		id := msg.GetRelationship().GetResource().GetObjectId()
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	metrics.ObserveSpiceDBCall("read_relationships", start, nil)
+	return ids, nil
+}
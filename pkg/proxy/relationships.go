@@ -0,0 +1,234 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/metrics"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/requestid"
+)
+
+const (
+	// DefaultRelationshipPageSize is the page size used when a relationship query sets no limit
+	DefaultRelationshipPageSize = 100
+	// MaxRelationshipPageSize bounds the page size of a relationship query
+	MaxRelationshipPageSize = 1000
+	// MaxCountedRelationships bounds how many relationships CountRelationships reads before
+	// it stops and reports a lower bound
+	MaxCountedRelationships = 100000
+
+	// relationshipDeleteBatchSize bounds each DeleteRelationships call so large filters
+	// are removed in several short transactions instead of one that may time out
+	relationshipDeleteBatchSize = 1000
+)
+
+// RelationshipQuery filters and pages a ReadRelationships call. At least one filter field must be set.
+type RelationshipQuery struct {
+	ResourceType string
+	ResourceID   string
+	Relation     string
+	SubjectType  string
+	// Limit is the page size; zero selects DefaultRelationshipPageSize
+	Limit uint32
+	// Cursor continues from a previous page
+	Cursor string
+	// Consistency is the read consistency; nil uses SpiceDB's default
+	Consistency *v1.Consistency
+}
+
+// ReadRelationships returns one page of relationships matching q and the cursor for the
+// next page, which is empty when no more relationships remain
+func (c *SpiceDBKubeProxy) ReadRelationships(ctx context.Context, q RelationshipQuery) ([]Relationship, string, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBRead)
+	defer cancel()
+
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return nil, "", fmt.Errorf("SpiceDB client not available")
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultRelationshipPageSize
+	}
+	if limit > MaxRelationshipPageSize {
+		limit = MaxRelationshipPageSize
+	}
+
+	relationships, lastCursor, err := c.readRelationshipsPage(ctx, q, limit, q.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// A short page means the results are exhausted. A full page may have been the last one,
+	// so look ahead rather than hand out a cursor to an empty page.
+	if uint32(len(relationships)) < limit {
+		return relationships, "", nil
+	}
+	more, err := c.hasRelationshipsAfter(ctx, q, limit, lastCursor)
+	if err != nil {
+		return nil, "", err
+	}
+	if !more {
+		lastCursor = ""
+	}
+	return relationships, lastCursor, nil
+}
+
+// CountRelationships counts the relationships matching q, ignoring its limit and cursor.
+// Counting reads every match, so it stops after MaxCountedRelationships and then returns
+// that many with exact set to false.
+func (c *SpiceDBKubeProxy) CountRelationships(ctx context.Context, q RelationshipQuery) (count uint64, exact bool, err error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBRead)
+	defer cancel()
+
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return 0, false, fmt.Errorf("SpiceDB client not available")
+	}
+
+	var cursor string
+	for count < MaxCountedRelationships {
+		rels, lastCursor, err := c.readRelationshipsPage(ctx, q, MaxRelationshipPageSize, cursor)
+		if err != nil {
+			return 0, false, err
+		}
+		count += uint64(len(rels))
+		if len(rels) < MaxRelationshipPageSize {
+			return count, true, nil
+		}
+		cursor = lastCursor
+	}
+
+	// Exactly MaxCountedRelationships matches also end up here, so check for one more
+	more, err := c.hasRelationshipsAfter(ctx, q, MaxRelationshipPageSize, cursor)
+	if err != nil {
+		return 0, false, err
+	}
+	return count, !more, nil
+}
+
+// readRelationshipsPage reads up to limit relationships matching q's filters after cursor,
+// returning them with the cursor after the last one
+func (c *SpiceDBKubeProxy) readRelationshipsPage(ctx context.Context, q RelationshipQuery, limit uint32, cursor string) ([]Relationship, string, error) {
+	start := time.Now()
+	stream, err := c.GetSpiceDBClient().ReadRelationships(requestid.OutgoingContext(ctx), relationshipPageRequest(q, limit, cursor))
+	if err != nil {
+		metrics.ObserveSpiceDBCall("read_relationships", start, err)
+		return nil, "", err
+	}
+
+	relationships := make([]Relationship, 0)
+	var lastCursor string
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			metrics.ObserveSpiceDBCall("read_relationships", start, err)
+			return nil, "", fmt.Errorf("failed to receive relationships: %w", err)
+		}
+		relationships = append(relationships, relationshipFromProto(msg.Relationship))
+		lastCursor = msg.GetAfterResultCursor().GetToken()
+	}
+	metrics.ObserveSpiceDBCall("read_relationships", start, nil)
+	return relationships, lastCursor, nil
+}
+
+// hasRelationshipsAfter reports whether the page after cursor is non-empty. SpiceDB only
+// accepts a cursor with the arguments it was issued for, so the next page is requested with
+// the same limit, and the stream is dropped after its first relationship.
+func (c *SpiceDBKubeProxy) hasRelationshipsAfter(ctx context.Context, q RelationshipQuery, limit uint32, cursor string) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	stream, err := c.GetSpiceDBClient().ReadRelationships(requestid.OutgoingContext(ctx), relationshipPageRequest(q, limit, cursor))
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if err == io.EOF {
+		metrics.ObserveSpiceDBCall("read_relationships", start, nil)
+		return false, nil
+	}
+	metrics.ObserveSpiceDBCall("read_relationships", start, err)
+	if err != nil {
+		return false, fmt.Errorf("failed to read relationships: %w", err)
+	}
+	return true, nil
+}
+
+// relationshipPageRequest builds the ReadRelationships request for a page of q
+func relationshipPageRequest(q RelationshipQuery, limit uint32, cursor string) *v1.ReadRelationshipsRequest {
+	filter := &v1.RelationshipFilter{
+		ResourceType:       q.ResourceType,
+		OptionalResourceId: q.ResourceID,
+		OptionalRelation:   q.Relation,
+	}
+	if q.SubjectType != "" {
+		filter.OptionalSubjectFilter = &v1.SubjectFilter{SubjectType: q.SubjectType}
+	}
+
+	req := &v1.ReadRelationshipsRequest{
+		RelationshipFilter: filter,
+		OptionalLimit:      limit,
+		Consistency:        q.Consistency,
+	}
+	if cursor != "" {
+		req.OptionalCursor = &v1.Cursor{Token: cursor}
+	}
+	return req
+}
+
+// DeleteRelationships removes every relationship on resources of resourceType, optionally
+// narrowed to one resource ID and relation, and returns how many were deleted. Matches are
+// deleted in batches until none remain, each bounded by the SpiceDB write timeout; if ctx
+// ends first, the count deleted so far is returned with the error.
+func (c *SpiceDBKubeProxy) DeleteRelationships(ctx context.Context, resourceType, resourceID, relation string) (uint64, error) {
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return 0, fmt.Errorf("SpiceDB client not available")
+	}
+	if resourceType == "" {
+		return 0, fmt.Errorf("resource type is required")
+	}
+
+	return c.deleteRelationshipsMatching(ctx, &v1.RelationshipFilter{
+		ResourceType:       resourceType,
+		OptionalResourceId: resourceID,
+		OptionalRelation:   relation,
+	})
+}
+
+// deleteRelationshipsMatching deletes every relationship matching filter in batches, as
+// described for DeleteRelationships
+func (c *SpiceDBKubeProxy) deleteRelationshipsMatching(ctx context.Context, filter *v1.RelationshipFilter) (uint64, error) {
+	client := c.GetSpiceDBClient()
+	req := &v1.DeleteRelationshipsRequest{
+		RelationshipFilter:            filter,
+		OptionalLimit:                 relationshipDeleteBatchSize,
+		OptionalAllowPartialDeletions: true,
+	}
+
+	var deleted uint64
+	for {
+		batchCtx, cancel := withTimeout(ctx, c.timeouts.SpiceDBWrite)
+		start := time.Now()
+		resp, err := client.DeleteRelationships(requestid.OutgoingContext(batchCtx), req)
+		metrics.ObserveSpiceDBCall("delete_relationships", start, err)
+		cancel()
+		if err != nil {
+			return deleted, err
+		}
+
+		deleted += resp.GetRelationshipsDeletedCount()
+		if resp.GetDeletionProgress() != v1.DeleteRelationshipsResponse_DELETION_PROGRESS_PARTIAL {
+			return deleted, nil
+		}
+	}
+}
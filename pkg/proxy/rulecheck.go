@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/authzed/spicedb-kubeapi-proxy/pkg/config/proxyrule"
+	"github.com/authzed/spicedb-kubeapi-proxy/pkg/rules"
+	corev1 "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+)
+
+// ruleTemplate is one relationship template of a rule, labelled with the rule field it came from
+type ruleTemplate struct {
+	field string
+	tmpl  proxyrule.StringOrTemplate
+	// written templates must name a stored relation, since they write, delete or require
+	// relationships; the others are checked or looked up and may also name a permission
+	written bool
+}
+
+// ruleTemplates returns every relationship template in the rule
+func ruleTemplates(cfg proxyrule.Config) []ruleTemplate {
+	var templates []ruleTemplate
+	add := func(field string, tmpls []proxyrule.StringOrTemplate, written bool) {
+		for i, tmpl := range tmpls {
+			templates = append(templates, ruleTemplate{field: fmt.Sprintf("%s[%d]", field, i), tmpl: tmpl, written: written})
+		}
+	}
+
+	add("check", cfg.Checks, false)
+	add("postcheck", cfg.PostChecks, false)
+	for i, pf := range cfg.PreFilters {
+		if pf.LookupMatchingResources != nil {
+			add(fmt.Sprintf("prefilter[%d].lookupMatchingResources", i), []proxyrule.StringOrTemplate{*pf.LookupMatchingResources}, false)
+		}
+	}
+	for i, pf := range cfg.PostFilters {
+		if pf.CheckPermissionTemplate != nil {
+			add(fmt.Sprintf("postfilter[%d].checkPermissionTemplate", i), []proxyrule.StringOrTemplate{*pf.CheckPermissionTemplate}, false)
+		}
+	}
+	add("update.preconditionExists", cfg.Update.PreconditionExists, true)
+	add("update.preconditionDoesNotExist", cfg.Update.PreconditionDoesNotExist, true)
+	add("update.creates", cfg.Update.CreateRelationships, true)
+	add("update.touches", cfg.Update.TouchRelationships, true)
+	add("update.deletes", cfg.Update.DeleteRelationships, true)
+	add("update.deleteByFilter", cfg.Update.DeleteByFilter, true)
+	return templates
+}
+
+// validateRulesAgainstSchema cross-checks the definitions, relations and permissions named by
+// every rule template against schema, so a typo in a rule fails at startup rather than on the
+// first matching request. Parts filled in per request, such as {{name}} or $subjectType, and
+// tuple set expressions cannot be checked and are skipped.
+func validateRulesAgainstSchema(configs []proxyrule.Config, schema *compiler.CompiledSchema) error {
+	definitions := make(map[string]*corev1.NamespaceDefinition, len(schema.ObjectDefinitions))
+	for _, def := range schema.ObjectDefinitions {
+		definitions[def.GetName()] = def
+	}
+
+	var mismatches []string
+	for i, cfg := range configs {
+		for _, t := range ruleTemplates(cfg) {
+			for _, problem := range t.mismatches(definitions) {
+				mismatches = append(mismatches, fmt.Sprintf("rule %s %s %q: %s", ruleName(cfg, i), t.field, t.String(), problem))
+			}
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("proxy authorization rules do not match the SpiceDB schema: %s", strings.Join(mismatches, "; "))
+	}
+	return nil
+}
+
+// String renders the template as it was configured
+func (t ruleTemplate) String() string {
+	switch {
+	case t.tmpl.Template != "":
+		return t.tmpl.Template
+	case t.tmpl.TupleSet != "":
+		return t.tmpl.TupleSet
+	case t.tmpl.RelationshipTemplate != nil:
+		res, sub := t.tmpl.Resource, t.tmpl.Subject
+		s := fmt.Sprintf("%s:%s#%s@%s:%s", res.Type, res.ID, res.Relation, sub.Type, sub.ID)
+		if sub.Relation != "" {
+			s += "#" + sub.Relation
+		}
+		return s
+	}
+	return ""
+}
+
+// mismatches lists what the template names that definitions do not define
+func (t ruleTemplate) mismatches(definitions map[string]*corev1.NamespaceDefinition) []string {
+	var rel *rules.UncompiledRelExpr
+	switch {
+	case t.tmpl.TupleSet != "":
+		return nil
+	case t.tmpl.Template != "":
+		var err error
+		if rel, err = rules.ParseRelSring(t.tmpl.Template); err != nil {
+			return []string{err.Error()}
+		}
+	case t.tmpl.RelationshipTemplate != nil:
+		rel = &rules.UncompiledRelExpr{
+			ResourceType:     t.tmpl.Resource.Type,
+			ResourceRelation: t.tmpl.Resource.Relation,
+			SubjectType:      t.tmpl.Subject.Type,
+			SubjectRelation:  t.tmpl.Subject.Relation,
+		}
+	default:
+		return nil
+	}
+
+	var problems []string
+	if isLiteralTemplatePart(rel.ResourceType) {
+		def, ok := definitions[rel.ResourceType]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("definition %s is not in the schema", rel.ResourceType))
+		} else if isLiteralTemplatePart(rel.ResourceRelation) {
+			relation := findRelation(def, rel.ResourceRelation)
+			switch {
+			case relation == nil:
+				problems = append(problems, fmt.Sprintf("definition %s has no relation or permission %s", rel.ResourceType, rel.ResourceRelation))
+			case t.written && relation.GetUsersetRewrite() != nil:
+				problems = append(problems, fmt.Sprintf("%s#%s is a permission, but relationships can only be stored on relations", rel.ResourceType, rel.ResourceRelation))
+			case t.written && definitions[rel.SubjectType] != nil && !allowsSubjectType(relation, rel.SubjectType):
+				problems = append(problems, fmt.Sprintf("relation %s#%s does not allow subjects of type %s", rel.ResourceType, rel.ResourceRelation, rel.SubjectType))
+			}
+		}
+	}
+
+	if isLiteralTemplatePart(rel.SubjectType) {
+		def, ok := definitions[rel.SubjectType]
+		if !ok {
+			if rel.SubjectType == rel.ResourceType {
+				// Already reported for the resource
+				return problems
+			}
+			problems = append(problems, fmt.Sprintf("subject definition %s is not in the schema", rel.SubjectType))
+		} else if isLiteralTemplatePart(rel.SubjectRelation) && rel.SubjectRelation != "..." && findRelation(def, rel.SubjectRelation) == nil {
+			problems = append(problems, fmt.Sprintf("subject definition %s has no relation or permission %s", rel.SubjectType, rel.SubjectRelation))
+		}
+	}
+	return problems
+}
+
+// isLiteralTemplatePart reports whether a template part is fixed rather than filled in per
+// request by a {{...}} expression or a $ placeholder
+func isLiteralTemplatePart(s string) bool {
+	return s != "" && !strings.Contains(s, "{{") && !strings.HasPrefix(s, "$")
+}
+
+// findRelation returns the relation or permission called name on def, or nil
+func findRelation(def *corev1.NamespaceDefinition, name string) *corev1.Relation {
+	for _, rel := range def.GetRelation() {
+		if rel.GetName() == name {
+			return rel
+		}
+	}
+	return nil
+}
+
+// allowsSubjectType reports whether relation accepts subjects of subjectType directly
+func allowsSubjectType(relation *corev1.Relation, subjectType string) bool {
+	for _, allowed := range relation.GetTypeInformation().GetAllowedDirectRelations() {
+		if allowed.GetNamespace() == subjectType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,574 @@
+package proxy
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/authzed/spicedb-kubeapi-proxy/pkg/config/proxyrule"
+)
+
+// namespaceCreateRuleName is the rule whose relationships every namespace create writes
+const namespaceCreateRuleName = "create-namespaces"
+
+// WithRulesPath loads additional proxy authorization rules from a YAML file, or from every
+// .yaml/.yml file in a directory, using the spicedb-kubeapi-proxy ProxyRule format.
+// File rules replace default rules with the same metadata name and are appended otherwise.
+func WithRulesPath(path string) Option {
+	return func(o *options) {
+		o.rulesPath = path
+	}
+}
+
+// WithNamespaceCreateRelationships adds relationship templates written alongside the creator
+// whenever a namespace is created, such as namespace:{{name}}#viewer@group:platform#member
+// to let a platform team view every new namespace. Templates must grant on the new
+// namespace, may use {{name}} for it and {{user.name}} for its creator, and are checked
+// against the schema at startup.
+func WithNamespaceCreateRelationships(templates ...string) Option {
+	return func(o *options) {
+		o.namespaceCreateRelationships = append(o.namespaceCreateRelationships, templates...)
+	}
+}
+
+// testResourceGroupVersion serves the TestResource custom resource from deployment/testresource-crd.yaml,
+// which exercises the testresource schema definition without touching real workloads
+const testResourceGroupVersion = "example.com/v1alpha1"
+
+// defaultRuleConfigs returns the built-in authorization rules for namespaces, pods, configmaps and testresources
+func defaultRuleConfigs() []proxyrule.Config {
+	return []proxyrule.Config{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: namespaceCreateRuleName},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "v1",
+					Resource:     "namespaces",
+					Verbs:        []string{"create"},
+				}},
+				Update: proxyrule.Update{
+					CreateRelationships: []proxyrule.StringOrTemplate{{
+						Template: "namespace:{{name}}#creator@user:{{user.name}}",
+					}, {
+						// Link the namespace to the cluster so cluster admins can manage it
+						Template: "namespace:{{name}}#cluster@cluster:" + ClusterObjectID,
+					}},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "get-namespaces"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "v1",
+					Resource:     "namespaces",
+					Verbs:        []string{"get"},
+				}},
+				Checks: []proxyrule.StringOrTemplate{{
+					Template: "namespace:{{name}}#view@user:{{user.name}}",
+				}},
+			},
+		},
+		{
+			// Label and annotation changes need edit, so viewers cannot relabel a namespace
+			// even when Kubernetes RBAC would let them
+			ObjectMeta: metav1.ObjectMeta{Name: "update-namespaces"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "v1",
+					Resource:     "namespaces",
+					Verbs:        []string{"update", "patch"},
+				}},
+				Checks: []proxyrule.StringOrTemplate{{
+					Template: "namespace:{{name}}#edit@user:{{user.name}}",
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "list-namespaces"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "v1",
+					Resource:     "namespaces",
+					Verbs:        []string{"list"},
+				}},
+				PreFilters: []proxyrule.PreFilter{{
+					FromObjectIDNameExpr:    "{{resourceId}}",
+					LookupMatchingResources: &proxyrule.StringOrTemplate{Template: "namespace:$#view@user:{{user.name}}"},
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "delete-namespaces"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "v1",
+					Resource:     "namespaces",
+					Verbs:        []string{"delete"},
+				}},
+				Checks: []proxyrule.StringOrTemplate{{
+					Template: "namespace:{{name}}#admin@user:{{user.name}}",
+				}},
+				Update: proxyrule.Update{
+					// Remove every creator, editor and viewer of the deleted namespace, and its cluster link, so no stale tuples are left behind
+					DeleteByFilter: []proxyrule.StringOrTemplate{{
+						Template: "namespace:{{name}}#cluster@$subjectType:$subjectID",
+					}, {
+						Template: "namespace:{{name}}#creator@$subjectType:$subjectID",
+					}, {
+						Template: "namespace:{{name}}#editor@$subjectType:$subjectID",
+					}, {
+						Template: "namespace:{{name}}#viewer@$subjectType:$subjectID",
+					}},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "create-pods"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "v1",
+					Resource:     "pods",
+					Verbs:        []string{"create"},
+				}},
+				// Kubernetes RBAC alone would let a user create pods in namespaces they do not own
+				Checks: []proxyrule.StringOrTemplate{{
+					Template: "namespace:{{namespace}}#edit@user:{{user.name}}",
+				}},
+				Update: proxyrule.Update{
+					CreateRelationships: []proxyrule.StringOrTemplate{{
+						Template: "pod:{{namespacedName}}#creator@user:{{user.name}}",
+					}, {
+						Template: "pod:{{namespacedName}}#namespace@namespace:{{namespace}}",
+					}},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "get-pods"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "v1",
+					Resource:     "pods",
+					Verbs:        []string{"get"},
+				}},
+				Checks: []proxyrule.StringOrTemplate{{
+					Template: "pod:{{namespacedName}}#view@user:{{user.name}}",
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "delete-pods"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "v1",
+					Resource:     "pods",
+					Verbs:        []string{"delete"},
+				}},
+				Checks: []proxyrule.StringOrTemplate{{
+					Template: "pod:{{namespacedName}}#edit@user:{{user.name}}",
+				}},
+				Update: proxyrule.Update{
+					DeleteByFilter: []proxyrule.StringOrTemplate{{
+						Template: "pod:{{namespacedName}}#creator@$subjectType:$subjectID",
+					}, {
+						Template: "pod:{{namespacedName}}#viewer@$subjectType:$subjectID",
+					}, {
+						Template: "pod:{{namespacedName}}#namespace@$subjectType:$subjectID",
+					}},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "list-pods"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "v1",
+					Resource:     "pods",
+					Verbs:        []string{"list"},
+				}},
+				// Pod IDs are namespace/name so same-named pods in different namespaces stay distinct
+				PreFilters: []proxyrule.PreFilter{{
+					FromObjectIDNameExpr:      "{{split_name(resourceId)}}",
+					FromObjectIDNamespaceExpr: "{{split_namespace(resourceId)}}",
+					LookupMatchingResources:   &proxyrule.StringOrTemplate{Template: "pod:$#view@user:{{user.name}}"},
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "create-configmaps"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "v1",
+					Resource:     "configmaps",
+					Verbs:        []string{"create"},
+				}},
+				// As with pods, writing into a namespace needs edit on it
+				Checks: []proxyrule.StringOrTemplate{{
+					Template: "namespace:{{namespace}}#edit@user:{{user.name}}",
+				}},
+				Update: proxyrule.Update{
+					CreateRelationships: []proxyrule.StringOrTemplate{{
+						Template: "configmap:{{namespacedName}}#creator@user:{{user.name}}",
+					}, {
+						Template: "configmap:{{namespacedName}}#namespace@namespace:{{namespace}}",
+					}},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "get-configmaps"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "v1",
+					Resource:     "configmaps",
+					Verbs:        []string{"get"},
+				}},
+				Checks: []proxyrule.StringOrTemplate{{
+					Template: "configmap:{{namespacedName}}#view@user:{{user.name}}",
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "delete-configmaps"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "v1",
+					Resource:     "configmaps",
+					Verbs:        []string{"delete"},
+				}},
+				Checks: []proxyrule.StringOrTemplate{{
+					Template: "configmap:{{namespacedName}}#edit@user:{{user.name}}",
+				}},
+				Update: proxyrule.Update{
+					DeleteByFilter: []proxyrule.StringOrTemplate{{
+						Template: "configmap:{{namespacedName}}#creator@$subjectType:$subjectID",
+					}, {
+						Template: "configmap:{{namespacedName}}#viewer@$subjectType:$subjectID",
+					}, {
+						Template: "configmap:{{namespacedName}}#namespace@$subjectType:$subjectID",
+					}},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "list-configmaps"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "v1",
+					Resource:     "configmaps",
+					Verbs:        []string{"list"},
+				}},
+				// ConfigMap IDs are namespace/name so same-named ConfigMaps in different namespaces stay distinct
+				PreFilters: []proxyrule.PreFilter{{
+					FromObjectIDNameExpr:      "{{split_name(resourceId)}}",
+					FromObjectIDNamespaceExpr: "{{split_namespace(resourceId)}}",
+					LookupMatchingResources:   &proxyrule.StringOrTemplate{Template: "configmap:$#view@user:{{user.name}}"},
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "create-deployments"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "apps/v1",
+					Resource:     "deployments",
+					Verbs:        []string{"create"},
+				}},
+				// Deployments create pods, so like pods they need edit on the namespace
+				Checks: []proxyrule.StringOrTemplate{{
+					Template: "namespace:{{namespace}}#edit@user:{{user.name}}",
+				}},
+				Update: proxyrule.Update{
+					CreateRelationships: []proxyrule.StringOrTemplate{{
+						Template: "deployment:{{namespacedName}}#creator@user:{{user.name}}",
+					}, {
+						Template: "deployment:{{namespacedName}}#namespace@namespace:{{namespace}}",
+					}},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "get-deployments"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "apps/v1",
+					Resource:     "deployments",
+					Verbs:        []string{"get"},
+				}},
+				Checks: []proxyrule.StringOrTemplate{{
+					Template: "deployment:{{namespacedName}}#view@user:{{user.name}}",
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "delete-deployments"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "apps/v1",
+					Resource:     "deployments",
+					Verbs:        []string{"delete"},
+				}},
+				Checks: []proxyrule.StringOrTemplate{{
+					Template: "deployment:{{namespacedName}}#edit@user:{{user.name}}",
+				}},
+				Update: proxyrule.Update{
+					DeleteByFilter: []proxyrule.StringOrTemplate{{
+						Template: "deployment:{{namespacedName}}#creator@$subjectType:$subjectID",
+					}, {
+						Template: "deployment:{{namespacedName}}#viewer@$subjectType:$subjectID",
+					}, {
+						Template: "deployment:{{namespacedName}}#namespace@$subjectType:$subjectID",
+					}},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "list-deployments"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: "apps/v1",
+					Resource:     "deployments",
+					Verbs:        []string{"list"},
+				}},
+				PreFilters: []proxyrule.PreFilter{{
+					FromObjectIDNameExpr:      "{{split_name(resourceId)}}",
+					FromObjectIDNamespaceExpr: "{{split_namespace(resourceId)}}",
+					LookupMatchingResources:   &proxyrule.StringOrTemplate{Template: "deployment:$#view@user:{{user.name}}"},
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "create-testresources"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: testResourceGroupVersion,
+					Resource:     "testresources",
+					Verbs:        []string{"create"},
+				}},
+				Checks: []proxyrule.StringOrTemplate{{
+					Template: "namespace:{{namespace}}#edit@user:{{user.name}}",
+				}},
+				Update: proxyrule.Update{
+					CreateRelationships: []proxyrule.StringOrTemplate{{
+						Template: "testresource:{{namespacedName}}#creator@user:{{user.name}}",
+					}, {
+						Template: "testresource:{{namespacedName}}#namespace@namespace:{{namespace}}",
+					}},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "get-testresources"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: testResourceGroupVersion,
+					Resource:     "testresources",
+					Verbs:        []string{"get"},
+				}},
+				Checks: []proxyrule.StringOrTemplate{{
+					Template: "testresource:{{namespacedName}}#view@user:{{user.name}}",
+				}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "delete-testresources"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: testResourceGroupVersion,
+					Resource:     "testresources",
+					Verbs:        []string{"delete"},
+				}},
+				Checks: []proxyrule.StringOrTemplate{{
+					Template: "testresource:{{namespacedName}}#edit@user:{{user.name}}",
+				}},
+				Update: proxyrule.Update{
+					DeleteByFilter: []proxyrule.StringOrTemplate{{
+						Template: "testresource:{{namespacedName}}#creator@$subjectType:$subjectID",
+					}, {
+						Template: "testresource:{{namespacedName}}#viewer@$subjectType:$subjectID",
+					}, {
+						Template: "testresource:{{namespacedName}}#namespace@$subjectType:$subjectID",
+					}},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "list-testresources"},
+			Spec: proxyrule.Spec{
+				Matches: []proxyrule.Match{{
+					GroupVersion: testResourceGroupVersion,
+					Resource:     "testresources",
+					Verbs:        []string{"list"},
+				}},
+				PreFilters: []proxyrule.PreFilter{{
+					FromObjectIDNameExpr:      "{{split_name(resourceId)}}",
+					FromObjectIDNamespaceExpr: "{{split_namespace(resourceId)}}",
+					LookupMatchingResources:   &proxyrule.StringOrTemplate{Template: "testresource:$#view@user:{{user.name}}"},
+				}},
+			},
+		},
+	}
+}
+
+// loadRuleConfigs merges the rules found at path over the defaults and logs the active set
+func loadRuleConfigs(path string, logger *slog.Logger) ([]proxyrule.Config, error) {
+	configs := defaultRuleConfigs()
+	sources := make([]string, len(configs))
+	for i := range sources {
+		sources[i] = "embedded default"
+	}
+
+	if path != "" {
+		files, err := ruleFiles(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			fileConfigs, err := parseRuleFile(file)
+			if err != nil {
+				return nil, err
+			}
+			for _, cfg := range fileConfigs {
+				if i := ruleIndex(configs, cfg.Name); i >= 0 {
+					configs[i] = cfg
+					sources[i] = file
+					continue
+				}
+				configs = append(configs, cfg)
+				sources = append(sources, file)
+			}
+		}
+	}
+
+	logger.Info("loaded proxy authorization rules", "count", len(configs))
+	for i, cfg := range configs {
+		logger.Info("active proxy authorization rule", "rule", ruleName(cfg, i), "matches", describeMatches(cfg.Matches), "source", sources[i])
+	}
+	return configs, nil
+}
+
+// addNamespaceCreateRelationships appends templates to the create-namespaces rule, which may
+// have been replaced by a rules file
+func addNamespaceCreateRelationships(configs []proxyrule.Config, templates []string) error {
+	if len(templates) == 0 {
+		return nil
+	}
+	i := ruleIndex(configs, namespaceCreateRuleName)
+	if i < 0 {
+		return fmt.Errorf("no %s rule to add namespace create relationships to", namespaceCreateRuleName)
+	}
+
+	update := &configs[i].Spec.Update
+	for _, tmpl := range templates {
+		rel, err := namespaceCreateRelationship(tmpl, "namespace", "user")
+		if err != nil {
+			return err
+		}
+		if rel.GetResource().GetObjectType() != "namespace" || !strings.HasPrefix(tmpl, "namespace:{{name}}#") {
+			return fmt.Errorf("namespace create relationship %q must grant on namespace:{{name}}", tmpl)
+		}
+		for _, existing := range update.CreateRelationships {
+			// SpiceDB rejects a write that creates the same relationship twice
+			if existing.Template == tmpl {
+				return fmt.Errorf("namespace create relationship %q is already written by the %s rule", tmpl, namespaceCreateRuleName)
+			}
+		}
+		update.CreateRelationships = append(update.CreateRelationships, proxyrule.StringOrTemplate{Template: tmpl})
+	}
+	return nil
+}
+
+// namespaceCreateRelationship renders a namespace create relationship template for the
+// given namespace and creator
+func namespaceCreateRelationship(tmpl, namespace, username string) (*v1.Relationship, error) {
+	rendered := strings.NewReplacer("{{name}}", namespace, "{{user.name}}", username).Replace(tmpl)
+	if strings.Contains(rendered, "{{") || strings.Contains(rendered, "$") {
+		return nil, fmt.Errorf("namespace create relationship %q may only use {{name}} and {{user.name}}", tmpl)
+	}
+	rel, err := tuple.ParseV1Rel(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespace create relationship %q: %w", tmpl, err)
+	}
+	return rel, nil
+}
+
+// ruleFiles returns path itself, or the sorted YAML files contained in it when path is a directory
+func ruleFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules path %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules directory %s: %w", path, err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		files = append(files, filepath.Join(path, entry.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// parseRuleFile parses every ProxyRule document in file
+func parseRuleFile(file string) ([]proxyrule.Config, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rules file %s: %w", file, err)
+	}
+	defer f.Close()
+
+	configs, err := proxyrule.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %w", file, err)
+	}
+	return configs, nil
+}
+
+// ruleIndex returns the index of the named rule in configs, or -1 when name is empty or absent
+func ruleIndex(configs []proxyrule.Config, name string) int {
+	if name == "" {
+		return -1
+	}
+	for i, cfg := range configs {
+		if cfg.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ruleName returns the rule's metadata name, or a positional placeholder for unnamed rules
+func ruleName(cfg proxyrule.Config, i int) string {
+	if cfg.Name != "" {
+		return cfg.Name
+	}
+	return fmt.Sprintf("<unnamed-%d>", i)
+}
+
+// describeMatches renders rule matches as "group/version resource [verbs]" for logging
+func describeMatches(matches []proxyrule.Match) string {
+	parts := make([]string, 0, len(matches))
+	for _, m := range matches {
+		parts = append(parts, fmt.Sprintf("%s %s %v", m.GroupVersion, m.Resource, m.Verbs))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,234 @@
+package proxy
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	caveattypes "github.com/authzed/spicedb/pkg/caveats/types"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/validationfile"
+	"github.com/authzed/spicedb/pkg/validationfile/blocks"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/metrics"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/requestid"
+)
+
+// defaultBootstrap is the SpiceDB bootstrap used when no bootstrap file is configured.
+// It includes the lock, workflow and activity definitions required by the embedded proxy.
+//
+//go:embed bootstrap.yaml
+var defaultBootstrap []byte
+
+// WithBootstrapFile loads the SpiceDB bootstrap YAML (schema and relationships) from path,
+// such as a mounted ConfigMap, instead of the embedded default
+func WithBootstrapFile(path string) Option {
+	return func(o *options) {
+		o.bootstrapFile = path
+	}
+}
+
+// loadBootstrap returns the validated bootstrap content from path, or the embedded default
+// when path is empty, along with the schema it compiles to
+func loadBootstrap(path string) ([]byte, *compiler.CompiledSchema, error) {
+	content := defaultBootstrap
+	source := "embedded default"
+	if path != "" {
+		var err error
+		content, err = os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read bootstrap file %s: %w", path, err)
+		}
+		source = path
+	}
+
+	schema, err := compileBootstrapSchema(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid bootstrap schema in %s: %w", source, err)
+	}
+	return content, schema, nil
+}
+
+// compileBootstrapSchema parses bootstrap YAML and compiles the schema it contains
+func compileBootstrapSchema(content []byte) (*compiler.CompiledSchema, error) {
+	file, err := validationfile.DecodeValidationFile(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse bootstrap YAML: %w", err)
+	}
+	if file.Schema.Schema == "" {
+		return nil, fmt.Errorf("bootstrap YAML does not define a schema")
+	}
+
+	return validationfile.CompileSchema(file.Schema, caveattypes.TypeSetOrDefault(nil))
+}
+
+// ErrSchemaNotInitialized is returned when no schema has been written to SpiceDB yet
+var ErrSchemaNotInitialized = errors.New("schema not initialized")
+
+// ErrInvalidSchema is returned when a schema update does not compile
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// ErrSchemaOrphansRelationships is returned when a schema update removes definitions or
+// relations that existing relationships still use
+var ErrSchemaOrphansRelationships = errors.New("schema update would orphan existing relationships")
+
+// maxReportedOrphans bounds how many orphaned relationships are reported per removed definition or relation
+const maxReportedOrphans = 10
+
+// SchemaOrphanError lists relationships that a rejected schema update would have orphaned
+type SchemaOrphanError struct {
+	Relationships []Relationship
+}
+
+func (e *SchemaOrphanError) Error() string {
+	rels := make([]string, 0, len(e.Relationships))
+	for _, rel := range e.Relationships {
+		rels = append(rels, rel.String())
+	}
+	return fmt.Sprintf("%s: %s", ErrSchemaOrphansRelationships, strings.Join(rels, ", "))
+}
+
+func (e *SchemaOrphanError) Unwrap() error {
+	return ErrSchemaOrphansRelationships
+}
+
+// SchemaInfo is the active SpiceDB schema and the revision it was read at
+type SchemaInfo struct {
+	Schema   string `json:"schema"`
+	Revision string `json:"revision"`
+}
+
+// GetSchema reads the active schema text from SpiceDB
+func (c *SpiceDBKubeProxy) GetSchema(ctx context.Context) (*SchemaInfo, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBRead)
+	defer cancel()
+
+	if c.schemaClient == nil {
+		return nil, fmt.Errorf("SpiceDB schema client not available")
+	}
+
+	start := time.Now()
+	resp, err := c.schemaClient.ReadSchema(requestid.OutgoingContext(ctx), &v1.ReadSchemaRequest{})
+	metrics.ObserveSpiceDBCall("read_schema", start, err)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrSchemaNotInitialized
+		}
+		return nil, err
+	}
+	return &SchemaInfo{Schema: resp.GetSchemaText(), Revision: resp.GetReadAt().GetToken()}, nil
+}
+
+// SchemaRevision returns the ZedToken at which the active SpiceDB schema was read
+func (c *SpiceDBKubeProxy) SchemaRevision(ctx context.Context) (string, error) {
+	schema, err := c.GetSchema(ctx)
+	if err != nil {
+		return "", err
+	}
+	return schema.Revision, nil
+}
+
+// WriteSchema replaces the SpiceDB schema with schemaText and returns the revision it was
+// written at. The schema must compile, and removing a definition or relation that existing
+// relationships still use is refused with a *SchemaOrphanError listing some of them.
+func (c *SpiceDBKubeProxy) WriteSchema(ctx context.Context, schemaText string) (string, error) {
+	if c.schemaClient == nil {
+		return "", fmt.Errorf("SpiceDB schema client not available")
+	}
+
+	updated, err := compileSchemaText(schemaText)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+
+	current, err := c.GetSchema(ctx)
+	switch {
+	case errors.Is(err, ErrSchemaNotInitialized):
+		// Nothing can be orphaned without an existing schema
+	case err != nil:
+		return "", fmt.Errorf("failed to read current schema: %w", err)
+	default:
+		existing, err := compileSchemaText(current.Schema)
+		if err != nil {
+			return "", fmt.Errorf("failed to compile current schema: %w", err)
+		}
+		orphaned, err := c.orphanedRelationships(ctx, schemaRemovals(existing, updated))
+		if err != nil {
+			return "", err
+		}
+		if len(orphaned) > 0 {
+			return "", &SchemaOrphanError{Relationships: orphaned}
+		}
+	}
+
+	writeCtx, cancel := withTimeout(ctx, c.timeouts.SpiceDBWrite)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := c.schemaClient.WriteSchema(requestid.OutgoingContext(writeCtx), &v1.WriteSchemaRequest{Schema: schemaText})
+	metrics.ObserveSpiceDBCall("write_schema", start, err)
+	if err != nil {
+		return "", err
+	}
+
+	c.logger.InfoContext(ctx, "SpiceDB schema updated", "revision", resp.GetWrittenAt().GetToken())
+	return resp.GetWrittenAt().GetToken(), nil
+}
+
+// compileSchemaText compiles a schema as SpiceDB would when it is written
+func compileSchemaText(schemaText string) (*compiler.CompiledSchema, error) {
+	return validationfile.CompileSchema(blocks.SchemaWithPosition{Schema: schemaText}, caveattypes.TypeSetOrDefault(nil))
+}
+
+// schemaRemovals returns queries matching relationships whose definition or relation
+// exists in current but not in updated, including relationships with a removed subject type
+func schemaRemovals(current, updated *compiler.CompiledSchema) []RelationshipQuery {
+	kept := make(map[string]map[string]bool, len(updated.ObjectDefinitions))
+	for _, def := range updated.ObjectDefinitions {
+		relations := make(map[string]bool, len(def.GetRelation()))
+		for _, rel := range def.GetRelation() {
+			relations[rel.GetName()] = true
+		}
+		kept[def.GetName()] = relations
+	}
+
+	var removals []RelationshipQuery
+	for _, def := range current.ObjectDefinitions {
+		relations, ok := kept[def.GetName()]
+		if !ok {
+			removals = append(removals,
+				RelationshipQuery{ResourceType: def.GetName()},
+				RelationshipQuery{SubjectType: def.GetName()},
+			)
+			continue
+		}
+		for _, rel := range def.GetRelation() {
+			// Permissions are computed, so only relations can have stored relationships
+			if rel.GetUsersetRewrite() == nil && !relations[rel.GetName()] {
+				removals = append(removals, RelationshipQuery{ResourceType: def.GetName(), Relation: rel.GetName()})
+			}
+		}
+	}
+	return removals
+}
+
+// orphanedRelationships returns up to maxReportedOrphans relationships matching each query
+func (c *SpiceDBKubeProxy) orphanedRelationships(ctx context.Context, queries []RelationshipQuery) ([]Relationship, error) {
+	var orphaned []Relationship
+	for _, q := range queries {
+		q.Limit = maxReportedOrphans
+		rels, _, err := c.ReadRelationships(ctx, q)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for orphaned relationships: %w", err)
+		}
+		orphaned = append(orphaned, rels...)
+	}
+	return orphaned, nil
+}
@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/metrics"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/requestid"
+)
+
+// LoadSeedRelationships reads relationships to seed from a file with one relationship per
+// line in SpiceDB's text form, such as cluster:default#admin@user:alice. Blank lines and
+// lines starting with // or # are ignored.
+func LoadSeedRelationships(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read seed relationships file %s: %w", path, err)
+	}
+
+	var rels []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rels = append(rels, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read seed relationships file %s: %w", path, err)
+	}
+	return rels, nil
+}
+
+// ParseSeedRelationships parses relationships in SpiceDB's text form, reporting every invalid entry
+func ParseSeedRelationships(rels []string) ([]*v1.Relationship, error) {
+	parsed := make([]*v1.Relationship, 0, len(rels))
+	var invalid []string
+	for _, rel := range rels {
+		r, err := tuple.ParseV1Rel(rel)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%q: %v", rel, err))
+			continue
+		}
+		parsed = append(parsed, r)
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("invalid seed relationships: %s", strings.Join(invalid, "; "))
+	}
+	return parsed, nil
+}
+
+// SeedRelationships writes each relationship that does not exist yet and reports how many
+// were created and how many already existed, so seeding again after a restart is a no-op.
+// Relationships are created one at a time, since a single existing relationship fails a
+// whole CREATE batch and TOUCH cannot tell new relationships from existing ones.
+func (c *SpiceDBKubeProxy) SeedRelationships(ctx context.Context, rels []*v1.Relationship) (created, skipped int, err error) {
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return 0, 0, fmt.Errorf("SpiceDB client not available")
+	}
+
+	for _, rel := range rels {
+		writeCtx, cancel := withTimeout(ctx, c.timeouts.SpiceDBWrite)
+		start := time.Now()
+		_, err := client.WriteRelationships(requestid.OutgoingContext(writeCtx), &v1.WriteRelationshipsRequest{
+			Updates: []*v1.RelationshipUpdate{{
+				Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+				Relationship: rel,
+			}},
+		})
+		cancel()
+		if status.Code(err) == codes.AlreadyExists {
+			metrics.ObserveSpiceDBCall("write_relationships", start, nil)
+			skipped++
+			continue
+		}
+		metrics.ObserveSpiceDBCall("write_relationships", start, err)
+		if err != nil {
+			return created, skipped, fmt.Errorf("failed to seed %s: %w", tuple.MustV1RelString(rel), err)
+		}
+		created++
+	}
+	return created, skipped, nil
+}
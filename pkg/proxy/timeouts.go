@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"context"
+	"time"
+)
+
+// Timeouts bounds outbound calls by operation type, so a hung backend fails the request with
+// a deadline exceeded error instead of blocking it. Zero leaves that type of call unbounded.
+// Relationship watches are long-lived and never bounded.
+type Timeouts struct {
+	// SpiceDBRead bounds permission checks, lookups and relationship reads, including receiving the whole stream
+	SpiceDBRead time.Duration
+	// SpiceDBWrite bounds relationship and schema writes
+	SpiceDBWrite time.Duration
+	// Kubernetes bounds each Kubernetes API call, whether made through the embedded proxy or directly
+	Kubernetes time.Duration
+	// Printer bounds each snapshot taken by the SpiceDB data printer
+	Printer time.Duration
+	// SpiceDBCheck bounds each permission check, including the embedded proxy's and the wait for
+	// a concurrency slot, within the caller's deadline. Kept tighter than SpiceDBRead, a slow
+	// check fails fast with ErrAuthorizationTimeout and the client can retry.
+	SpiceDBCheck time.Duration
+}
+
+// DefaultTimeouts returns the timeouts used when none are configured
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		SpiceDBRead:  10 * time.Second,
+		SpiceDBWrite: 10 * time.Second,
+		Kubernetes:   30 * time.Second,
+		Printer:      30 * time.Second,
+		SpiceDBCheck: 3 * time.Second,
+	}
+}
+
+// WithTimeouts replaces DefaultTimeouts for outbound SpiceDB and Kubernetes calls
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(o *options) {
+		o.timeouts = timeouts
+	}
+}
+
+// withTimeout bounds ctx by d, or only makes it cancellable when d is zero
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,312 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/metrics"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/requestid"
+)
+
+// ErrUserOwnsResources is returned by DeleteUserData when the user still created resources and no new owner was given
+var ErrUserOwnsResources = errors.New("user still owns resources")
+
+var (
+	// userDataResourceTypes are the definitions on which a user can hold relationships
+	userDataResourceTypes = []string{"namespace", "pod", "configmap", "deployment", "testresource", "group", "cluster"}
+	// creatorResourceTypes are the definitions whose creator relation makes a user their owner
+	creatorResourceTypes = []string{"namespace", "pod", "configmap", "deployment", "testresource"}
+)
+
+// reassignBatchSize bounds the resources reassigned by one WriteRelationships call, each
+// taking a delete and a touch, so a call stays within SpiceDB's default update limit
+const reassignBatchSize = 500
+
+// UserDataDeletion reports, per definition, what DeleteUserData did
+type UserDataDeletion struct {
+	// Deleted counts the relationships removed where the user was the subject
+	Deleted map[string]uint64 `json:"deleted"`
+	// Reassigned counts the resources whose creator relation moved to the new owner
+	Reassigned map[string]int `json:"reassigned,omitempty"`
+}
+
+// DeleteUserData removes every relationship in which user is the subject, across
+// userDataResourceTypes. Resources the user created keep an owner: they are reassigned to
+// newOwner first, or, when newOwner is empty, nothing is deleted and ErrUserOwnsResources is
+// returned. Reassignment and deletion are separate transactions, so a failure part way
+// leaves the grants written so far in place; running it again finishes the job.
+func (c *SpiceDBKubeProxy) DeleteUserData(ctx context.Context, user, newOwner string) (*UserDataDeletion, error) {
+	client := c.GetSpiceDBClient()
+	if client == nil {
+		return nil, fmt.Errorf("SpiceDB client not available")
+	}
+	if user == "" {
+		return nil, fmt.Errorf("user is required")
+	}
+	if newOwner == user {
+		return nil, fmt.Errorf("cannot reassign resources of user %s to the same user", user)
+	}
+
+	owned := make(map[string][]string)
+	total := 0
+	for _, resourceType := range creatorResourceTypes {
+		ids, err := c.userCreatedResources(ctx, resourceType, user)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) > 0 {
+			owned[resourceType] = ids
+			total += len(ids)
+		}
+	}
+	if total > 0 && newOwner == "" {
+		return nil, fmt.Errorf("%w: user %s created %d resource(s); set a new owner to reassign them", ErrUserOwnsResources, user, total)
+	}
+
+	result := &UserDataDeletion{Deleted: make(map[string]uint64), Reassigned: make(map[string]int)}
+	for resourceType, ids := range owned {
+		if err := c.reassignCreator(ctx, resourceType, ids, user, newOwner); err != nil {
+			return result, fmt.Errorf("failed to reassign %s resources to %s: %w", resourceType, newOwner, err)
+		}
+		result.Reassigned[resourceType] = len(ids)
+	}
+
+	for _, resourceType := range userDataResourceTypes {
+		deleted, err := c.deleteRelationshipsMatching(ctx, &v1.RelationshipFilter{
+			ResourceType: resourceType,
+			OptionalSubjectFilter: &v1.SubjectFilter{
+				SubjectType:       "user",
+				OptionalSubjectId: user,
+			},
+		})
+		result.Deleted[resourceType] = deleted
+		if err != nil {
+			return result, fmt.Errorf("failed to delete %s relationships of user %s: %w", resourceType, user, err)
+		}
+	}
+	return result, nil
+}
+
+// userCreatedResources returns the IDs of resources of resourceType on which user holds the creator relation
+func (c *SpiceDBKubeProxy) userCreatedResources(ctx context.Context, resourceType, user string) ([]string, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBRead)
+	defer cancel()
+
+	start := time.Now()
+	stream, err := c.GetSpiceDBClient().ReadRelationships(requestid.OutgoingContext(ctx), &v1.ReadRelationshipsRequest{
+		Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+		RelationshipFilter: &v1.RelationshipFilter{
+			ResourceType:     resourceType,
+			OptionalRelation: "creator",
+			OptionalSubjectFilter: &v1.SubjectFilter{
+				SubjectType:       "user",
+				OptionalSubjectId: user,
+			},
+		},
+	})
+	if err != nil {
+		metrics.ObserveSpiceDBCall("read_relationships", start, err)
+		return nil, fmt.Errorf("failed to read %s creators: %w", resourceType, err)
+	}
+
+	var ids []string
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			metrics.ObserveSpiceDBCall("read_relationships", start, err)
+			return nil, fmt.Errorf("failed to receive %s creators: %w", resourceType, err)
+		}
+		ids = append(ids, msg.GetRelationship().GetResource().GetObjectId())
+	}
+	metrics.ObserveSpiceDBCall("read_relationships", start, nil)
+	return ids, nil
+}
+
+// reassignCreator moves the creator relation on the given resources from oldOwner to newOwner
+func (c *SpiceDBKubeProxy) reassignCreator(ctx context.Context, resourceType string, ids []string, oldOwner, newOwner string) error {
+	for len(ids) > 0 {
+		batch := ids
+		if len(batch) > reassignBatchSize {
+			batch = ids[:reassignBatchSize]
+		}
+		ids = ids[len(batch):]
+
+		updates := make([]*v1.RelationshipUpdate, 0, 2*len(batch))
+		for _, id := range batch {
+			updates = append(updates,
+				&v1.RelationshipUpdate{
+					Operation:    v1.RelationshipUpdate_OPERATION_DELETE,
+					Relationship: creatorRelationship(resourceType, id, oldOwner),
+				},
+				&v1.RelationshipUpdate{
+					Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+					Relationship: creatorRelationship(resourceType, id, newOwner),
+				},
+			)
+		}
+
+		writeCtx, cancel := withTimeout(ctx, c.timeouts.SpiceDBWrite)
+		start := time.Now()
+		_, err := c.GetSpiceDBClient().WriteRelationships(requestid.OutgoingContext(writeCtx), &v1.WriteRelationshipsRequest{Updates: updates})
+		metrics.ObserveSpiceDBCall("write_relationships", start, err)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// creatorRelationship builds resourceType:id#creator@user:user
+func creatorRelationship(resourceType, id, user string) *v1.Relationship {
+	return &v1.Relationship{
+		Resource: &v1.ObjectReference{
+			ObjectType: resourceType,
+			ObjectId:   id,
+		},
+		Relation: "creator",
+		Subject: &v1.SubjectReference{
+			Object: &v1.ObjectReference{
+				ObjectType: "user",
+				ObjectId:   user,
+			},
+		},
+	}
+}
+
+// SubjectMerge reports, per definition, what MergeUserSubjects did
+type SubjectMerge struct {
+	// Moved counts the relationships rewritten from the old subject to the new one
+	Moved map[string]int `json:"moved"`
+	// Dropped counts the old subject's relationships the new subject already held, which were only deleted
+	Dropped map[string]int `json:"dropped,omitempty"`
+}
+
+// MergeUserSubjects moves every relationship in which user:from is the subject, across
+// userDataResourceTypes, to user:to, keeping caveats and expirations. Where user:to already
+// holds the same relation on a resource, its own relationship is kept and the old one is
+// only deleted. Each batch is one transaction, so a failure part way leaves the batches
+// moved so far in place; running it again finishes the job.
+func (c *SpiceDBKubeProxy) MergeUserSubjects(ctx context.Context, from, to string) (*SubjectMerge, error) {
+	if c.GetSpiceDBClient() == nil {
+		return nil, fmt.Errorf("SpiceDB client not available")
+	}
+	if from == "" || to == "" {
+		return nil, fmt.Errorf("both users are required")
+	}
+	if from == to {
+		return nil, fmt.Errorf("cannot merge user %s into the same user", from)
+	}
+
+	result := &SubjectMerge{Moved: make(map[string]int), Dropped: make(map[string]int)}
+	for _, resourceType := range userDataResourceTypes {
+		rels, err := c.userSubjectRelationships(ctx, resourceType, from)
+		if err != nil {
+			return result, err
+		}
+		if len(rels) == 0 {
+			continue
+		}
+		existing, err := c.userSubjectRelationships(ctx, resourceType, to)
+		if err != nil {
+			return result, err
+		}
+		held := make(map[string]bool, len(existing))
+		for _, rel := range existing {
+			held[rel.GetResource().GetObjectId()+"#"+rel.GetRelation()] = true
+		}
+
+		for len(rels) > 0 {
+			batch := rels
+			if len(batch) > reassignBatchSize {
+				batch = rels[:reassignBatchSize]
+			}
+			rels = rels[len(batch):]
+
+			updates := make([]*v1.RelationshipUpdate, 0, 2*len(batch))
+			moved, dropped := 0, 0
+			for _, rel := range batch {
+				updates = append(updates, &v1.RelationshipUpdate{
+					Operation:    v1.RelationshipUpdate_OPERATION_DELETE,
+					Relationship: rel,
+				})
+				if held[rel.GetResource().GetObjectId()+"#"+rel.GetRelation()] {
+					dropped++
+					continue
+				}
+				updates = append(updates, &v1.RelationshipUpdate{
+					Operation: v1.RelationshipUpdate_OPERATION_TOUCH,
+					Relationship: &v1.Relationship{
+						Resource: rel.GetResource(),
+						Relation: rel.GetRelation(),
+						Subject: &v1.SubjectReference{
+							Object: &v1.ObjectReference{ObjectType: "user", ObjectId: to},
+						},
+						OptionalCaveat:    rel.GetOptionalCaveat(),
+						OptionalExpiresAt: rel.GetOptionalExpiresAt(),
+					},
+				})
+				moved++
+			}
+
+			writeCtx, cancel := withTimeout(ctx, c.timeouts.SpiceDBWrite)
+			start := time.Now()
+			_, err := c.GetSpiceDBClient().WriteRelationships(requestid.OutgoingContext(writeCtx), &v1.WriteRelationshipsRequest{Updates: updates})
+			metrics.ObserveSpiceDBCall("write_relationships", start, err)
+			cancel()
+			if err != nil {
+				return result, fmt.Errorf("failed to merge %s relationships of user %s into %s: %w", resourceType, from, to, err)
+			}
+			result.Moved[resourceType] += moved
+			if dropped > 0 {
+				result.Dropped[resourceType] += dropped
+			}
+		}
+	}
+	return result, nil
+}
+
+// userSubjectRelationships returns the relationships on resourceType whose subject is user
+func (c *SpiceDBKubeProxy) userSubjectRelationships(ctx context.Context, resourceType, user string) ([]*v1.Relationship, error) {
+	ctx, cancel := withTimeout(ctx, c.timeouts.SpiceDBRead)
+	defer cancel()
+
+	start := time.Now()
+	stream, err := c.GetSpiceDBClient().ReadRelationships(requestid.OutgoingContext(ctx), &v1.ReadRelationshipsRequest{
+		Consistency: &v1.Consistency{Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true}},
+		RelationshipFilter: &v1.RelationshipFilter{
+			ResourceType: resourceType,
+			OptionalSubjectFilter: &v1.SubjectFilter{
+				SubjectType:       "user",
+				OptionalSubjectId: user,
+			},
+		},
+	})
+	if err != nil {
+		metrics.ObserveSpiceDBCall("read_relationships", start, err)
+		return nil, fmt.Errorf("failed to read %s relationships of user %s: %w", resourceType, user, err)
+	}
+
+	var rels []*v1.Relationship
+	for {
+		msg, err := stream.Recv()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			metrics.ObserveSpiceDBCall("read_relationships", start, err)
+			return nil, fmt.Errorf("failed to receive %s relationships of user %s: %w", resourceType, user, err)
+		}
+		rels = append(rels, msg.GetRelationship())
+	}
+	metrics.ObserveSpiceDBCall("read_relationships", start, nil)
+	return rels, nil
+}
@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/requestid"
+)
+
+// RelationshipUpdate is one relationship change read from the SpiceDB watch stream
+type RelationshipUpdate struct {
+	// Operation is one of create, touch or delete
+	Operation    string       `json:"operation"`
+	Relationship Relationship `json:"relationship"`
+	// ChangesThrough is the ZedToken of the revision that contains the change
+	ChangesThrough string `json:"changesThrough,omitempty"`
+}
+
+// WatchRelationships streams relationship changes for the given object types, or all types
+// when none are given, calling fn for each change. It returns nil when ctx is cancelled or
+// SpiceDB ends the stream, and stops early with the error returned by fn.
+func (c *SpiceDBKubeProxy) WatchRelationships(ctx context.Context, objectTypes []string, fn func(RelationshipUpdate) error) error {
+	if c.watchClient == nil {
+		return fmt.Errorf("SpiceDB watch client not available")
+	}
+
+	stream, err := c.watchClient.Watch(requestid.OutgoingContext(ctx), &v1.WatchRequest{
+		OptionalObjectTypes: objectTypes,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start watch: %w", err)
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil || status.Code(err) == codes.Canceled {
+				return nil
+			}
+			return fmt.Errorf("watch stream failed: %w", err)
+		}
+
+		for _, update := range resp.GetUpdates() {
+			op := strings.ToLower(strings.TrimPrefix(update.GetOperation().String(), "OPERATION_"))
+			if err := fn(RelationshipUpdate{
+				Operation:      op,
+				Relationship:   relationshipFromProto(update.GetRelationship()),
+				ChangesThrough: resp.GetChangesThrough().GetToken(),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package requestid
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// Header is the HTTP header that carries the request ID in and out of the server
+	Header = "X-Request-ID"
+	// metadataKey is the gRPC metadata key used to forward the request ID to SpiceDB
+	metadataKey = "x-request-id"
+	// maxLength bounds client-supplied request IDs so they cannot bloat logs
+	maxLength = 128
+)
+
+// contextKey is an unexported type for context keys defined in this package,
+// preventing collisions with keys defined elsewhere
+type contextKey struct{}
+
+// requestIDContextKey is the context key for the request ID
+var requestIDContextKey = contextKey{}
+
+// NewContext returns a copy of ctx carrying id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, id)
+}
+
+// GetRequestIDFromContext retrieves the request ID from context
+func GetRequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDContextKey).(string)
+	return id, ok && id != ""
+}
+
+// Middleware reuses the caller's X-Request-ID or generates a UUID, stores it in the
+// request context and echoes it in the response header
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if id == "" || len(id) > maxLength {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+	})
+}
+
+// OutgoingContext attaches the request ID in ctx, if any, to outgoing gRPC metadata
+func OutgoingContext(ctx context.Context) context.Context {
+	if id, ok := GetRequestIDFromContext(ctx); ok {
+		return metadata.AppendToOutgoingContext(ctx, metadataKey, id)
+	}
+	return ctx
+}
+
+// logHandler adds the request ID from the log call's context to every record
+type logHandler struct {
+	slog.Handler
+}
+
+// NewLogHandler wraps h so that records logged with a request context include a request_id attribute
+func NewLogHandler(h slog.Handler) slog.Handler {
+	return &logHandler{Handler: h}
+}
+
+func (h *logHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id, ok := GetRequestIDFromContext(ctx); ok {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &logHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	return &logHandler{Handler: h.Handler.WithGroup(name)}
+}
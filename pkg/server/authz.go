@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/audit"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/auth"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/proxy"
+)
+
+// requireClusterAdmin returns ErrPermissionDenied unless Kubernetes RBAC grants the user every verb on every resource
+func requireClusterAdmin(ctx context.Context, p *proxy.SpiceDBKubeProxy, user *auth.UserInfo) error {
+	if err := requireKubernetesPermission(ctx, p, user, "*", "*", ""); err != nil {
+		return fmt.Errorf("cluster admin required: %w", err)
+	}
+	return nil
+}
+
+// requireSelfOrClusterAdmin allows checks of the caller's own SpiceDB access, and requires a
+// cluster admin to check anyone else's, so users cannot probe what others can reach
+func requireSelfOrClusterAdmin(ctx context.Context, p *proxy.SpiceDBKubeProxy, user *auth.UserInfo, subjectType, subjectID string) error {
+	if subjectType == "user" && subjectID == sanitizeUserName(user.Username) {
+		return nil
+	}
+	return requireClusterAdmin(ctx, p, user)
+}
+
+// requireKubernetesPermission returns ErrPermissionDenied unless Kubernetes RBAC allows the action
+func requireKubernetesPermission(ctx context.Context, p *proxy.SpiceDBKubeProxy, user *auth.UserInfo, resource, verb, namespace string) error {
+	allowed, err := p.CheckKubernetesPermission(ctx, user, resource, verb, namespace)
+	if err != nil {
+		return fmt.Errorf("permission check failed: %w", err)
+	}
+	if !allowed {
+		return fmt.Errorf("%w: user does not have permission to %s %s", proxy.ErrPermissionDenied, verb, resource)
+	}
+	return nil
+}
+
+// auditSpiceDBDecision records the outcome of an operation the embedded proxy authorized
+// against SpiceDB. Denials surface as errors from the operation; errors unrelated to
+// authorization, such as a missing object, are not decisions and are not recorded.
+func auditSpiceDBDecision(ctx context.Context, p *proxy.SpiceDBKubeProxy, user *auth.UserInfo, resource, verb, namespace string, err error) {
+	switch {
+	case err == nil:
+		p.RecordAuthorization(ctx, audit.AuthorizerSpiceDB, user, resource, verb, namespace, true, nil)
+	case errors.Is(err, proxy.ErrPermissionDenied), apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		p.RecordAuthorization(ctx, audit.AuthorizerSpiceDB, user, resource, verb, namespace, false, nil)
+	}
+}
+
+// requireGroupPermission returns ErrPermissionDenied unless the user holds permission on
+// group in SpiceDB or is a cluster admin, who can manage every group. The check is fully
+// consistent so a removed group admin loses access immediately.
+func requireGroupPermission(ctx context.Context, p *proxy.SpiceDBKubeProxy, user *auth.UserInfo, group, permission string) error {
+	resp, err := p.CheckPermission(ctx, "group", group, permission, "user", sanitizeUserName(user.Username), proxy.NewConsistency(true, ""))
+	if err != nil {
+		return fmt.Errorf("permission check failed: %w", err)
+	}
+	if resp.GetPermissionship() == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
+		return nil
+	}
+	if err := requireClusterAdmin(ctx, p, user); err != nil {
+		if errors.Is(err, proxy.ErrPermissionDenied) {
+			return fmt.Errorf("%w: user does not have %s permission on group %s", proxy.ErrPermissionDenied, permission, group)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/api"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/auth"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/proxy"
+)
+
+// maxBatchOperations bounds the number of operations accepted in one batch request
+const maxBatchOperations = 100
+
+// errInvalidBatchOperation marks batch items rejected before running, such as unknown ops or missing params
+var errInvalidBatchOperation = errors.New("invalid batch operation")
+
+// batchHandler runs a list of operations in order for the authenticated user. Each item runs
+// its own permission check and a failing item does not stop the rest of the batch.
+func batchHandler(kubeProxy *proxy.SpiceDBKubeProxy) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.BatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if len(req.Operations) == 0 {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "At least one operation is required"})
+			return
+		}
+		if len(req.Operations) > maxBatchOperations {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: fmt.Sprintf("At most %d operations are allowed per batch", maxBatchOperations)})
+			return
+		}
+
+		results := make([]api.BatchResult, len(req.Operations))
+		failed := 0
+		for i, op := range req.Operations {
+			data, err := runBatchOperation(r.Context(), kubeProxy, user, op)
+			results[i] = api.BatchResult{Index: i, Op: op.Op, Success: err == nil, Status: http.StatusOK, Data: data}
+			if err != nil {
+				failed++
+				results[i].Status = batchStatusForError(err)
+				results[i].Code = codeForError(err, results[i].Status)
+				results[i].Error = err.Error()
+			}
+		}
+
+		// The batch itself succeeded; per-item outcomes are reported in results
+		writeJSON(w, http.StatusOK, api.Response{
+			Success: failed == 0,
+			Data: map[string]interface{}{
+				"results":   results,
+				"succeeded": len(results) - failed,
+				"failed":    failed,
+			},
+		})
+	}
+}
+
+// batchStatusForError maps a batch item error to the HTTP status the equivalent endpoint would return
+func batchStatusForError(err error) int {
+	if errors.Is(err, errInvalidBatchOperation) {
+		return http.StatusBadRequest
+	}
+	return statusForError(err)
+}
+
+// runBatchOperation performs one batch item with the same permission checks as its standalone endpoint
+func runBatchOperation(ctx context.Context, p *proxy.SpiceDBKubeProxy, user *auth.UserInfo, op api.BatchOperation) (interface{}, error) {
+	username := sanitizeUserName(user.Username)
+
+	switch op.Op {
+	case "create-namespace":
+		var req api.CreateNamespaceRequest
+		if err := decodeBatchParams(op, &req); err != nil {
+			return nil, err
+		}
+		if req.Namespace == "" {
+			return nil, fmt.Errorf("%w: namespace is required", errInvalidBatchOperation)
+		}
+		if err := validateNamespaceName(req.Namespace); err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidBatchOperation, err)
+		}
+		if err := requireKubernetesPermission(ctx, p, user, "namespaces", "create", ""); err != nil {
+			return nil, err
+		}
+		if err := p.CreateNamespaceAsUser(ctx, username, user.Groups, req.Namespace, req.DryRun); err != nil {
+			return nil, err
+		}
+		if req.DryRun {
+			return map[string]interface{}{"namespace": req.Namespace, "dryRun": true, "allowed": true}, nil
+		}
+		return map[string]string{"namespace": req.Namespace}, nil
+
+	case "delete-namespace":
+		var req api.DeleteNamespaceRequest
+		if err := decodeBatchParams(op, &req); err != nil {
+			return nil, err
+		}
+		if req.Namespace == "" {
+			return nil, fmt.Errorf("%w: namespace is required", errInvalidBatchOperation)
+		}
+		if err := requireKubernetesPermission(ctx, p, user, "namespaces", "delete", req.Namespace); err != nil {
+			return nil, err
+		}
+		err := p.DeleteNamespaceAsUser(ctx, username, user.Groups, req.Namespace)
+		auditSpiceDBDecision(ctx, p, user, "namespaces", "delete", req.Namespace, err)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"namespace": req.Namespace}, nil
+
+	case "grant-view", "grant-edit", "revoke-view":
+		var req api.GrantViewPermissionRequest
+		if err := decodeBatchParams(op, &req); err != nil {
+			return nil, err
+		}
+		if req.Namespace == "" || req.User == "" {
+			return nil, fmt.Errorf("%w: both namespace and user are required", errInvalidBatchOperation)
+		}
+		// Like their standalone endpoints, only grant-view can be dry run
+		if req.DryRun && op.Op != "grant-view" {
+			return nil, fmt.Errorf("%w: %s does not support dryRun", errInvalidBatchOperation, op.Op)
+		}
+		if err := requireKubernetesPermission(ctx, p, user, "namespaces", "update", req.Namespace); err != nil {
+			return nil, err
+		}
+
+		target := sanitizeUserName(req.User)
+		switch op.Op {
+		case "grant-view":
+			if req.DryRun {
+				return map[string]interface{}{"namespace": req.Namespace, "user": target, "dryRun": true, "allowed": true}, nil
+			}
+			writtenAt, err := p.GrantViewPermission(ctx, req.Namespace, target, time.Time{})
+			if err != nil {
+				return nil, err
+			}
+			return map[string]string{"namespace": req.Namespace, "user": target, "written_at": writtenAt}, nil
+		case "grant-edit":
+			if err := p.GrantEditPermission(ctx, req.Namespace, target); err != nil {
+				return nil, err
+			}
+		default:
+			deleted, err := p.RevokeViewPermission(ctx, req.Namespace, target)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"namespace": req.Namespace, "user": target, "relationships_deleted": deleted}, nil
+		}
+		return map[string]string{"namespace": req.Namespace, "user": target}, nil
+
+	case "create-pod":
+		var req api.CreatePodRequest
+		if err := decodeBatchParams(op, &req); err != nil {
+			return nil, err
+		}
+		if req.Namespace == "" || req.Name == "" || req.Image == "" {
+			return nil, fmt.Errorf("%w: namespace, name and image are required", errInvalidBatchOperation)
+		}
+		if err := requireKubernetesPermission(ctx, p, user, "pods", "create", req.Namespace); err != nil {
+			return nil, err
+		}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: req.Name, Namespace: req.Namespace},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: req.Name, Image: req.Image}},
+			},
+		}
+		podName, err := p.CreatePodAsUser(ctx, username, user.Groups, req.Namespace, pod)
+		auditSpiceDBDecision(ctx, p, user, "pods", "create", req.Namespace, err)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"pod": podName, "namespace": req.Namespace}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: unknown op %q", errInvalidBatchOperation, op.Op)
+	}
+}
+
+// decodeBatchParams unmarshals an operation's params into v
+func decodeBatchParams(op api.BatchOperation, v interface{}) error {
+	if len(op.Params) == 0 {
+		return fmt.Errorf("%w: params are required", errInvalidBatchOperation)
+	}
+	if err := json.Unmarshal(op.Params, v); err != nil {
+		return fmt.Errorf("%w: invalid params: %v", errInvalidBatchOperation, err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/api"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/proxy"
+)
+
+// runBatch sends operations to /api/batch as user and returns the per-item results
+func runBatch(t *testing.T, user string, ops ...api.BatchOperation) []api.BatchResult {
+	t.Helper()
+	status, resp := call(t, http.MethodPost, "/api/batch", user, api.BatchRequest{Operations: ops})
+	if status != http.StatusOK {
+		t.Fatalf("batch = %d %s, want 200", status, resp.Error)
+	}
+	data, err := json.Marshal(dataMap(t, resp)["results"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []api.BatchResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(ops) {
+		t.Fatalf("batch returned %d results for %d operations", len(results), len(ops))
+	}
+	return results
+}
+
+func batchOp(t *testing.T, op string, params interface{}) api.BatchOperation {
+	t.Helper()
+	data, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api.BatchOperation{Op: op, Params: data}
+}
+
+func TestBatchDryRun(t *testing.T) {
+	createNamespace(t, "batchdry-alice", "batchdry-existing")
+
+	results := runBatch(t, "batchdry-alice",
+		batchOp(t, "create-namespace", api.CreateNamespaceRequest{Namespace: "batchdry-new", DryRun: true}),
+		batchOp(t, "grant-view", api.GrantViewPermissionRequest{Namespace: "batchdry-existing", User: "batchdry-bob", DryRun: true}),
+		batchOp(t, "grant-edit", api.GrantViewPermissionRequest{Namespace: "batchdry-existing", User: "batchdry-bob", DryRun: true}),
+	)
+
+	for _, i := range []int{0, 1} {
+		data, _ := results[i].Data.(map[string]interface{})
+		if !results[i].Success || data["dryRun"] != true {
+			t.Errorf("%s result = %+v, want a successful dry run", results[i].Op, results[i])
+		}
+	}
+	if testKube.Has("namespaces", "", "batchdry-new") {
+		t.Error("dry run created the namespace")
+	}
+	resp, err := testServer.proxy.CheckPermission(context.Background(), "namespace", "batchdry-existing", "view", "user", "batchdry-bob", proxy.NewConsistency(true, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
+		t.Error("dry run granted view")
+	}
+
+	// grant-edit cannot be dry run, so asking for one is rejected rather than performed
+	if results[2].Success || results[2].Status != http.StatusBadRequest {
+		t.Errorf("grant-edit dry run result = %+v, want 400", results[2])
+	}
+	resp, err = testServer.proxy.CheckPermission(context.Background(), "namespace", "batchdry-existing", "edit", "user", "batchdry-bob", proxy.NewConsistency(true, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
+		t.Error("rejected dry run granted edit")
+	}
+}
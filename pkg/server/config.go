@@ -0,0 +1,457 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/audit"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/auth"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/proxy"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/requestid"
+)
+
+// Config holds the server configuration
+type Config struct {
+	// ListenAddr is the host:port the HTTP server binds; defaults to ":8080"
+	ListenAddr string
+	// KubeConfigSource selects where the backend cluster config comes from; empty means KubeConfigSourceAuto
+	KubeConfigSource string
+	// KubeConfigPath is the kubeconfig file used outside a cluster; empty uses $KUBECONFIG or ~/.kube/config
+	KubeConfigPath string
+	// ReadinessTimeout bounds how long NewServer waits for the embedded SpiceDB to become ready
+	ReadinessTimeout time.Duration
+	// MaxRequestBodyBytes bounds /api request bodies; larger requests get 413. Defaults to 1 MiB.
+	MaxRequestBodyBytes int64
+	// WorkflowDBPath stores the workflow database at a stable path; empty uses a temporary file removed
+	// on Stop and InMemoryWorkflowDBPath keeps it in memory
+	WorkflowDBPath string
+	// TLS enables HTTPS and client certificate authentication when set
+	TLS *TLSConfig
+	// CORS allows browser clients on other origins to call the /api endpoints; nil rejects them all
+	CORS *CORSConfig
+	// Logger receives structured logs from the server, proxy and authenticator
+	Logger *slog.Logger
+	// AuditSink receives authorization decisions; nil logs them with Logger
+	AuditSink audit.Sink
+	// OIDC enables OIDC ID token authentication when set
+	OIDC *auth.OIDCConfig
+	// RateLimit limits API requests per authenticated user when set
+	RateLimit *RateLimitConfig
+	// Impersonation honors Impersonate-User / Impersonate-Group headers for privileged callers
+	Impersonation bool
+	// BasicAuth accepts HTTP Basic credentials, validating the password as a token unless the user is in BasicAuthFile
+	BasicAuth bool
+	// BasicAuthFile is an optional CSV of static Basic credentials: password,user,uid[,"group1,group2"]
+	BasicAuthFile string
+	// TokenCookie names a cookie holding a bearer token for browser clients; empty disables cookie
+	// authentication. When TLS is set, cookie tokens are only accepted over TLS.
+	TokenCookie string
+	// HeaderAuth renames the headers used for header authentication
+	HeaderAuth auth.HeaderAuthConfig
+	// DisableHeaderAuth rejects requests authenticated only by user headers
+	DisableHeaderAuth bool
+	// TokenAudiences are the audiences bearer tokens must be issued for when validated via TokenReview
+	TokenAudiences []string
+	// SARCacheTTL is how long SubjectAccessReview decisions are cached; zero disables the cache
+	SARCacheTTL time.Duration
+	// SARCacheMaxSize bounds the number of cached SubjectAccessReview decisions
+	SARCacheMaxSize int
+	// ClientCacheIdleTTL is how long an unused per-user Kubernetes client is kept; zero disables the cache
+	ClientCacheIdleTTL time.Duration
+	// ClientCacheMaxSize bounds the number of cached per-user Kubernetes clients
+	ClientCacheMaxSize int
+	// ClusterAdmins are granted cluster#admin in SpiceDB at startup, making them admins of every namespace
+	ClusterAdmins []string
+	// BootstrapFile is an optional SpiceDB bootstrap YAML replacing the embedded schema
+	BootstrapFile string
+	// NamespaceCreateRelationships are relationship templates written alongside the creator on
+	// every namespace create, such as namespace:{{name}}#viewer@group:platform#member
+	NamespaceCreateRelationships []string
+	// SeedRelationshipsFile lists relationships, one per line, created at startup when missing
+	SeedRelationshipsFile string
+	// RulesPath is an optional ProxyRule YAML file or directory merged over the default rules
+	RulesPath string
+	// Printer configures the periodic SpiceDB data printer
+	Printer proxy.PrinterConfig
+	// Reconciler prunes relationships of objects deleted directly in Kubernetes; disabled by default
+	Reconciler proxy.ReconcilerConfig
+	// CheckConcurrency bounds concurrent SpiceDB permission checks; zero keeps proxy.DefaultCheckConcurrency
+	CheckConcurrency int
+	// DemoAccess controls who can read the /api/demo endpoint list; empty means DemoAccessPublic
+	DemoAccess string
+	// Timeouts bounds each outbound SpiceDB and Kubernetes call by operation type; the zero value keeps proxy.DefaultTimeouts
+	Timeouts proxy.Timeouts
+	// HTTPTimeouts bounds reading requests from and idling clients; the zero value keeps DefaultHTTPTimeouts
+	HTTPTimeouts HTTPTimeouts
+}
+
+// Access levels of the /api/demo endpoint for Config.DemoAccess
+const (
+	// DemoAccessPublic serves the endpoint list to anyone, for developer convenience
+	DemoAccessPublic = "public"
+	// DemoAccessAuthenticated serves it only to authenticated users
+	DemoAccessAuthenticated = "authenticated"
+	// DemoAccessDisabled does not serve it at all
+	DemoAccessDisabled = "disabled"
+)
+
+// validateDemoAccess checks that access is empty or a known DemoAccess value
+func validateDemoAccess(access string) error {
+	switch access {
+	case "", DemoAccessPublic, DemoAccessAuthenticated, DemoAccessDisabled:
+		return nil
+	}
+	return fmt.Errorf("unknown access %q: must be %q, %q or %q", access, DemoAccessPublic, DemoAccessAuthenticated, DemoAccessDisabled)
+}
+
+const (
+	// InMemoryWorkflowDBPath as the WorkflowDBPath keeps the workflow database in memory, as SQLite names it
+	InMemoryWorkflowDBPath = ":memory:"
+	// defaultListenAddr is the address the HTTP server binds when none is configured
+	defaultListenAddr = ":8080"
+	// defaultReadinessTimeout bounds startup when no readiness timeout is configured
+	defaultReadinessTimeout = 60 * time.Second
+	// defaultMaxRequestBodyBytes bounds /api request bodies when no limit is configured
+	defaultMaxRequestBodyBytes = 1 << 20
+	// maxImportBodyBytes bounds relationship imports, which carry a whole export and so are
+	// exempt from the general body limit
+	maxImportBodyBytes = 256 << 20
+)
+
+// ConfigFromEnv loads the server configuration from environment variables
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		ListenAddr:         defaultListenAddr,
+		ReadinessTimeout:   defaultReadinessTimeout,
+		SARCacheTTL:        10 * time.Second,
+		SARCacheMaxSize:    1024,
+		ClientCacheIdleTTL: 5 * time.Minute,
+		ClientCacheMaxSize: 256,
+		Printer:            proxy.DefaultPrinterConfig(),
+		BootstrapFile:      os.Getenv("SPICEDB_BOOTSTRAP_FILE"),
+		RulesPath:          os.Getenv("PROXY_RULES_PATH"),
+		WorkflowDBPath:     os.Getenv("PROXY_WORKFLOW_DB_PATH"),
+		KubeConfigSource:   os.Getenv("KUBE_CONFIG_SOURCE"),
+		KubeConfigPath:     os.Getenv("PROXY_KUBECONFIG"),
+		DemoAccess:         os.Getenv("DEMO_ENDPOINT_ACCESS"),
+	}
+
+	if err := validateKubeConfigSource(cfg.KubeConfigSource); err != nil {
+		return Config{}, fmt.Errorf("invalid KUBE_CONFIG_SOURCE: %w", err)
+	}
+
+	if v := os.Getenv("PROXY_LISTEN_ADDR"); v != "" {
+		cfg.ListenAddr = v
+	}
+	if err := validateListenAddr(cfg.ListenAddr); err != nil {
+		return Config{}, fmt.Errorf("invalid PROXY_LISTEN_ADDR: %w", err)
+	}
+
+	if v := os.Getenv("PROXY_READY_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return Config{}, fmt.Errorf("invalid PROXY_READY_TIMEOUT %q: must be a positive duration", v)
+		}
+		cfg.ReadinessTimeout = d
+	}
+
+	cfg.MaxRequestBodyBytes = defaultMaxRequestBodyBytes
+	if v := os.Getenv("MAX_REQUEST_BODY_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			return Config{}, fmt.Errorf("invalid MAX_REQUEST_BODY_BYTES %q: must be a positive integer", v)
+		}
+		cfg.MaxRequestBodyBytes = n
+	}
+
+	if certFile, keyFile := os.Getenv("PROXY_TLS_CERT_FILE"), os.Getenv("PROXY_TLS_KEY_FILE"); certFile != "" || keyFile != "" {
+		cfg.TLS = &TLSConfig{
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			ClientCAFile: os.Getenv("PROXY_TLS_CLIENT_CA_FILE"),
+			ClientAuth:   os.Getenv("PROXY_TLS_CLIENT_AUTH"),
+		}
+	}
+
+	level := slog.LevelInfo
+	if v := os.Getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		cors := &CORSConfig{
+			AllowedOrigins: splitList(v),
+			AllowedMethods: splitList(os.Getenv("CORS_ALLOWED_METHODS")),
+			AllowedHeaders: splitList(os.Getenv("CORS_ALLOWED_HEADERS")),
+		}
+		if v := os.Getenv("CORS_ALLOW_CREDENTIALS"); v != "" {
+			allow, err := strconv.ParseBool(v)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid CORS_ALLOW_CREDENTIALS %q: %w", v, err)
+			}
+			cors.AllowCredentials = allow
+		}
+		if v := os.Getenv("CORS_MAX_AGE"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid CORS_MAX_AGE %q: %w", v, err)
+			}
+			cors.MaxAge = d
+		}
+		cfg.CORS = cors
+	}
+
+	if v := os.Getenv("RATE_LIMIT_RPS"); v != "" {
+		rps, err := strconv.ParseFloat(v, 64)
+		if err != nil || rps <= 0 {
+			return Config{}, fmt.Errorf("invalid RATE_LIMIT_RPS %q: must be a positive number", v)
+		}
+		limit := &RateLimitConfig{
+			RequestsPerSecond: rps,
+			ExemptGroups:      splitList(os.Getenv("RATE_LIMIT_EXEMPT_GROUPS")),
+		}
+		if v := os.Getenv("RATE_LIMIT_BURST"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return Config{}, fmt.Errorf("invalid RATE_LIMIT_BURST %q: must be a positive integer", v)
+			}
+			limit.Burst = n
+		}
+		cfg.RateLimit = limit
+	}
+
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := level.UnmarshalText([]byte(v)); err != nil {
+			return Config{}, fmt.Errorf("invalid LOG_LEVEL %q: must be debug, info, warn or error", v)
+		}
+	}
+	cfg.Logger = slog.New(requestid.NewLogHandler(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	if v := os.Getenv("AUDIT_LOG_ENABLED"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid AUDIT_LOG_ENABLED %q: %w", v, err)
+		}
+		if !enabled {
+			cfg.AuditSink = audit.Discard
+		}
+	}
+
+	if issuer := os.Getenv("OIDC_ISSUER_URL"); issuer != "" {
+		oidcConfig := &auth.OIDCConfig{
+			IssuerURL:     issuer,
+			ClientID:      os.Getenv("OIDC_CLIENT_ID"),
+			UsernameClaim: os.Getenv("OIDC_USERNAME_CLAIM"),
+			GroupsClaim:   os.Getenv("OIDC_GROUPS_CLAIM"),
+		}
+		if skew := os.Getenv("OIDC_CLOCK_SKEW"); skew != "" {
+			d, err := time.ParseDuration(skew)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid OIDC_CLOCK_SKEW %q: %w", skew, err)
+			}
+			oidcConfig.ClockSkew = d
+		}
+		cfg.OIDC = oidcConfig
+	}
+
+	if v := os.Getenv("IMPERSONATION_ENABLED"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid IMPERSONATION_ENABLED %q: %w", v, err)
+		}
+		cfg.Impersonation = enabled
+	}
+
+	if v := os.Getenv("BASIC_AUTH_ENABLED"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid BASIC_AUTH_ENABLED %q: %w", v, err)
+		}
+		cfg.BasicAuth = enabled
+	}
+	cfg.BasicAuthFile = os.Getenv("BASIC_AUTH_FILE")
+	cfg.TokenCookie = os.Getenv("TOKEN_COOKIE_NAME")
+
+	if v := os.Getenv("HEADER_AUTH_ENABLED"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid HEADER_AUTH_ENABLED %q: %w", v, err)
+		}
+		cfg.DisableHeaderAuth = !enabled
+	}
+	cfg.HeaderAuth = auth.HeaderAuthConfig{
+		UserHeader:     os.Getenv("HEADER_AUTH_USER_HEADER"),
+		GroupHeaders:   splitList(os.Getenv("HEADER_AUTH_GROUP_HEADERS")),
+		GroupDelimiter: os.Getenv("HEADER_AUTH_GROUP_DELIMITER"),
+	}
+
+	if v := os.Getenv("TOKEN_REVIEW_AUDIENCES"); v != "" {
+		cfg.TokenAudiences = splitList(v)
+	}
+
+	if v := os.Getenv("CLUSTER_ADMIN_USERS"); v != "" {
+		cfg.ClusterAdmins = splitList(v)
+	}
+	cfg.NamespaceCreateRelationships = splitList(os.Getenv("NAMESPACE_CREATE_RELATIONSHIPS"))
+	cfg.SeedRelationshipsFile = os.Getenv("SPICEDB_SEED_RELATIONSHIPS_FILE")
+
+	if v := os.Getenv("SAR_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid SAR_CACHE_TTL %q: %w", v, err)
+		}
+		cfg.SARCacheTTL = d
+	}
+
+	if v := os.Getenv("SAR_CACHE_MAX_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return Config{}, fmt.Errorf("invalid SAR_CACHE_MAX_SIZE %q: must be a positive integer", v)
+		}
+		cfg.SARCacheMaxSize = n
+	}
+
+	if v := os.Getenv("CLIENT_CACHE_IDLE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid CLIENT_CACHE_IDLE_TTL %q: %w", v, err)
+		}
+		cfg.ClientCacheIdleTTL = d
+	}
+
+	if v := os.Getenv("CLIENT_CACHE_MAX_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return Config{}, fmt.Errorf("invalid CLIENT_CACHE_MAX_SIZE %q: must be a positive integer", v)
+		}
+		cfg.ClientCacheMaxSize = n
+	}
+
+	if v := os.Getenv("SPICEDB_PRINTER_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid SPICEDB_PRINTER_INTERVAL %q: %w", v, err)
+		}
+		cfg.Printer.Interval = d
+	}
+
+	if v := os.Getenv("SPICEDB_PRINTER_ENABLED"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid SPICEDB_PRINTER_ENABLED %q: %w", v, err)
+		}
+		if !enabled {
+			cfg.Printer.Interval = 0
+		}
+	}
+
+	if v := os.Getenv("SPICEDB_PRINTER_REDACT_SUBJECTS"); v != "" {
+		redact, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid SPICEDB_PRINTER_REDACT_SUBJECTS %q: %w", v, err)
+		}
+		cfg.Printer.RedactSubjects = redact
+	}
+
+	if v := os.Getenv("SPICEDB_PRINTER_FORMAT"); v != "" {
+		if v != proxy.PrinterFormatText && v != proxy.PrinterFormatJSON {
+			return Config{}, fmt.Errorf("invalid SPICEDB_PRINTER_FORMAT %q: must be %q or %q", v, proxy.PrinterFormatText, proxy.PrinterFormatJSON)
+		}
+		cfg.Printer.Format = v
+	}
+
+	cfg.Printer.File = os.Getenv("SPICEDB_PRINTER_FILE")
+
+	if v := os.Getenv("RECONCILE_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid RECONCILE_INTERVAL %q: %w", v, err)
+		}
+		cfg.Reconciler.Interval = d
+	}
+
+	if v := os.Getenv("RECONCILE_DRY_RUN"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid RECONCILE_DRY_RUN %q: %w", v, err)
+		}
+		cfg.Reconciler.DryRun = dryRun
+	}
+
+	if v := os.Getenv("SPICEDB_PRINTER_FILE_MAX_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return Config{}, fmt.Errorf("invalid SPICEDB_PRINTER_FILE_MAX_BYTES %q: must be a non-negative integer", v)
+		}
+		cfg.Printer.FileMaxBytes = n
+	}
+
+	if v := os.Getenv("SPICEDB_PRINTER_FILE_MAX_BACKUPS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return Config{}, fmt.Errorf("invalid SPICEDB_PRINTER_FILE_MAX_BACKUPS %q: must be a non-negative integer", v)
+		}
+		cfg.Printer.FileMaxBackups = n
+	}
+
+	if v := os.Getenv("SPICEDB_CHECK_CONCURRENCY"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return Config{}, fmt.Errorf("invalid SPICEDB_CHECK_CONCURRENCY %q: must be a positive integer", v)
+		}
+		cfg.CheckConcurrency = n
+	}
+
+	cfg.Timeouts = proxy.DefaultTimeouts()
+	cfg.HTTPTimeouts = DefaultHTTPTimeouts()
+	for _, t := range []struct {
+		env     string
+		timeout *time.Duration
+	}{
+		{"SPICEDB_READ_TIMEOUT", &cfg.Timeouts.SpiceDBRead},
+		{"SPICEDB_WRITE_TIMEOUT", &cfg.Timeouts.SpiceDBWrite},
+		{"SPICEDB_CHECK_TIMEOUT", &cfg.Timeouts.SpiceDBCheck},
+		{"KUBERNETES_TIMEOUT", &cfg.Timeouts.Kubernetes},
+		{"SPICEDB_PRINTER_TIMEOUT", &cfg.Timeouts.Printer},
+		{"HTTP_READ_HEADER_TIMEOUT", &cfg.HTTPTimeouts.ReadHeader},
+		{"HTTP_READ_TIMEOUT", &cfg.HTTPTimeouts.Read},
+		{"HTTP_WRITE_TIMEOUT", &cfg.HTTPTimeouts.Write},
+		{"HTTP_IDLE_TIMEOUT", &cfg.HTTPTimeouts.Idle},
+	} {
+		v := os.Getenv(t.env)
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("invalid %s %q: must be a non-negative Go duration, 0 to disable", t.env, v)
+		}
+		*t.timeout = d
+	}
+
+	return cfg, nil
+}
+
+// splitList splits a comma-separated environment value, dropping empty entries
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// validateListenAddr checks that addr is a host:port pair with a valid port
+func validateListenAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%q must be in host:port form: %w", addr, err)
+	}
+	if _, err := net.LookupPort("tcp", port); err != nil {
+		return fmt.Errorf("%q has an invalid port: %w", addr, err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/requestid"
+)
+
+// CORSAllowAnyOrigin in CORSConfig.AllowedOrigins allows requests from every origin
+const CORSAllowAnyOrigin = "*"
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodOptions}
+	defaultCORSHeaders = []string{"Authorization", "Content-Type", requestid.Header, "Impersonate-User", "Impersonate-Group"}
+)
+
+// CORSConfig allows browser clients on other origins to call the /api endpoints.
+// Without a CORSConfig every cross-origin request is rejected.
+type CORSConfig struct {
+	// AllowedOrigins lists the exact origins allowed, or CORSAllowAnyOrigin
+	AllowedOrigins []string
+	// AllowedMethods defaults to GET, POST and OPTIONS
+	AllowedMethods []string
+	// AllowedHeaders defaults to the authentication, impersonation, content type and request ID headers
+	AllowedHeaders []string
+	// AllowCredentials lets browsers send cookies and client certificates; it cannot be combined with any origin
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response; zero leaves it to the browser
+	MaxAge time.Duration
+}
+
+// validateCORSConfig rejects configurations browsers would refuse to honor
+func validateCORSConfig(cfg *CORSConfig) error {
+	if len(cfg.AllowedOrigins) == 0 {
+		return fmt.Errorf("at least one allowed origin is required")
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == CORSAllowAnyOrigin && cfg.AllowCredentials {
+			return fmt.Errorf("credentials cannot be allowed for any origin")
+		}
+	}
+	return nil
+}
+
+// withCORS adds CORS headers to /api responses for allowed origins and answers preflight
+// requests itself, so preflights never reach authentication. Cross-origin requests from
+// other origins, or from any origin when cfg is nil, get no CORS headers and failed preflights.
+func withCORS(cfg *CORSConfig, next http.Handler) http.Handler {
+	methods, headers := defaultCORSMethods, defaultCORSHeaders
+	if cfg != nil && len(cfg.AllowedMethods) > 0 {
+		methods = cfg.AllowedMethods
+	}
+	if cfg != nil && len(cfg.AllowedHeaders) > 0 {
+		headers = cfg.AllowedHeaders
+	}
+	allowMethods, allowHeaders := strings.Join(methods, ", "), strings.Join(headers, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Responses differ by origin, so shared caches must key on it
+		w.Header().Add("Vary", "Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !corsOriginAllowed(cfg, origin) {
+			if preflight {
+				http.Error(w, "CORS origin not allowed", http.StatusForbidden)
+				return
+			}
+			// The browser blocks the response without CORS headers; same-origin tooling is unaffected
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if cfg.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			w.Header().Set("Access-Control-Expose-Headers", requestid.Header)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+		w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+		if cfg.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// corsOriginAllowed reports whether cfg allows requests from origin
+func corsOriginAllowed(cfg *CORSConfig, origin string) bool {
+	if cfg == nil {
+		return false
+	}
+	for _, allowed := range cfg.AllowedOrigins {
+		if allowed == CORSAllowAnyOrigin || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// requestTracker follows in-flight requests so Stop can wait for them before stopping the
+// embedded proxy. http.Server.Shutdown already waits for ordinary requests, but not for
+// WebSocket watches, whose connections are hijacked; those are asked to end through
+// draining instead.
+type requestTracker struct {
+	wg    sync.WaitGroup
+	ctx   context.Context
+	drain context.CancelFunc
+}
+
+func newRequestTracker() *requestTracker {
+	ctx, drain := context.WithCancel(context.Background())
+	return &requestTracker{ctx: ctx, drain: drain}
+}
+
+// draining is closed once the server starts shutting down. Long-lived streams end when it is.
+func (t *requestTracker) draining() <-chan struct{} {
+	return t.ctx.Done()
+}
+
+// wait blocks until every tracked request has returned or ctx ends. It must only be called
+// once the server has stopped accepting requests.
+func (t *requestTracker) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// withRequestTracking counts each request as in flight until next returns
+func withRequestTracking(t *requestTracker, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.wg.Add(1)
+		defer t.wg.Done()
+		next.ServeHTTP(w, r)
+	})
+}
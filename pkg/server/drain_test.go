@@ -0,0 +1,125 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startDrainServer serves the handler newHandler builds behind request tracking, wired for
+// shutdown like NewServer
+func startDrainServer(t *testing.T, newHandler func(requests *requestTracker) http.Handler) (*http.Server, *requestTracker, string) {
+	t.Helper()
+	requests := newRequestTracker()
+	server := &http.Server{Handler: withRequestTracking(requests, newHandler(requests))}
+	server.RegisterOnShutdown(requests.drain)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(ln)
+	t.Cleanup(func() { server.Close() })
+	return server, requests, "http://" + ln.Addr().String()
+}
+
+// shutdown stops server the way Stop does, reporting the error once in-flight requests are done
+func shutdown(server *http.Server, requests *requestTracker) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err := server.Shutdown(ctx)
+		if waitErr := requests.wait(ctx); err == nil {
+			err = waitErr
+		}
+		done <- err
+	}()
+	return done
+}
+
+func TestShutdownWaitsForLongRequest(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	server, requests, url := startDrainServer(t, func(*requestTracker) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			io.WriteString(w, "done")
+		})
+	})
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{body: string(body), err: err}
+	}()
+	<-started
+
+	stopped := shutdown(server, requests)
+	select {
+	case err := <-stopped:
+		t.Fatalf("shutdown returned %v with a request in flight", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	close(release)
+	if got := <-responses; got.err != nil || got.body != "done" {
+		t.Errorf("in-flight request = %q, %v; want it to complete", got.body, got.err)
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("shutdown = %v, want nil", err)
+	}
+}
+
+func TestShutdownDrainsHijackedStreams(t *testing.T) {
+	ended := make(chan struct{})
+	server, requests, url := startDrainServer(t, func(requests *requestTracker) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer close(ended)
+			conn, buf, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n\r\n")
+			buf.Flush()
+			// Like a watch, the stream lasts until the server drains
+			<-requests.draining()
+		})
+	})
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: test\r\n\r\n")
+	if _, err := bufio.NewReader(conn).ReadString('\n'); err != nil {
+		t.Fatalf("read upgrade response: %v", err)
+	}
+
+	if err := <-shutdown(server, requests); err != nil {
+		t.Errorf("shutdown = %v, want nil", err)
+	}
+	select {
+	case <-ended:
+	default:
+		t.Error("shutdown returned before the hijacked stream ended")
+	}
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/api"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/auth"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/proxy"
+)
+
+// snapshotRevisionHeader carries the ZedToken an export was read at
+const snapshotRevisionHeader = "X-Snapshot-Revision"
+
+// exportHandler streams every relationship to a cluster admin as newline-delimited JSON, one
+// proxy.Relationship per line, flushing after each page read from SpiceDB. The snapshot is read
+// at the revision in the X-Snapshot-Revision header. Once streaming has started, a failure can
+// no longer change the status, so the connection is aborted and the client sees a truncated
+// transfer rather than a silently incomplete backup.
+func exportHandler(kubeProxy *proxy.SpiceDBKubeProxy, logger *slog.Logger) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		export, err := kubeProxy.ExportRelationships(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set(snapshotRevisionHeader, export.Revision)
+		w.WriteHeader(http.StatusOK)
+
+		flusher := http.NewResponseController(w)
+		encoder := json.NewEncoder(w)
+		count := 0
+		err = export.Range(r.Context(), func(rel proxy.Relationship) error {
+			if err := encoder.Encode(rel); err != nil {
+				return err
+			}
+			count++
+			if count%proxy.MaxRelationshipPageSize == 0 {
+				// Not every writer can flush; the data then goes out as the buffer fills
+				_ = flusher.Flush()
+			}
+			return nil
+		})
+		if err != nil {
+			logger.ErrorContext(r.Context(), "relationship export failed", "user", user.Username, "revision", export.Revision, "exported", count, "error", err)
+			panic(http.ErrAbortHandler)
+		}
+		logger.InfoContext(r.Context(), "relationships exported", "user", user.Username, "revision", export.Revision, "count", count)
+	}
+}
+
+// importHandler creates the relationships in a body written by exportHandler, skipping those
+// that already exist, for cluster admins
+func importHandler(kubeProxy *proxy.SpiceDBKubeProxy, logger *slog.Logger) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		result, err := kubeProxy.ImportRelationships(r.Context(), r.Body)
+		if result != nil {
+			logger.InfoContext(r.Context(), "relationships imported", "user", user.Username,
+				"imported", result.Imported, "skipped", result.Skipped)
+		}
+		if err != nil {
+			status := statusForError(err)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+				err = fmt.Errorf("Request body exceeds %d bytes", tooLarge.Limit)
+			}
+			resp := api.Response{Success: false, Error: err.Error()}
+			if result != nil {
+				resp.Data = result
+			}
+			writeJSON(w, status, resp)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: result})
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/api"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/auth"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/proxy"
+)
+
+// decodeGroupMemberRequest reads and validates a GroupMemberRequest and checks the caller
+// may manage the group, replying with the error otherwise
+func decodeGroupMemberRequest(w http.ResponseWriter, r *http.Request, kubeProxy *proxy.SpiceDBKubeProxy, user *auth.UserInfo) (api.GroupMemberRequest, bool) {
+	var req api.GroupMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return req, false
+	}
+
+	if req.Group == "" || req.User == "" {
+		writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Both group and user are required"})
+		return req, false
+	}
+	if err := validateGroupName(req.Group); err != nil {
+		writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: err.Error()})
+		return req, false
+	}
+
+	if err := requireGroupPermission(r.Context(), kubeProxy, user, req.Group, proxy.GroupPermissionManage); err != nil {
+		writeError(w, err)
+		return req, false
+	}
+	return req, true
+}
+
+// groupRelation names the relation a GroupMemberRequest changes
+func groupRelation(req api.GroupMemberRequest) string {
+	if req.Admin {
+		return proxy.GroupRelationAdmin
+	}
+	return proxy.GroupRelationMember
+}
+
+// addGroupMemberHandler adds a user to a group, or makes them a group admin, for group
+// admins and cluster admins
+func addGroupMemberHandler(kubeProxy *proxy.SpiceDBKubeProxy, logger *slog.Logger) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		req, ok := decodeGroupMemberRequest(w, r, kubeProxy, user)
+		if !ok {
+			return
+		}
+
+		subjectID := sanitizeUserName(req.User)
+		add := kubeProxy.AddUserToGroup
+		if req.Admin {
+			add = kubeProxy.AddGroupAdmin
+		}
+		writtenAt, err := add(r.Context(), req.Group, subjectID)
+		if err != nil {
+			writeJSON(w, statusForError(err), api.Response{Success: false, Error: fmt.Sprintf("Failed to add group %s: %v", groupRelation(req), err)})
+			return
+		}
+		logger.InfoContext(r.Context(), "group relation added", "user", user.Username, "group", req.Group,
+			"relation", groupRelation(req), "subject", req.User)
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]string{
+			"group":      req.Group,
+			"user":       req.User,
+			"relation":   groupRelation(req),
+			"added_by":   sanitizeUserName(user.Username),
+			"written_at": writtenAt,
+		}})
+	}
+}
+
+// removeGroupMemberHandler removes a user from a group, or their group admin relation, for
+// group admins and cluster admins. Removing a relation the user does not hold is not an error.
+func removeGroupMemberHandler(kubeProxy *proxy.SpiceDBKubeProxy, logger *slog.Logger) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		req, ok := decodeGroupMemberRequest(w, r, kubeProxy, user)
+		if !ok {
+			return
+		}
+
+		subjectID := sanitizeUserName(req.User)
+		remove := kubeProxy.RemoveUserFromGroup
+		if req.Admin {
+			remove = kubeProxy.RemoveGroupAdmin
+		}
+		deleted, err := remove(r.Context(), req.Group, subjectID)
+		if err != nil {
+			writeJSON(w, statusForError(err), api.Response{Success: false, Error: fmt.Sprintf("Failed to remove group %s: %v", groupRelation(req), err)})
+			return
+		}
+		logger.InfoContext(r.Context(), "group relation removed", "user", user.Username, "group", req.Group,
+			"relation", groupRelation(req), "subject", req.User, "count", deleted)
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{
+			"group":                 req.Group,
+			"user":                  req.User,
+			"relation":              groupRelation(req),
+			"relationships_deleted": deleted,
+		}})
+	}
+}
+
+// listGroupMembersHandler lists a group's members for its members, its admins and cluster admins
+func listGroupMembersHandler(kubeProxy *proxy.SpiceDBKubeProxy) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.ListGroupMembersRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if err := validateGroupName(req.Group); err != nil {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: err.Error()})
+			return
+		}
+
+		if err := requireGroupPermission(r.Context(), kubeProxy, user, req.Group, proxy.GroupPermissionView); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		consistency := proxy.NewConsistency(req.FullyConsistent, req.AtLeastAsFresh)
+		memberIDs, err := kubeProxy.ListGroupMembers(r.Context(), req.Group, consistency)
+		if err != nil {
+			writeJSON(w, statusForError(err), api.Response{Success: false, Error: fmt.Sprintf("Failed to list group members: %v", err)})
+			return
+		}
+
+		// Report Kubernetes user names rather than their SpiceDB IDs
+		members := make([]string, 0, len(memberIDs))
+		for _, id := range memberIDs {
+			members = append(members, userNameFromSubjectID(id))
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{"group": req.Group, "members": members}})
+	}
+}
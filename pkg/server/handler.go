@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/api"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/auth"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/proxy"
+)
+
+// authedHandler handles a request made by an authenticated user
+type authedHandler func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo)
+
+// withMethod rejects requests whose method is not method before calling next
+func withMethod(method string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// withAuth authenticates the user from the request headers and passes them to next,
+// replying 401 when authentication fails
+func withAuth(p *proxy.SpiceDBKubeProxy, next authedHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := authenticate(p, r)
+		if err != nil {
+			writeJSON(w, http.StatusUnauthorized, api.Response{Success: false, Error: fmt.Sprintf("Authentication failed: %v", err)})
+			return
+		}
+		next(w, r, user)
+	}
+}
+
+// withClusterAdmin calls next only for users Kubernetes RBAC grants every verb on every resource
+func withClusterAdmin(p *proxy.SpiceDBKubeProxy, next authedHandler) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		if err := requireClusterAdmin(r.Context(), p, user); err != nil {
+			writeError(w, err)
+			return
+		}
+		next(w, r, user)
+	}
+}
+
+// withPermission calls next only when Kubernetes RBAC allows the user verb on resource
+// cluster-wide. Like checkPermission it replies 502 when the check fails and 403 otherwise.
+func withPermission(p *proxy.SpiceDBKubeProxy, resource, verb string, next authedHandler) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		denied := api.Response{Error: fmt.Sprintf("User does not have permission to %s %s", verb, resource)}
+		if !checkPermission(w, r, p, user, resource, verb, "", denied) {
+			return
+		}
+		next(w, r, user)
+	}
+}
+
+// checkPermission reports whether Kubernetes RBAC allows the user the action. Otherwise it
+// replies 502 when the check fails, or 403 with denied when the action is not allowed.
+func checkPermission(w http.ResponseWriter, r *http.Request, p *proxy.SpiceDBKubeProxy, user *auth.UserInfo, resource, verb, namespace string, denied api.Response) bool {
+	allowed, err := p.CheckKubernetesPermission(r.Context(), user, resource, verb, namespace)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, api.Response{Success: false, Error: fmt.Sprintf("Permission check failed: %v", err)})
+		return false
+	}
+	if !allowed {
+		denied.Success = false
+		writeJSON(w, http.StatusForbidden, denied)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	authzv1 "k8s.io/api/authorization/v1"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/api"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/auth"
+)
+
+func TestChain(t *testing.T) {
+	var order []string
+	record := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), record("outer"), record("middle"), record("inner"))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := strings.Join(order, " "); got != "outer middle inner handler" {
+		t.Errorf("ran %q, want %q", got, "outer middle inner handler")
+	}
+}
+
+func TestWithPermissionMatchesCheckPermission(t *testing.T) {
+	p := testServer.proxy
+	ok := func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: user.Username})
+	}
+	inline := withAuth(p, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		if !checkPermission(w, r, p, user, "namespaces", "list", "", api.Response{Error: "User does not have permission to list namespaces"}) {
+			return
+		}
+		ok(w, r, user)
+	})
+	wrapped := withAuth(p, withPermission(p, "namespaces", "list", ok))
+
+	serve := func(h http.HandlerFunc, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("X-Remote-User", user)
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	// Only perm-alice may list namespaces
+	withSubjectAccessReview(t, func(review *authzv1.SubjectAccessReview) bool {
+		attrs := review.Spec.ResourceAttributes
+		if attrs != nil && attrs.Resource == "namespaces" && attrs.Verb == "list" {
+			return review.Spec.User == "perm-alice"
+		}
+		return allowAllButClusterAdmin(review)
+	})
+
+	for _, tt := range []struct {
+		user string
+		want int
+	}{
+		{"perm-alice", http.StatusOK},
+		{"perm-mallory", http.StatusForbidden},
+	} {
+		want, got := serve(inline, tt.user), serve(wrapped, tt.user)
+		if want.Code != tt.want {
+			t.Fatalf("checkPermission for %s = %d, want %d", tt.user, want.Code, tt.want)
+		}
+		if got.Code != want.Code || got.Body.String() != want.Body.String() {
+			t.Errorf("withPermission for %s = %d %s, checkPermission = %d %s", tt.user, got.Code, got.Body.String(), want.Code, want.Body.String())
+		}
+	}
+
+	// The endpoint answers the same way through the server
+	status, resp := call(t, http.MethodPost, "/api/namespaces/list", "perm-mallory", nil)
+	if want := "User does not have permission to list namespaces"; status != http.StatusForbidden || resp.Error != want {
+		t.Errorf("list namespaces as mallory = %d %q, want 403 %q", status, resp.Error, want)
+	}
+}
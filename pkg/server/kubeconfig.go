@@ -0,0 +1,69 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Sources of the backend cluster configuration for Config.KubeConfigSource
+const (
+	// KubeConfigSourceAuto uses the in-cluster config when running in a pod and a kubeconfig file otherwise
+	KubeConfigSourceAuto = "auto"
+	// KubeConfigSourceInCluster only uses the pod's service account config
+	KubeConfigSourceInCluster = "in-cluster"
+	// KubeConfigSourceFile only uses a kubeconfig file
+	KubeConfigSourceFile = "kubeconfig"
+)
+
+// validateKubeConfigSource checks that source is empty or a known KubeConfigSource value
+func validateKubeConfigSource(source string) error {
+	switch source {
+	case "", KubeConfigSourceAuto, KubeConfigSourceInCluster, KubeConfigSourceFile:
+		return nil
+	}
+	return fmt.Errorf("unknown source %q: must be %q, %q or %q", source, KubeConfigSourceAuto, KubeConfigSourceInCluster, KubeConfigSourceFile)
+}
+
+// loadKubeConfig returns the backend cluster config from source. The kubeconfig file is
+// path when set and is otherwise found the way kubectl finds it, from $KUBECONFIG or
+// ~/.kube/config, using its current context.
+func loadKubeConfig(source, path string, logger *slog.Logger) (*rest.Config, error) {
+	switch source {
+	case KubeConfigSourceInCluster:
+		kubeConfig, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get in-cluster config: %w", err)
+		}
+		return kubeConfig, nil
+	case KubeConfigSourceFile:
+		return kubeConfigFromFile(path)
+	}
+
+	kubeConfig, inClusterErr := rest.InClusterConfig()
+	if inClusterErr == nil {
+		return kubeConfig, nil
+	}
+	kubeConfig, fileErr := kubeConfigFromFile(path)
+	if fileErr != nil {
+		return nil, fmt.Errorf("no Kubernetes config found: in-cluster config: %v; kubeconfig: %v", inClusterErr, fileErr)
+	}
+	logger.Info("not running in a cluster, using kubeconfig", "host", kubeConfig.Host)
+	return kubeConfig, nil
+}
+
+// kubeConfigFromFile loads the current context of the kubeconfig file at path, or of the default kubeconfig when path is empty
+func kubeConfigFromFile(path string) (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = path
+	kubeConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		if path != "" {
+			return nil, fmt.Errorf("failed to load kubeconfig %s: %w", path, err)
+		}
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	return kubeConfig, nil
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/api"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/auth"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/metrics"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/proxy"
+)
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController, which the
+// WebSocket upgrade uses to hijack the connection
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// endpointFor returns the mux pattern serving r, so unknown paths share one label
+func endpointFor(mux *http.ServeMux, r *http.Request) string {
+	_, endpoint := mux.Handler(r)
+	if endpoint == "" {
+		return "unmatched"
+	}
+	return endpoint
+}
+
+// chain wraps h in middlewares, the first of which sees a request first
+func chain(h http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// withMetrics records request count, outcome and latency per registered endpoint.
+// The mux pattern is used as the endpoint label so unknown paths don't create new series.
+func withMetrics(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := endpointFor(mux, r)
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		mux.ServeHTTP(rec, r)
+		metrics.ObserveRequest(endpoint, rec.status, time.Since(start))
+	})
+}
+
+// withMaxBodySize limits /api request bodies to limit bytes, or to the limit overrides gives
+// their path. Requests declaring a larger Content-Length are rejected up front; others fail
+// with 413 when decoding reads past the limit.
+func withMaxBodySize(limit int64, overrides map[string]int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limit := limit
+		if override, ok := overrides[r.URL.Path]; ok {
+			limit = override
+		}
+		if r.ContentLength > limit {
+			writeJSON(w, http.StatusRequestEntityTooLarge, api.Response{Success: false, Error: fmt.Sprintf("Request body exceeds %d bytes", limit)})
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withJSONContentType rejects /api POST requests whose body is not declared as
+// application/json, or as one of the media types overrides gives their path, with 415 before
+// anything reads the body. Requests without a body pass, since endpoints such as list accept
+// an empty one.
+func withJSONContentType(overrides map[string][]string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasPrefix(r.URL.Path, "/api/") || r.ContentLength == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		accepted := []string{"application/json"}
+		if override, ok := overrides[r.URL.Path]; ok {
+			accepted = override
+		}
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || !slices.Contains(accepted, mediaType) {
+			writeJSON(w, http.StatusUnsupportedMediaType, api.Response{Success: false, Error: fmt.Sprintf("Content-Type must be %s", strings.Join(accepted, " or "))})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestUserKey is the context key for the per-request slot that handlers fill with the caller
+type requestUserKey struct{}
+
+// withRequestLogging logs one structured entry per request with the endpoint, caller,
+// outcome and latency. Handlers report the caller through authenticate.
+func withRequestLogging(logger *slog.Logger, mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var user *auth.UserInfo
+		r = r.WithContext(context.WithValue(r.Context(), requestUserKey{}, &user))
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		attrs := []any{
+			"method", r.Method,
+			"endpoint", endpointFor(mux, r),
+			"status", rec.status,
+			"outcome", metrics.OutcomeForStatus(rec.status),
+			"latency", time.Since(start),
+		}
+		if user != nil {
+			attrs = append(attrs, "user", user.Username)
+			if user.Impersonator != nil {
+				attrs = append(attrs, "impersonator", user.Impersonator.Username)
+			}
+		}
+		logger.InfoContext(r.Context(), "request", attrs...)
+	})
+}
+
+// authResultKey is the context key for an authentication result computed by middleware
+type authResultKey struct{}
+
+type authResult struct {
+	user *auth.UserInfo
+	err  error
+}
+
+// withAuthentication authenticates r once and attaches the result, so middleware that
+// needs the caller and the handler that serves r share a single authentication
+func withAuthentication(p *proxy.SpiceDBKubeProxy, r *http.Request) *http.Request {
+	if _, ok := r.Context().Value(authResultKey{}).(*authResult); ok {
+		return r
+	}
+	user, err := p.AuthenticateFromRequest(r)
+	return r.WithContext(context.WithValue(r.Context(), authResultKey{}, &authResult{user: user, err: err}))
+}
+
+// authenticate authenticates the request caller and records it for the request log
+func authenticate(p *proxy.SpiceDBKubeProxy, r *http.Request) (*auth.UserInfo, error) {
+	var user *auth.UserInfo
+	var err error
+	if result, ok := r.Context().Value(authResultKey{}).(*authResult); ok {
+		user, err = result.user, result.err
+	} else {
+		user, err = p.AuthenticateFromRequest(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if slot, ok := r.Context().Value(requestUserKey{}).(**auth.UserInfo); ok {
+		*slot = user
+	}
+	return user, nil
+}
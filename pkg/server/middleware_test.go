@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/api"
+)
+
+func TestWithMaxBodySize(t *testing.T) {
+	decode := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, api.Response{Success: true})
+	})
+	handler := withMaxBodySize(64, map[string]int64{"/api/import": 1024}, decode)
+
+	large := `{"namespace":"` + strings.Repeat("a", 200) + `"}`
+	tests := []struct {
+		name string
+		path string
+		body string
+		// chunked hides the length, so only reading the body finds it too large
+		chunked bool
+		want    int
+	}{
+		{name: "within limit", path: "/api/test", body: `{"namespace":"a"}`, want: http.StatusOK},
+		{name: "declared too large", path: "/api/test", body: large, want: http.StatusRequestEntityTooLarge},
+		{name: "streamed too large", path: "/api/test", body: large, chunked: true, want: http.StatusRequestEntityTooLarge},
+		{name: "override allows more", path: "/api/import", body: large, want: http.StatusOK},
+		{name: "outside /api", path: "/other", body: large, want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.chunked {
+				body = io.MultiReader(body)
+			}
+			req := httptest.NewRequest(http.MethodPost, tt.path, body)
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			var resp api.Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("response is not JSON: %q", rec.Body.String())
+			}
+			if tt.want == http.StatusRequestEntityTooLarge && !strings.Contains(resp.Error, "exceeds 64 bytes") {
+				t.Errorf("error = %q, want the limit reported", resp.Error)
+			}
+		})
+	}
+}
+
+func TestOversizedBodyRejected(t *testing.T) {
+	// The server's default limit is 1 MiB
+	body := api.CreateNamespaceRequest{Namespace: strings.Repeat("a", defaultMaxRequestBodyBytes+1)}
+	status, resp := call(t, http.MethodPost, "/api/namespaces/create", "body-alice", body)
+	if status != http.StatusRequestEntityTooLarge || resp.Code == "" {
+		t.Errorf("oversized create = %d %+v, want 413 with a code", status, resp)
+	}
+}
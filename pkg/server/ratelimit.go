@@ -0,0 +1,156 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/api"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/auth"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/proxy"
+)
+
+// rateLimiterIdleTTL is how long an unused per-user limiter is kept before it is dropped
+const rateLimiterIdleTTL = 10 * time.Minute
+
+// RateLimitConfig limits each authenticated caller to a token bucket of RequestsPerSecond with Burst capacity
+type RateLimitConfig struct {
+	RequestsPerSecond float64
+	// Burst defaults to the per-second rate rounded up
+	Burst int
+	// ExemptGroups are never rate limited, such as cluster admins
+	ExemptGroups []string
+}
+
+// validateRateLimitConfig checks that cfg describes a usable token bucket
+func validateRateLimitConfig(cfg *RateLimitConfig) error {
+	if cfg.RequestsPerSecond <= 0 {
+		return fmt.Errorf("requests per second must be positive")
+	}
+	if cfg.Burst < 0 {
+		return fmt.Errorf("burst must not be negative")
+	}
+	return nil
+}
+
+// userRateLimiter holds one token bucket per caller
+type userRateLimiter struct {
+	mu        sync.Mutex
+	limit     rate.Limit
+	burst     int
+	exempt    map[string]bool
+	limiters  map[string]*userLimiter
+	lastSweep time.Time
+}
+
+type userLimiter struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+func newUserRateLimiter(cfg *RateLimitConfig) *userRateLimiter {
+	burst := cfg.Burst
+	if burst == 0 {
+		burst = int(math.Ceil(cfg.RequestsPerSecond))
+	}
+	exempt := make(map[string]bool, len(cfg.ExemptGroups))
+	for _, group := range cfg.ExemptGroups {
+		exempt[group] = true
+	}
+	return &userRateLimiter{
+		limit:     rate.Limit(cfg.RequestsPerSecond),
+		burst:     burst,
+		exempt:    exempt,
+		limiters:  make(map[string]*userLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// reserve takes a token for user and returns zero when the request may proceed,
+// or how long the caller must wait before a token is available
+func (l *userRateLimiter) reserve(user *auth.UserInfo) time.Duration {
+	for _, group := range user.Groups {
+		if l.exempt[group] {
+			return 0
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	entry, ok := l.limiters[user.Username]
+	if !ok {
+		entry = &userLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[user.Username] = entry
+	}
+	entry.lastUsed = now
+
+	reservation := entry.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		// Rejected requests must not consume tokens
+		reservation.CancelAt(now)
+		return delay
+	}
+	return 0
+}
+
+// sweep drops limiters idle for longer than rateLimiterIdleTTL; an idle bucket is full
+// again, so dropping it does not change any caller's limit
+func (l *userRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimiterIdleTTL {
+		return
+	}
+	for username, entry := range l.limiters {
+		if now.Sub(entry.lastUsed) > rateLimiterIdleTTL {
+			delete(l.limiters, username)
+		}
+	}
+	l.lastSweep = now
+}
+
+// withRateLimit authenticates /api requests and rejects callers that exceed their
+// token bucket with 429 and a Retry-After header. The caller who sent the request is
+// limited, so impersonated requests count against the impersonator. The result is
+// kept on the request so handlers do not authenticate a second time.
+func withRateLimit(p *proxy.SpiceDBKubeProxy, cfg *RateLimitConfig, next http.Handler) http.Handler {
+	if cfg == nil {
+		return next
+	}
+	limiter := newUserRateLimiter(cfg)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The demo endpoint is static and served without authentication by default
+		if !strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/api/demo" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r = withAuthentication(p, r)
+		user, err := authenticate(p, r)
+		if err != nil {
+			// Handlers reply to unauthenticated requests themselves
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		caller := user
+		if user.Impersonator != nil {
+			caller = user.Impersonator
+		}
+		if delay := limiter.reserve(caller); delay > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			writeJSON(w, http.StatusTooManyRequests, api.Response{Success: false, Error: "Rate limit exceeded"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
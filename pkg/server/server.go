@@ -2,275 +2,1920 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
-	"strings"
 	"time"
 
-	"k8s.io/client-go/rest"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/api"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/auth"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/metrics"
 	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/proxy"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/requestid"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/tracing"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/version"
+)
+
+const (
+	// readinessInitialBackoff is the delay after the first failed readiness check during startup
+	readinessInitialBackoff = 100 * time.Millisecond
+	// readinessMaxBackoff caps the exponential delay between readiness checks
+	readinessMaxBackoff = 5 * time.Second
+	// readinessCheckTimeout bounds a single readiness check
+	readinessCheckTimeout = 2 * time.Second
 )
 
 // Server wraps the embedded SpiceDB proxy for HTTP API access
 type Server struct {
-	proxy  *proxy.SpiceDBKubeProxy
-	server *http.Server
+	proxy     *proxy.SpiceDBKubeProxy
+	server    *http.Server
+	logger    *slog.Logger
+	stopProxy context.CancelFunc
+	requests  *requestTracker
 }
 
 // NewServer creates a new HTTP server with the embedded proxy
-func NewServer() (*Server, error) {
+func NewServer(cfg Config) (*Server, error) {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	// Validate the listen address up front rather than failing in ListenAndServe after startup
+	listenAddr := cfg.ListenAddr
+	if listenAddr == "" {
+		listenAddr = defaultListenAddr
+	}
+	if err := validateListenAddr(listenAddr); err != nil {
+		return nil, fmt.Errorf("invalid listen address: %w", err)
+	}
+
+	var tlsConfig *tls.Config
+	if cfg.TLS != nil {
+		c, err := buildTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+		}
+		tlsConfig = c
+	}
+
+	if cfg.CORS != nil {
+		if err := validateCORSConfig(cfg.CORS); err != nil {
+			return nil, fmt.Errorf("invalid CORS configuration: %w", err)
+		}
+	}
+	if cfg.RateLimit != nil {
+		if err := validateRateLimitConfig(cfg.RateLimit); err != nil {
+			return nil, fmt.Errorf("invalid rate limit configuration: %w", err)
+		}
+	}
+	if err := validateKubeConfigSource(cfg.KubeConfigSource); err != nil {
+		return nil, fmt.Errorf("invalid Kubernetes config source: %w", err)
+	}
+	if err := validateDemoAccess(cfg.DemoAccess); err != nil {
+		return nil, fmt.Errorf("invalid demo endpoint access: %w", err)
+	}
+
 	// Set cache directory to writable location
 	err := os.Setenv("KUBECACHEDIR", "/tmp/kube-cache")
 	if err != nil {
-		log.Printf("Warning: failed to set KUBECACHEDIR: %v", err)
+		logger.Warn("failed to set KUBECACHEDIR", "error", err)
 	}
 
 	// Create cache directory if it doesn't exist
 	if err := os.MkdirAll("/tmp/kube-cache", 0755); err != nil {
-		log.Printf("Warning: failed to create cache directory: %v", err)
+		logger.Warn("failed to create cache directory", "error", err)
 	}
 
-	// Get in-cluster config
-	kubeConfig, err := rest.InClusterConfig()
+	// Get the backend cluster config, from the pod or a kubeconfig file
+	kubeConfig, err := loadKubeConfig(cfg.KubeConfigSource, cfg.KubeConfigPath, logger)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get in-cluster config: %w", err)
+		return nil, err
+	}
+
+	authOpts := []auth.Option{auth.WithLogger(logger)}
+	if cfg.OIDC != nil {
+		authOpts = append(authOpts, auth.WithOIDC(*cfg.OIDC))
+	}
+	if cfg.SARCacheTTL > 0 {
+		authOpts = append(authOpts, auth.WithSubjectAccessReviewCache(cfg.SARCacheTTL, cfg.SARCacheMaxSize))
+	}
+	if len(cfg.TokenAudiences) > 0 {
+		authOpts = append(authOpts, auth.WithTokenAudiences(cfg.TokenAudiences...))
+	}
+	if cfg.Impersonation {
+		authOpts = append(authOpts, auth.WithImpersonation())
+	}
+	if cfg.TokenCookie != "" {
+		authOpts = append(authOpts, auth.WithCookieAuth(auth.CookieAuthConfig{Name: cfg.TokenCookie, RequireTLS: cfg.TLS != nil}))
+	}
+	if cfg.DisableHeaderAuth {
+		authOpts = append(authOpts, auth.WithoutHeaderAuth())
+	} else {
+		authOpts = append(authOpts, auth.WithHeaderAuth(cfg.HeaderAuth))
+	}
+	if cfg.BasicAuth {
+		var credentials map[string]auth.BasicCredential
+		if cfg.BasicAuthFile != "" {
+			credentials, err = auth.LoadBasicCredentialsFile(cfg.BasicAuthFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+		authOpts = append(authOpts, auth.WithBasicAuth(credentials))
+	} else if cfg.BasicAuthFile != "" {
+		return nil, fmt.Errorf("basic auth file %s is set but basic auth is disabled", cfg.BasicAuthFile)
+	}
+
+	// Parse seed relationships before starting anything so a bad file fails fast
+	var seedRelationships []*v1.Relationship
+	if cfg.SeedRelationshipsFile != "" {
+		rels, err := proxy.LoadSeedRelationships(cfg.SeedRelationshipsFile)
+		if err != nil {
+			return nil, err
+		}
+		if seedRelationships, err = proxy.ParseSeedRelationships(rels); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.SeedRelationshipsFile, err)
+		}
+	}
+
+	proxyOpts := []proxy.Option{proxy.WithAuthenticatorOptions(authOpts...), proxy.WithLogger(logger)}
+	if cfg.AuditSink != nil {
+		proxyOpts = append(proxyOpts, proxy.WithAuditSink(cfg.AuditSink))
+	}
+	if cfg.BootstrapFile != "" {
+		proxyOpts = append(proxyOpts, proxy.WithBootstrapFile(cfg.BootstrapFile))
+	}
+	if cfg.RulesPath != "" {
+		proxyOpts = append(proxyOpts, proxy.WithRulesPath(cfg.RulesPath))
+	}
+	if len(cfg.NamespaceCreateRelationships) > 0 {
+		proxyOpts = append(proxyOpts, proxy.WithNamespaceCreateRelationships(cfg.NamespaceCreateRelationships...))
+	}
+	if cfg.Timeouts != (proxy.Timeouts{}) {
+		proxyOpts = append(proxyOpts, proxy.WithTimeouts(cfg.Timeouts))
+	}
+	switch cfg.WorkflowDBPath {
+	case "":
+	case InMemoryWorkflowDBPath:
+		proxyOpts = append(proxyOpts, proxy.WithInMemoryWorkflowDatabase())
+	default:
+		proxyOpts = append(proxyOpts, proxy.WithWorkflowDatabasePath(cfg.WorkflowDBPath))
+	}
+	if cfg.ClientCacheIdleTTL > 0 {
+		proxyOpts = append(proxyOpts, proxy.WithClientCache(cfg.ClientCacheIdleTTL, cfg.ClientCacheMaxSize))
+	}
+	if cfg.CheckConcurrency > 0 {
+		proxyOpts = append(proxyOpts, proxy.WithCheckConcurrency(cfg.CheckConcurrency))
 	}
 
 	// Create proxy
-	proxy, err := proxy.NewSpiceDBKubeProxy(context.Background(), kubeConfig)
+	kubeProxy, err := proxy.NewSpiceDBKubeProxy(context.Background(), kubeConfig, proxyOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create proxy: %w", err)
 	}
 
-	// Start the proxy
-	if err := proxy.Start(context.Background()); err != nil {
+	// Start the proxy; Stop cancels proxyCtx to shut it down
+	proxyCtx, stopProxy := context.WithCancel(context.Background())
+	if err := kubeProxy.Start(proxyCtx); err != nil {
+		stopProxy()
 		return nil, fmt.Errorf("failed to start proxy: %w", err)
 	}
 
 	// Wait for proxy to be ready
-	time.Sleep(2 * time.Second)
+	readyTimeout := cfg.ReadinessTimeout
+	if readyTimeout <= 0 {
+		readyTimeout = defaultReadinessTimeout
+	}
+	if err := waitForProxyReady(kubeProxy, readyTimeout); err != nil {
+		stopProxy()
+		return nil, err
+	}
+
+	// Designate the initial cluster admins, who can manage every namespace
+	for _, admin := range cfg.ClusterAdmins {
+		if err := kubeProxy.GrantClusterAdmin(context.Background(), sanitizeUserName(admin)); err != nil {
+			stopProxy()
+			return nil, fmt.Errorf("failed to grant cluster admin to %s: %w", admin, err)
+		}
+		logger.Info("granted cluster admin", "user", admin)
+	}
+
+	if len(seedRelationships) > 0 {
+		created, skipped, err := kubeProxy.SeedRelationships(context.Background(), seedRelationships)
+		if err != nil {
+			stopProxy()
+			return nil, err
+		}
+		logger.Info("seeded relationships", "file", cfg.SeedRelationshipsFile, "created", created, "skipped", skipped)
+	}
 
 	// Create HTTP server
 	mux := http.NewServeMux()
+	requests := newRequestTracker()
 
 	// Health endpoints
+	// Liveness only reports that the process is serving; ?verbose adds the dependency probes
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("ok"))
+		if !r.URL.Query().Has("verbose") {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("ok"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+		defer cancel()
+
+		health := kubeProxy.ProxyHealth(ctx)
+		status := http.StatusOK
+		if !health.OK {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, health)
 	})
 
+	// Prometheus metrics
+	mux.Handle("/metrics", metrics.Handler())
+
 	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+		defer cancel()
+
+		if !kubeProxy.IsReady(ctx) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready"))
+			return
+		}
+
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("ready"))
 	})
 
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+		defer cancel()
+
+		resp := struct {
+			version.Info
+			SchemaRevision string `json:"schemaRevision,omitempty"`
+		}{Info: version.Get()}
+
+		// Build info is still useful while SpiceDB is unavailable, so a schema read failure is only logged
+		revision, err := kubeProxy.SchemaRevision(ctx)
+		if err != nil {
+			logger.WarnContext(r.Context(), "failed to read SpiceDB schema revision", "error", err)
+		}
+		resp.SchemaRevision = revision
+
+		writeJSON(w, http.StatusOK, resp)
+	})
+
 	// API endpoints with real authentication
-	mux.HandleFunc("/api/namespaces/create", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	mux.HandleFunc("/api/namespaces/create", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.CreateNamespaceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.Namespace == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Namespace is required"})
+			return
+		}
+		if err := validateNamespaceName(req.Namespace); err != nil {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: err.Error()})
+			return
+		}
+
+		// Check Kubernetes RBAC permission first
+		if !checkPermission(w, r, kubeProxy, user, "namespaces", "create", "", api.Response{Error: "User does not have permission to create namespaces", Data: dryRunDecision(req.DryRun, false)}) {
+			return
+		}
+
+		// Use authenticated user for namespace creation
+		if err := kubeProxy.CreateNamespaceAsUser(r.Context(), sanitizeUserName(user.Username), user.Groups, req.Namespace, req.DryRun); err != nil {
+			status := statusForError(err)
+			writeJSON(w, status, api.Response{Success: false, Error: err.Error(), Code: codeForError(err, status), Data: dryRunDecision(req.DryRun, false)})
+			return
+		}
+
+		if req.DryRun {
+			writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{"namespace": req.Namespace, "user": sanitizeUserName(user.Username), "dryRun": true, "allowed": true}})
 			return
 		}
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]string{"namespace": req.Namespace, "user": sanitizeUserName(user.Username)}})
+	})))
 
-		// Authenticate user from request headers
-		user, err := proxy.AuthenticateFromRequest(r)
+	mux.HandleFunc("/api/namespaces/list", withMethod(http.MethodPost, withAuth(kubeProxy, withPermission(kubeProxy, "namespaces", "list", func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		// The request body is optional for listing
+		var req api.ListNamespacesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.Limit < 0 {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Limit must not be negative"})
+			return
+		}
+		selector, err := labels.Parse(req.LabelSelector)
 		if err != nil {
-			writeJSON(w, api.Response{Success: false, Error: fmt.Sprintf("Authentication failed: %v", err)})
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: fmt.Sprintf("Invalid labelSelector: %v", err)})
 			return
 		}
 
-		var req api.CreateNamespaceRequest
+		permission := req.Permission
+		if permission == "" {
+			permission = proxy.DefaultListPermission
+		}
+
+		var namespaces []string
+		var continueToken string
+		if req.Fast {
+			consistency := proxy.NewConsistency(req.FullyConsistent, req.AtLeastAsFresh)
+			namespaces, err = kubeProxy.ListNamespacesWithPermission(r.Context(), sanitizeUserName(user.Username), permission, consistency, selector)
+		} else {
+			namespaces, continueToken, err = kubeProxy.ListNamespacesAsUser(r.Context(), sanitizeUserName(user.Username), user.Groups, proxy.ListNamespacesOptions{
+				Limit:         req.Limit,
+				Continue:      req.Continue,
+				LabelSelector: selector.String(),
+				Permission:    permission,
+			})
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{"namespaces": namespaces, "continue": continueToken, "permission": permission, "user": sanitizeUserName(user.Username)}})
+	}))))
+
+	mux.HandleFunc("/api/namespaces/get", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.GetNamespaceRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeJSON(w, api.Response{Success: false, Error: "Invalid JSON"})
+			writeDecodeError(w, err)
 			return
 		}
 
 		if req.Namespace == "" {
-			writeJSON(w, api.Response{Success: false, Error: "Namespace is required"})
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Namespace is required"})
 			return
 		}
 
 		// Check Kubernetes RBAC permission first
-		allowed, err := proxy.CheckKubernetesPermission(r.Context(), user, "namespaces", "create", "")
-		if err != nil {
-			writeJSON(w, api.Response{Success: false, Error: fmt.Sprintf("Permission check failed: %v", err)})
+		if !checkPermission(w, r, kubeProxy, user, "namespaces", "get", req.Namespace, api.Response{Error: "User does not have permission to get namespaces"}) {
 			return
 		}
-		if !allowed {
-			writeJSON(w, api.Response{Success: false, Error: "User does not have permission to create namespaces"})
+
+		// SpiceDB view permission is enforced by the namespace get proxyrule
+		ns, err := kubeProxy.GetNamespaceAsUser(r.Context(), sanitizeUserName(user.Username), user.Groups, req.Namespace)
+		auditSpiceDBDecision(r.Context(), kubeProxy, user, "namespaces", "get", req.Namespace, err)
+		if err != nil {
+			writeError(w, err)
 			return
 		}
 
-		// Use authenticated user for namespace creation
-		if err := proxy.CreateNamespaceAsUser(r.Context(), sanitizeUserName(user.Username), req.Namespace); err != nil {
-			writeJSON(w, api.Response{Success: false, Error: err.Error()})
+		// Only namespace admins learn who created the namespace
+		resp, err := kubeProxy.CheckPermission(r.Context(), "namespace", req.Namespace, "admin", "user", sanitizeUserName(user.Username), nil)
+		if err == nil && resp.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
+			if creators, err := kubeProxy.NamespaceCreators(r.Context(), req.Namespace); err == nil && len(creators) > 0 {
+				ns.Creator = userNameFromSubjectID(creators[0])
+			}
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: ns})
+	})))
+
+	mux.HandleFunc("/api/namespaces/delete", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.DeleteNamespaceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
 			return
 		}
 
-		writeJSON(w, api.Response{Success: true, Data: map[string]string{"namespace": req.Namespace, "user": sanitizeUserName(user.Username)}})
-	})
+		if req.Namespace == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Namespace is required"})
+			return
+		}
 
-	mux.HandleFunc("/api/namespaces/list", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		// Check Kubernetes RBAC permission first
+		if !checkPermission(w, r, kubeProxy, user, "namespaces", "delete", req.Namespace, api.Response{Error: "User does not have permission to delete namespaces"}) {
 			return
 		}
 
-		// Authenticate user from request headers
-		user, err := proxy.AuthenticateFromRequest(r)
+		// SpiceDB admin permission is enforced by the namespace delete proxyrule
+		err = kubeProxy.DeleteNamespaceAsUser(r.Context(), sanitizeUserName(user.Username), user.Groups, req.Namespace)
+		auditSpiceDBDecision(r.Context(), kubeProxy, user, "namespaces", "delete", req.Namespace, err)
 		if err != nil {
-			writeJSON(w, api.Response{Success: false, Error: fmt.Sprintf("Authentication failed: %v", err)})
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]string{"namespace": req.Namespace, "user": sanitizeUserName(user.Username)}})
+	})))
+
+	mux.HandleFunc("/api/namespaces/update", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.UpdateNamespaceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.Namespace == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Namespace is required"})
+			return
+		}
+		if len(req.Labels) == 0 && len(req.Annotations) == 0 {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Labels or annotations are required"})
 			return
 		}
 
 		// Check Kubernetes RBAC permission first
-		allowed, err := proxy.CheckKubernetesPermission(r.Context(), user, "namespaces", "list", "")
+		if !checkPermission(w, r, kubeProxy, user, "namespaces", "patch", req.Namespace, api.Response{Error: "User does not have permission to update namespaces"}) {
+			return
+		}
+
+		// SpiceDB edit permission is enforced by the namespace update proxyrule
+		ns, err := kubeProxy.UpdateNamespaceAsUser(r.Context(), sanitizeUserName(user.Username), user.Groups, req.Namespace, proxy.NamespaceUpdate{
+			Labels:      req.Labels,
+			Annotations: req.Annotations,
+		})
+		auditSpiceDBDecision(r.Context(), kubeProxy, user, "namespaces", "patch", req.Namespace, err)
 		if err != nil {
-			writeJSON(w, api.Response{Success: false, Error: fmt.Sprintf("Permission check failed: %v", err)})
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: ns})
+	})))
+
+	mux.HandleFunc("/api/namespaces/grant-view", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.GrantViewPermissionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.Namespace == "" || req.User == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Both namespace and user are required"})
+			return
+		}
+
+		// Check if user has admin permission on the namespace
+		if !checkPermission(w, r, kubeProxy, user, "namespaces", "update", req.Namespace, api.Response{Error: "User does not have permission to grant access to this namespace", Data: dryRunDecision(req.DryRun, false)}) {
 			return
 		}
-		if !allowed {
-			writeJSON(w, api.Response{Success: false, Error: "User does not have permission to list namespaces"})
+
+		if req.DryRun {
+			writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{
+				"namespace":  req.Namespace,
+				"user":       sanitizeUserName(req.User),
+				"permission": "view",
+				"granted_by": sanitizeUserName(user.Username),
+				"dryRun":     true,
+				"allowed":    true,
+			}})
 			return
 		}
 
-		namespaces, err := proxy.ListNamespacesAsUser(r.Context(), sanitizeUserName(user.Username))
+		// Grant view permission in SpiceDB
+		writtenAt, err := kubeProxy.GrantViewPermission(r.Context(), req.Namespace, sanitizeUserName(req.User), time.Time{})
 		if err != nil {
-			writeJSON(w, api.Response{Success: false, Error: err.Error()})
+			writeJSON(w, statusForError(err), api.Response{Success: false, Error: fmt.Sprintf("Failed to grant view permission: %v", err)})
 			return
 		}
 
-		writeJSON(w, api.Response{Success: true, Data: map[string]interface{}{"namespaces": namespaces, "user": sanitizeUserName(user.Username)}})
-	})
+		data := map[string]interface{}{
+			"namespace":  req.Namespace,
+			"user":       sanitizeUserName(req.User),
+			"permission": "view",
+			"granted_by": sanitizeUserName(user.Username),
+			"written_at": writtenAt,
+		}
+		if req.ReturnViewers {
+			// Read fully consistent so the grant just written is included. The grant stands
+			// even when the readback fails, so that is reported without failing the request.
+			viewerIDs, err := kubeProxy.ListNamespaceViewers(r.Context(), req.Namespace, proxy.NewConsistency(true, ""))
+			if err != nil {
+				data["viewersError"] = fmt.Sprintf("Failed to list namespace viewers: %v", err)
+			} else {
+				viewers := make([]string, 0, len(viewerIDs))
+				for _, id := range viewerIDs {
+					viewers = append(viewers, userNameFromSubjectID(id))
+				}
+				data["viewers"] = viewers
+			}
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: data})
+	})))
+
+	mux.HandleFunc("/api/namespaces/grant-view-bulk", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.GrantViewBulkRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.Namespace == "" || len(req.Users) == 0 {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Namespace and at least one user are required"})
+			return
+		}
+		if len(req.Users) > proxy.MaxGrantViewBulkUsers {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: fmt.Sprintf("At most %d users can be granted at once", proxy.MaxGrantViewBulkUsers)})
+			return
+		}
+		users := make([]string, 0, len(req.Users))
+		for _, u := range req.Users {
+			if u == "" {
+				writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Users must not be empty"})
+				return
+			}
+			users = append(users, sanitizeUserName(u))
+		}
 
-	mux.HandleFunc("/api/namespaces/grant-view", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		// One check covers the whole batch, as with grant-view
+		if !checkPermission(w, r, kubeProxy, user, "namespaces", "update", req.Namespace, api.Response{Error: "User does not have permission to grant access to this namespace"}) {
 			return
 		}
 
-		// Authenticate user from request headers
-		user, err := proxy.AuthenticateFromRequest(r)
+		results, err := kubeProxy.GrantViewPermissionBulk(r.Context(), req.Namespace, users)
 		if err != nil {
-			writeJSON(w, api.Response{Success: false, Error: fmt.Sprintf("Authentication failed: %v", err)})
+			writeJSON(w, statusForError(err), api.Response{Success: false, Error: fmt.Sprintf("Failed to grant view permission, no users were granted: %v", err)})
 			return
 		}
 
-		var req api.GrantViewPermissionRequest
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{
+			"namespace":  req.Namespace,
+			"permission": "view",
+			"granted_by": sanitizeUserName(user.Username),
+			"results":    results,
+		}})
+	})))
+
+	mux.HandleFunc("/api/namespaces/grant-view-temp", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.GrantTemporaryViewPermissionRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeJSON(w, api.Response{Success: false, Error: "Invalid JSON"})
+			writeDecodeError(w, err)
 			return
 		}
 
-		if req.Namespace == "" || req.User == "" {
-			writeJSON(w, api.Response{Success: false, Error: "Both namespace and user are required"})
+		if req.Namespace == "" || req.User == "" || req.Duration == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Namespace, user and duration are required"})
+			return
+		}
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil || duration <= 0 {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Duration must be a positive Go duration such as 30m or 24h"})
 			return
 		}
 
 		// Check if user has admin permission on the namespace
-		allowed, err := proxy.CheckKubernetesPermission(r.Context(), user, "namespaces", "update", req.Namespace)
+		if !checkPermission(w, r, kubeProxy, user, "namespaces", "update", req.Namespace, api.Response{Error: "User does not have permission to grant access to this namespace"}) {
+			return
+		}
+
+		expiresAt := time.Now().Add(duration).UTC()
+		writtenAt, err := kubeProxy.GrantViewPermission(r.Context(), req.Namespace, sanitizeUserName(req.User), expiresAt)
 		if err != nil {
-			writeJSON(w, api.Response{Success: false, Error: fmt.Sprintf("Permission check failed: %v", err)})
+			writeJSON(w, statusForError(err), api.Response{Success: false, Error: fmt.Sprintf("Failed to grant view permission: %v", err)})
 			return
 		}
-		if !allowed {
-			writeJSON(w, api.Response{Success: false, Error: "User does not have permission to grant access to this namespace"})
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]string{
+			"namespace":  req.Namespace,
+			"user":       sanitizeUserName(req.User),
+			"permission": "view",
+			"granted_by": sanitizeUserName(user.Username),
+			"expires_at": expiresAt.Format(time.RFC3339),
+			"written_at": writtenAt,
+		}})
+	})))
+
+	mux.HandleFunc("/api/namespaces/grant-view-group", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.GrantGroupViewPermissionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
 			return
 		}
 
-		// Grant view permission in SpiceDB
-		if err := proxy.GrantViewPermission(r.Context(), req.Namespace, sanitizeUserName(req.User)); err != nil {
-			writeJSON(w, api.Response{Success: false, Error: fmt.Sprintf("Failed to grant view permission: %v", err)})
+		if req.Namespace == "" || req.Group == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Both namespace and group are required"})
+			return
+		}
+
+		// Check if user has admin permission on the namespace
+		if !checkPermission(w, r, kubeProxy, user, "namespaces", "update", req.Namespace, api.Response{Error: "User does not have permission to grant access to this namespace"}) {
+			return
+		}
+
+		// Grant view permission to the group's members in SpiceDB
+		if err := kubeProxy.GrantViewToGroup(r.Context(), req.Namespace, req.Group); err != nil {
+			writeJSON(w, statusForError(err), api.Response{Success: false, Error: fmt.Sprintf("Failed to grant view permission: %v", err)})
 			return
 		}
 
-		writeJSON(w, api.Response{
-			Success: true, 
+		writeJSON(w, http.StatusOK, api.Response{
+			Success: true,
 			Data: map[string]string{
-				"namespace": req.Namespace,
-				"user": sanitizeUserName(req.User),
+				"namespace":  req.Namespace,
+				"group":      req.Group,
 				"permission": "view",
 				"granted_by": sanitizeUserName(user.Username),
 			},
 		})
-	})
+	})))
 
-	// Example usage endpoint
-	mux.HandleFunc("/api/demo", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	mux.HandleFunc("/api/groups/add-member", withMethod(http.MethodPost, withAuth(kubeProxy, addGroupMemberHandler(kubeProxy, logger))))
+	mux.HandleFunc("/api/groups/remove-member", withMethod(http.MethodPost, withAuth(kubeProxy, removeGroupMemberHandler(kubeProxy, logger))))
+	mux.HandleFunc("/api/groups/members", withMethod(http.MethodPost, withAuth(kubeProxy, listGroupMembersHandler(kubeProxy))))
+
+	mux.HandleFunc("/api/namespaces/revoke-view", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.RevokeViewPermissionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
 			return
 		}
 
-		demo := map[string]interface{}{
-			"message": "SpiceDB KubeAPI Proxy Integration Demo",
-			"endpoints": map[string]string{
-				"create_namespace": "POST /api/namespaces/create",
-				"list_namespaces":  "POST /api/namespaces/list",
-				"grant_view":       "POST /api/namespaces/grant-view",
-				"health":           "GET /healthz",
-				"ready":            "GET /readyz",
-			},
-			"example_requests": map[string]interface{}{
-				"create_namespace": map[string]string{
-					"namespace": "alice-workspace",
-				},
-				"list_namespaces": map[string]string{},
-				"grant_view": map[string]string{
-					"namespace": "alice-workspace",
-					"user":      "bob",
-				},
+		if req.Namespace == "" || req.User == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Both namespace and user are required"})
+			return
+		}
+
+		// Check if user has admin permission on the namespace
+		if !checkPermission(w, r, kubeProxy, user, "namespaces", "update", req.Namespace, api.Response{Error: "User does not have permission to revoke access to this namespace"}) {
+			return
+		}
+
+		// Revoke view permission in SpiceDB
+		deleted, err := kubeProxy.RevokeViewPermission(r.Context(), req.Namespace, sanitizeUserName(req.User))
+		if err != nil {
+			writeJSON(w, statusForError(err), api.Response{Success: false, Error: fmt.Sprintf("Failed to revoke view permission: %v", err)})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{
+			Success: true,
+			Data: map[string]interface{}{
+				"namespace":             req.Namespace,
+				"user":                  sanitizeUserName(req.User),
+				"permission":            "view",
+				"revoked_by":            sanitizeUserName(user.Username),
+				"relationships_deleted": deleted,
 			},
+		})
+	})))
+
+	mux.HandleFunc("/api/namespaces/viewers", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.ListNamespaceViewersRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
 		}
 
-		writeJSON(w, api.Response{Success: true, Data: demo})
-	})
+		if req.Namespace == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Namespace is required"})
+			return
+		}
 
-	server := &http.Server{
-		Addr:    ":8080",
-		Handler: mux,
-	}
+		// Check if user has admin permission on the namespace
+		if !checkPermission(w, r, kubeProxy, user, "namespaces", "update", req.Namespace, api.Response{Error: "User does not have permission to list viewers of this namespace"}) {
+			return
+		}
 
-	return &Server{
-		proxy:  proxy,
-		server: server,
-	}, nil
-}
+		consistency := proxy.NewConsistency(req.FullyConsistent, req.AtLeastAsFresh)
+		viewerIDs, err := kubeProxy.ListNamespaceViewers(r.Context(), req.Namespace, consistency)
+		if err != nil {
+			writeJSON(w, statusForError(err), api.Response{Success: false, Error: fmt.Sprintf("Failed to list namespace viewers: %v", err)})
+			return
+		}
 
-// sanitizeUserName converts user names to be valid SpiceDB object IDs
-// For service accounts, extract just the service account name (e.g., testuser from system:serviceaccount:spicedb-proxy:testuser)
-// For other users, replace invalid characters with underscores
-func sanitizeUserName(userName string) string {
-	// Check if this is a service account name
-	if strings.HasPrefix(userName, "system:serviceaccount:") {
-		// Extract the service account name from system:serviceaccount:namespace:name
-		parts := strings.Split(userName, ":")
-		if len(parts) >= 4 {
-			return parts[3] // Return just the service account name
+		// Report Kubernetes user names rather than their SpiceDB IDs
+		viewers := make([]string, 0, len(viewerIDs))
+		for _, id := range viewerIDs {
+			viewers = append(viewers, userNameFromSubjectID(id))
 		}
-	}
 
-	return userName
-}
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{"namespace": req.Namespace, "viewers": viewers}})
+	})))
 
-func writeJSON(w http.ResponseWriter, v interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(v)
-}
+	mux.HandleFunc("/api/namespaces/grant-edit", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.GrantEditPermissionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
 
-// Start starts the HTTP server
-func (s *Server) Start() error {
-	log.Printf("Starting server on %s", s.server.Addr)
-	return s.server.ListenAndServe()
-}
+		if req.Namespace == "" || req.User == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Both namespace and user are required"})
+			return
+		}
 
-// Stop gracefully stops the server
-func (s *Server) Stop(ctx context.Context) error {
-	return s.server.Shutdown(ctx)
+		// Check if user has admin permission on the namespace
+		if !checkPermission(w, r, kubeProxy, user, "namespaces", "update", req.Namespace, api.Response{Error: "User does not have permission to grant access to this namespace"}) {
+			return
+		}
+
+		// Grant edit permission in SpiceDB
+		if err := kubeProxy.GrantEditPermission(r.Context(), req.Namespace, sanitizeUserName(req.User)); err != nil {
+			writeJSON(w, statusForError(err), api.Response{Success: false, Error: fmt.Sprintf("Failed to grant edit permission: %v", err)})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{
+			Success: true,
+			Data: map[string]string{
+				"namespace":  req.Namespace,
+				"user":       sanitizeUserName(req.User),
+				"permission": "edit",
+				"granted_by": sanitizeUserName(user.Username),
+			},
+		})
+	})))
+
+	mux.HandleFunc("/api/namespaces/transfer-ownership", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.TransferNamespaceOwnershipRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.Namespace == "" || req.NewOwner == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Both namespace and newOwner are required"})
+			return
+		}
+
+		// Only namespace admins, the current creator or a cluster admin, may hand the namespace to someone else
+		consistency := proxy.NewConsistency(true, "")
+		resp, err := kubeProxy.CheckPermission(r.Context(), "namespace", req.Namespace, "admin", "user", sanitizeUserName(user.Username), consistency)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, api.Response{Success: false, Error: fmt.Sprintf("Permission check failed: %v", err)})
+			return
+		}
+		if resp.Permissionship != v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION {
+			writeJSON(w, http.StatusForbidden, api.Response{Success: false, Error: "Only namespace admins can transfer ownership"})
+			return
+		}
+
+		oldOwner, err := kubeProxy.TransferNamespaceOwnership(r.Context(), req.Namespace, sanitizeUserName(req.NewOwner))
+		if err != nil {
+			writeJSON(w, statusForError(err), api.Response{Success: false, Error: fmt.Sprintf("Failed to transfer ownership: %v", err)})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{
+			Success: true,
+			Data: map[string]string{
+				"namespace": req.Namespace,
+				"old_owner": userNameFromSubjectID(oldOwner),
+				"new_owner": sanitizeUserName(req.NewOwner),
+			},
+		})
+	})))
+
+	mux.HandleFunc("/api/pods/create", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.CreatePodRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.Namespace == "" || req.Name == "" || req.Image == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Namespace, name and image are required"})
+			return
+		}
+
+		// Check Kubernetes RBAC permission first
+		if !checkPermission(w, r, kubeProxy, user, "pods", "create", req.Namespace, api.Response{Error: "User does not have permission to create pods in this namespace"}) {
+			return
+		}
+
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: req.Name, Namespace: req.Namespace},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: req.Name, Image: req.Image}},
+			},
+		}
+
+		// The pod create proxyrule records the pod creator and namespace relationships in SpiceDB
+		podName, err := kubeProxy.CreatePodAsUser(r.Context(), sanitizeUserName(user.Username), user.Groups, req.Namespace, pod)
+		auditSpiceDBDecision(r.Context(), kubeProxy, user, "pods", "create", req.Namespace, err)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]string{"pod": podName, "namespace": req.Namespace, "user": sanitizeUserName(user.Username)}})
+	})))
+
+	mux.HandleFunc("/api/pods/list", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.ListPodsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.Namespace == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Namespace is required"})
+			return
+		}
+
+		// Check Kubernetes RBAC permission first
+		if !checkPermission(w, r, kubeProxy, user, "pods", "list", req.Namespace, api.Response{Error: "User does not have permission to list pods in this namespace"}) {
+			return
+		}
+
+		pods, err := kubeProxy.ListPodsAsUser(r.Context(), sanitizeUserName(user.Username), user.Groups, req.Namespace)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{"pods": pods, "namespace": req.Namespace, "user": sanitizeUserName(user.Username)}})
+	})))
+
+	mux.HandleFunc("/api/pods/delete", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.DeletePodRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.Namespace == "" || req.Name == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Both namespace and name are required"})
+			return
+		}
+
+		// Check Kubernetes RBAC permission first
+		if !checkPermission(w, r, kubeProxy, user, "pods", "delete", req.Namespace, api.Response{Error: "User does not have permission to delete pods in this namespace"}) {
+			return
+		}
+
+		// SpiceDB edit permission on the pod is enforced by the pod delete proxyrule
+		err = kubeProxy.DeletePodAsUser(r.Context(), sanitizeUserName(user.Username), user.Groups, req.Namespace, req.Name)
+		auditSpiceDBDecision(r.Context(), kubeProxy, user, "pods", "delete", req.Namespace, err)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]string{"pod": req.Name, "namespace": req.Namespace, "user": sanitizeUserName(user.Username)}})
+	})))
+
+	mux.HandleFunc("/api/pods/check", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.CheckPodAccessRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.Namespace == "" || req.Name == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Both namespace and name are required"})
+			return
+		}
+		if req.Permission != "view" && req.Permission != "edit" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "permission must be view or edit"})
+			return
+		}
+
+		// This is the check the get-pods and delete-pods rules make, on pods keyed by
+		// namespace/name as the create-pods rule writes them. Access derived from the pod's
+		// namespace relation is evaluated by SpiceDB, so it is reflected here once the schema grants it.
+		consistency := proxy.NewConsistency(req.FullyConsistent, req.AtLeastAsFresh)
+		resp, err := kubeProxy.CheckPermission(r.Context(), "pod", req.Namespace+"/"+req.Name, req.Permission, "user", sanitizeUserName(user.Username), consistency)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, api.Response{Success: false, Error: fmt.Sprintf("Permission check failed: %v", err)})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{
+			Success: true,
+			Data: map[string]interface{}{
+				"allowed":        resp.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION,
+				"permissionship": resp.Permissionship.String(),
+				"checked_at":     resp.GetCheckedAt().GetToken(),
+				"pod":            req.Name,
+				"namespace":      req.Namespace,
+				"permission":     req.Permission,
+				"user":           sanitizeUserName(user.Username),
+			},
+		})
+	})))
+
+	mux.HandleFunc("/api/configmaps/create", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.CreateConfigMapRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.Namespace == "" || req.Name == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Namespace and name are required"})
+			return
+		}
+
+		// Check Kubernetes RBAC permission first
+		if !checkPermission(w, r, kubeProxy, user, "configmaps", "create", req.Namespace, api.Response{Error: "User does not have permission to create configmaps in this namespace"}) {
+			return
+		}
+
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: req.Name, Namespace: req.Namespace},
+			Data:       req.Data,
+		}
+
+		// The configmap create proxyrule records the creator and namespace relationships in SpiceDB
+		name, err := kubeProxy.CreateConfigMapAsUser(r.Context(), sanitizeUserName(user.Username), user.Groups, req.Namespace, configMap)
+		auditSpiceDBDecision(r.Context(), kubeProxy, user, "configmaps", "create", req.Namespace, err)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]string{"configmap": name, "namespace": req.Namespace, "user": sanitizeUserName(user.Username)}})
+	})))
+
+	mux.HandleFunc("/api/configmaps/list", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.ListConfigMapsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.Namespace == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Namespace is required"})
+			return
+		}
+
+		// Check Kubernetes RBAC permission first
+		if !checkPermission(w, r, kubeProxy, user, "configmaps", "list", req.Namespace, api.Response{Error: "User does not have permission to list configmaps in this namespace"}) {
+			return
+		}
+
+		configMaps, err := kubeProxy.ListConfigMapsAsUser(r.Context(), sanitizeUserName(user.Username), user.Groups, req.Namespace)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{"configmaps": configMaps, "namespace": req.Namespace, "user": sanitizeUserName(user.Username)}})
+	})))
+
+	mux.HandleFunc("/api/deployments/create", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.CreateDeploymentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.Namespace == "" || req.Name == "" || req.Image == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Namespace, name and image are required"})
+			return
+		}
+		replicas := int32(1)
+		if req.Replicas != nil {
+			if *req.Replicas < 0 {
+				writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Replicas must not be negative"})
+				return
+			}
+			replicas = *req.Replicas
+		}
+
+		// Check Kubernetes RBAC permission first
+		if !checkPermission(w, r, kubeProxy, user, "deployments", "create", req.Namespace, api.Response{Error: "User does not have permission to create deployments in this namespace"}) {
+			return
+		}
+
+		podLabels := map[string]string{"app": req.Name}
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: req.Name, Namespace: req.Namespace},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: podLabels},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: req.Name, Image: req.Image}},
+					},
+				},
+			},
+		}
+
+		// The deployment create proxyrule records the creator and namespace relationships in SpiceDB
+		name, err := kubeProxy.CreateDeploymentAsUser(r.Context(), sanitizeUserName(user.Username), user.Groups, req.Namespace, deployment)
+		auditSpiceDBDecision(r.Context(), kubeProxy, user, "deployments", "create", req.Namespace, err)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]string{"deployment": name, "namespace": req.Namespace, "user": sanitizeUserName(user.Username)}})
+	})))
+
+	mux.HandleFunc("/api/deployments/list", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.ListDeploymentsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.Namespace == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Namespace is required"})
+			return
+		}
+
+		// Check Kubernetes RBAC permission first
+		if !checkPermission(w, r, kubeProxy, user, "deployments", "list", req.Namespace, api.Response{Error: "User does not have permission to list deployments in this namespace"}) {
+			return
+		}
+
+		deployments, err := kubeProxy.ListDeploymentsAsUser(r.Context(), sanitizeUserName(user.Username), user.Groups, req.Namespace)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{"deployments": deployments, "namespace": req.Namespace, "user": sanitizeUserName(user.Username)}})
+	})))
+
+	// Generic handler for any resource covered by the loaded proxy rules; verb is get, list, create, update or delete
+	mux.HandleFunc("/api/resources/{verb}", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.ResourceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		verb := r.PathValue("verb")
+		if req.Version == "" || req.Resource == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Version and resource are required"})
+			return
+		}
+		if (verb == "get" || verb == "delete") && req.Name == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Name is required for get and delete"})
+			return
+		}
+		if (verb == "create" || verb == "update") && req.Object == nil {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Object is required for create and update"})
+			return
+		}
+
+		// The matching proxyrules authorize the call in SpiceDB
+		result, err := kubeProxy.ResourceAsUser(r.Context(), sanitizeUserName(user.Username), user.Groups, proxy.ResourceRequest{
+			Verb:      verb,
+			Resource:  schema.GroupVersionResource{Group: req.Group, Version: req.Version, Resource: req.Resource},
+			Namespace: req.Namespace,
+			Name:      req.Name,
+			Object:    req.Object,
+		})
+		auditSpiceDBDecision(r.Context(), kubeProxy, user, req.Resource, verb, req.Namespace, err)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{"verb": verb, "result": result, "user": sanitizeUserName(user.Username)}})
+	})))
+
+	mux.HandleFunc("/api/capabilities", withMethod(http.MethodGet, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, _ *auth.UserInfo) {
+		// Metadata about the loaded rules, not a permission check, so no permission is required
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{"resources": kubeProxy.Capabilities()}})
+	})))
+
+	mux.HandleFunc("/api/whoami", withMethod(http.MethodGet, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		// The caller may always see who they are, so no permission is checked
+		identity := userIdentity(user)
+		if user.Impersonator != nil {
+			identity["impersonator"] = userIdentity(user.Impersonator)
+		}
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: identity})
+	})))
+
+	mux.HandleFunc("/api/permissions/check", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.CheckPermissionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.ResourceType == "" || req.ResourceID == "" || req.Permission == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "resourceType, resourceId and permission are required"})
+			return
+		}
+
+		// Default to checking the caller's own access
+		if req.SubjectType == "" {
+			req.SubjectType = "user"
+		}
+		if req.SubjectID == "" {
+			req.SubjectID = sanitizeUserName(user.Username)
+		}
+		if err := requireSelfOrClusterAdmin(r.Context(), kubeProxy, user, req.SubjectType, req.SubjectID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		consistency := proxy.NewConsistency(req.FullyConsistent, req.AtLeastAsFresh)
+		resp, err := kubeProxy.CheckPermission(r.Context(), req.ResourceType, req.ResourceID, req.Permission, req.SubjectType, req.SubjectID, consistency)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, api.Response{Success: false, Error: fmt.Sprintf("Permission check failed: %v", err)})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{
+			Success: true,
+			Data: map[string]interface{}{
+				"allowed":        resp.Permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION,
+				"permissionship": resp.Permissionship.String(),
+				"checked_at":     resp.GetCheckedAt().GetToken(),
+				"resource":       fmt.Sprintf("%s:%s", req.ResourceType, req.ResourceID),
+				"permission":     req.Permission,
+				"subject":        fmt.Sprintf("%s:%s", req.SubjectType, req.SubjectID),
+			},
+		})
+	})))
+
+	mux.HandleFunc("/api/permissions/bulk-check", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.BulkCheckRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if len(req.Items) == 0 {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "At least one item is required"})
+			return
+		}
+		if len(req.Items) > proxy.MaxBulkCheckItems {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: fmt.Sprintf("At most %d items can be checked at once", proxy.MaxBulkCheckItems)})
+			return
+		}
+		checks := make([]proxy.PermissionCheck, 0, len(req.Items))
+		for i, item := range req.Items {
+			if item.ResourceType == "" || item.ResourceID == "" || item.Permission == "" {
+				writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: fmt.Sprintf("items[%d]: resourceType, resourceId and permission are required", i)})
+				return
+			}
+			checks = append(checks, proxy.PermissionCheck{ResourceType: item.ResourceType, ResourceID: item.ResourceID, Permission: item.Permission})
+		}
+
+		// Default to checking the caller's own access
+		if req.SubjectType == "" {
+			req.SubjectType = "user"
+		}
+		if req.SubjectID == "" {
+			req.SubjectID = sanitizeUserName(user.Username)
+		}
+		if err := requireSelfOrClusterAdmin(r.Context(), kubeProxy, user, req.SubjectType, req.SubjectID); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		consistency := proxy.NewConsistency(req.FullyConsistent, req.AtLeastAsFresh)
+		resp, err := kubeProxy.CheckPermissionsBulk(r.Context(), checks, req.SubjectType, req.SubjectID, consistency)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, api.Response{Success: false, Error: fmt.Sprintf("Permission check failed: %v", err)})
+			return
+		}
+
+		results := make([]map[string]interface{}, 0, len(req.Items))
+		for i, pair := range resp.GetPairs() {
+			result := map[string]interface{}{
+				"resource":   fmt.Sprintf("%s:%s", req.Items[i].ResourceType, req.Items[i].ResourceID),
+				"permission": req.Items[i].Permission,
+			}
+			if pairErr := pair.GetError(); pairErr != nil {
+				result["allowed"] = false
+				result["error"] = pairErr.GetMessage()
+			} else {
+				permissionship := pair.GetItem().GetPermissionship()
+				result["allowed"] = permissionship == v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+				result["permissionship"] = permissionship.String()
+			}
+			results = append(results, result)
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{
+			Success: true,
+			Data: map[string]interface{}{
+				"results":    results,
+				"checked_at": resp.GetCheckedAt().GetToken(),
+				"subject":    fmt.Sprintf("%s:%s", req.SubjectType, req.SubjectID),
+			},
+		})
+	})))
+
+	mux.HandleFunc("/api/batch", withMethod(http.MethodPost, withAuth(kubeProxy, batchHandler(kubeProxy))))
+
+	mux.HandleFunc("/api/relationships/read", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.ReadRelationshipsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.ResourceType == "" && req.ResourceID == "" && req.Relation == "" && req.SubjectType == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "At least one of resourceType, resourceId, relation or subjectType is required"})
+			return
+		}
+		if req.Limit > proxy.MaxRelationshipPageSize {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: fmt.Sprintf("Limit must not exceed %d", proxy.MaxRelationshipPageSize)})
+			return
+		}
+
+		// The authorization graph reveals every grant, so only cluster admins may read it
+		if err := requireClusterAdmin(r.Context(), kubeProxy, user); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		query := proxy.RelationshipQuery{
+			ResourceType: req.ResourceType,
+			ResourceID:   req.ResourceID,
+			Relation:     req.Relation,
+			SubjectType:  req.SubjectType,
+			Limit:        req.Limit,
+			Cursor:       req.Cursor,
+			Consistency:  proxy.NewConsistency(req.FullyConsistent, req.AtLeastAsFresh),
+		}
+		relationships, cursor, err := kubeProxy.ReadRelationships(r.Context(), query)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, api.Response{Success: false, Error: fmt.Sprintf("Failed to read relationships: %v", err)})
+			return
+		}
+
+		data := map[string]interface{}{"relationships": relationships, "cursor": cursor, "hasMore": cursor != ""}
+		if req.Count {
+			// Counted in a separate pass, so writes in between can make it differ from the pages
+			count, exact, err := kubeProxy.CountRelationships(r.Context(), query)
+			if err != nil {
+				writeJSON(w, http.StatusBadGateway, api.Response{Success: false, Error: fmt.Sprintf("Failed to count relationships: %v", err)})
+				return
+			}
+			data["count"] = count
+			data["countExact"] = exact
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: data})
+	})))
+
+	mux.HandleFunc("/api/users/permissions", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.UserPermissionsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.User == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "User is required"})
+			return
+		}
+		if req.Limit > proxy.MaxUserPermissionsLimit {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: fmt.Sprintf("Limit must not exceed %d", proxy.MaxUserPermissionsLimit)})
+			return
+		}
+		for resourceType, permissions := range req.Permissions {
+			if resourceType == "" || len(permissions) == 0 {
+				writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Every resource type in permissions needs at least one permission"})
+				return
+			}
+		}
+
+		// Another user's access reveals their grants, so only cluster admins may ask
+		if err := requireClusterAdmin(r.Context(), kubeProxy, user); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		target := sanitizeUserName(req.User)
+		permissions, truncated, err := kubeProxy.UserPermissions(r.Context(), proxy.UserPermissionsQuery{
+			SubjectType: "user",
+			SubjectID:   target,
+			Permissions: req.Permissions,
+			Limit:       req.Limit,
+			Consistency: proxy.NewConsistency(req.FullyConsistent, req.AtLeastAsFresh),
+		})
+		if err != nil {
+			writeJSON(w, statusForError(err), api.Response{Success: false, Error: fmt.Sprintf("Failed to look up permissions: %v", err)})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{"user": target, "permissions": permissions, "truncated": truncated}})
+	})))
+
+	// Offboarding: a cluster admin removes a user's relationships, reassigning what they created
+	mux.HandleFunc("/api/users/delete-data", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.DeleteUserDataRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.User == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "User is required"})
+			return
+		}
+		if !req.Confirm {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "confirm must be true to delete user data"})
+			return
+		}
+
+		if err := requireClusterAdmin(r.Context(), kubeProxy, user); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		target := sanitizeUserName(req.User)
+		var newOwner string
+		if req.ReassignTo != "" {
+			newOwner = sanitizeUserName(req.ReassignTo)
+		}
+		result, err := kubeProxy.DeleteUserData(r.Context(), target, newOwner)
+		if result != nil {
+			logger.InfoContext(r.Context(), "user data deleted", "user", user.Username, "target", target, "reassignTo", newOwner,
+				"deleted", result.Deleted, "reassigned", result.Reassigned)
+		}
+		if err != nil {
+			resp := api.Response{Success: false, Error: err.Error()}
+			if result != nil {
+				// Report what was removed before the failure
+				resp.Data = result
+			}
+			writeJSON(w, statusForError(err), resp)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{
+			"user":       target,
+			"deleted":    result.Deleted,
+			"reassigned": result.Reassigned,
+			"reassignTo": newOwner,
+		}})
+	})))
+
+	mux.HandleFunc("/api/users/merge", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.MergeUserSubjectsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.From == "" || req.Into == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Both from and into are required"})
+			return
+		}
+		if !req.Confirm {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "confirm must be true to merge users"})
+			return
+		}
+
+		if err := requireClusterAdmin(r.Context(), kubeProxy, user); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		from, into := sanitizeUserName(req.From), sanitizeUserName(req.Into)
+		if from == into {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: fmt.Sprintf("%s and %s are the same SpiceDB subject", req.From, req.Into)})
+			return
+		}
+		result, err := kubeProxy.MergeUserSubjects(r.Context(), from, into)
+		if result != nil {
+			logger.InfoContext(r.Context(), "user subjects merged", "user", user.Username, "from", from, "into", into,
+				"moved", result.Moved, "dropped", result.Dropped)
+		}
+		if err != nil {
+			resp := api.Response{Success: false, Error: err.Error()}
+			if result != nil {
+				// Report what was moved before the failure
+				resp.Data = result
+			}
+			writeJSON(w, statusForError(err), resp)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{
+			"from":    from,
+			"into":    into,
+			"moved":   result.Moved,
+			"dropped": result.Dropped,
+		}})
+	})))
+
+	mux.HandleFunc("/api/schema", withMethod(http.MethodGet, withAuth(kubeProxy, withClusterAdmin(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		schema, err := kubeProxy.GetSchema(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: schema})
+	}))))
+
+	mux.HandleFunc("/api/schema/update", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.WriteSchemaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.Schema == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Schema is required"})
+			return
+		}
+
+		if err := requireClusterAdmin(r.Context(), kubeProxy, user); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		revision, err := kubeProxy.WriteSchema(r.Context(), req.Schema)
+		if err != nil {
+			var orphanErr *proxy.SchemaOrphanError
+			if errors.As(err, &orphanErr) {
+				writeJSON(w, http.StatusConflict, api.Response{Success: false, Error: proxy.ErrSchemaOrphansRelationships.Error(), Data: map[string]interface{}{"orphanedRelationships": orphanErr.Relationships}})
+				return
+			}
+			writeError(w, err)
+			return
+		}
+
+		logger.InfoContext(r.Context(), "schema updated", "user", user.Username, "revision", revision)
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]string{"revision": revision}})
+	})))
+
+	mux.HandleFunc("/api/relationships/delete", withMethod(http.MethodPost, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var req api.DeleteRelationshipsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+
+		if req.ResourceType == "" {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "resourceType is required"})
+			return
+		}
+		if !req.Confirm {
+			writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "confirm must be true to delete relationships"})
+			return
+		}
+
+		if err := requireClusterAdmin(r.Context(), kubeProxy, user); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		deleted, err := kubeProxy.DeleteRelationships(r.Context(), req.ResourceType, req.ResourceID, req.Relation)
+		logger.InfoContext(r.Context(), "relationships deleted", "user", user.Username, "resourceType", req.ResourceType,
+			"resourceId", req.ResourceID, "relation", req.Relation, "count", deleted)
+		if err != nil {
+			writeJSON(w, statusForError(err), api.Response{Success: false, Error: err.Error(), Data: map[string]uint64{"relationships_deleted": deleted}})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: map[string]interface{}{
+			"resourceType":          req.ResourceType,
+			"resourceId":            req.ResourceID,
+			"relation":              req.Relation,
+			"relationships_deleted": deleted,
+		}})
+	})))
+
+	mux.HandleFunc("/api/relationships/export", withMethod(http.MethodGet, withAuth(kubeProxy, withClusterAdmin(kubeProxy, exportHandler(kubeProxy, logger)))))
+	mux.HandleFunc("/api/relationships/import", withMethod(http.MethodPost, withAuth(kubeProxy, withClusterAdmin(kubeProxy, importHandler(kubeProxy, logger)))))
+
+	mux.HandleFunc("/api/relationships/watch", withMethod(http.MethodGet, withAuth(kubeProxy, withClusterAdmin(kubeProxy, watchHandler(kubeProxy, logger, requests.draining())))))
+
+	// Example usage endpoint
+	demoHandler := func(w http.ResponseWriter, r *http.Request) {
+		demo := map[string]interface{}{
+			"message": "SpiceDB KubeAPI Proxy Integration Demo",
+			"endpoints": map[string]string{
+				"create_namespace": "POST /api/namespaces/create",
+				"list_namespaces":  "POST /api/namespaces/list",
+				"get_namespace":    "POST /api/namespaces/get",
+				"delete_namespace": "POST /api/namespaces/delete",
+				"update_namespace": "POST /api/namespaces/update",
+				"grant_view":       "POST /api/namespaces/grant-view",
+				"grant_view_bulk":  "POST /api/namespaces/grant-view-bulk",
+				"grant_view_group": "POST /api/namespaces/grant-view-group",
+				"grant_view_temp":  "POST /api/namespaces/grant-view-temp",
+				"revoke_view":      "POST /api/namespaces/revoke-view",
+				"list_viewers":     "POST /api/namespaces/viewers",
+				"grant_edit":       "POST /api/namespaces/grant-edit",
+				"add_group_member": "POST /api/groups/add-member",
+				"remove_member":    "POST /api/groups/remove-member",
+				"group_members":    "POST /api/groups/members",
+				"transfer_owner":   "POST /api/namespaces/transfer-ownership",
+				"create_pod":       "POST /api/pods/create",
+				"list_pods":        "POST /api/pods/list",
+				"delete_pod":       "POST /api/pods/delete",
+				"check_pod":        "POST /api/pods/check",
+				"create_configmap": "POST /api/configmaps/create",
+				"list_configmaps":  "POST /api/configmaps/list",
+				"create_deploy":    "POST /api/deployments/create",
+				"list_deploys":     "POST /api/deployments/list",
+				"resources":        "POST /api/resources/{get,list,create,update,delete}",
+				"whoami":           "GET /api/whoami",
+				"capabilities":     "GET /api/capabilities",
+				"check_permission": "POST /api/permissions/check",
+				"bulk_check":       "POST /api/permissions/bulk-check",
+				"batch":            "POST /api/batch",
+				"relationships":    "POST /api/relationships/read",
+				"delete_relations": "POST /api/relationships/delete",
+				"export_relations": "GET /api/relationships/export (newline-delimited JSON)",
+				"import_relations": "POST /api/relationships/import (newline-delimited JSON)",
+				"user_permissions": "POST /api/users/permissions",
+				"delete_user_data": "POST /api/users/delete-data",
+				"merge_users":      "POST /api/users/merge",
+				"watch":            "GET /api/relationships/watch[?types=namespace,pod] (WebSocket)",
+				"schema":           "GET /api/schema",
+				"update_schema":    "POST /api/schema/update",
+				"health":           "GET /healthz[?verbose]",
+				"ready":            "GET /readyz",
+				"metrics":          "GET /metrics",
+				"version":          "GET /version",
+			},
+			"example_requests": map[string]interface{}{
+				"create_namespace": map[string]interface{}{
+					"namespace": "alice-workspace",
+					"dryRun":    false,
+				},
+				"list_namespaces": map[string]interface{}{
+					"limit":         50,
+					"continue":      "",
+					"fast":          false,
+					"labelSelector": "team=payments",
+					"permission":    "view",
+				},
+				"get_namespace": map[string]string{
+					"namespace": "alice-workspace",
+				},
+				"delete_namespace": map[string]string{
+					"namespace": "alice-workspace",
+				},
+				"update_namespace": map[string]interface{}{
+					"namespace":   "alice-workspace",
+					"labels":      map[string]interface{}{"team": "payments", "stale": nil},
+					"annotations": map[string]string{"owner": "alice"},
+				},
+				"grant_view": map[string]interface{}{
+					"namespace":     "alice-workspace",
+					"user":          "bob",
+					"returnViewers": true,
+				},
+				"grant_view_bulk": map[string]interface{}{
+					"namespace": "alice-workspace",
+					"users":     []string{"bob", "carol"},
+				},
+				"grant_view_temp": map[string]string{
+					"namespace": "alice-workspace",
+					"user":      "bob",
+					"duration":  "24h",
+				},
+				"grant_view_group": map[string]string{
+					"namespace": "alice-workspace",
+					"group":     "developers",
+				},
+				"add_group_member": map[string]string{
+					"group": "developers",
+					"user":  "bob",
+				},
+				"remove_member": map[string]string{
+					"group": "developers",
+					"user":  "bob",
+				},
+				"group_members": map[string]interface{}{
+					"group":           "developers",
+					"fullyConsistent": true,
+				},
+				"revoke_view": map[string]string{
+					"namespace": "alice-workspace",
+					"user":      "bob",
+				},
+				"list_viewers": map[string]interface{}{
+					"namespace":       "alice-workspace",
+					"fullyConsistent": true,
+				},
+				"grant_edit": map[string]string{
+					"namespace": "alice-workspace",
+					"user":      "carol",
+				},
+				"transfer_owner": map[string]string{
+					"namespace": "alice-workspace",
+					"newOwner":  "dave",
+				},
+				"create_pod": map[string]string{
+					"namespace": "alice-workspace",
+					"name":      "nginx",
+					"image":     "nginx:latest",
+				},
+				"list_pods": map[string]string{
+					"namespace": "alice-workspace",
+				},
+				"delete_pod": map[string]string{
+					"namespace": "alice-workspace",
+					"name":      "nginx",
+				},
+				"check_pod": map[string]string{
+					"namespace":  "alice-workspace",
+					"name":       "nginx",
+					"permission": "view",
+				},
+				"create_configmap": map[string]interface{}{
+					"namespace": "alice-workspace",
+					"name":      "app-config",
+					"data":      map[string]string{"LOG_LEVEL": "info"},
+				},
+				"list_configmaps": map[string]string{
+					"namespace": "alice-workspace",
+				},
+				"create_deploy": map[string]interface{}{
+					"namespace": "alice-workspace",
+					"name":      "web",
+					"image":     "nginx:latest",
+					"replicas":  2,
+				},
+				"list_deploys": map[string]string{
+					"namespace": "alice-workspace",
+				},
+				"resources": map[string]interface{}{
+					"group":     "example.com",
+					"version":   "v1alpha1",
+					"resource":  "testresources",
+					"namespace": "alice-workspace",
+					"name":      "sample",
+					"object": map[string]interface{}{
+						"apiVersion": "example.com/v1alpha1",
+						"kind":       "TestResource",
+						"metadata":   map[string]string{"name": "sample"},
+					},
+				},
+				"check_permission": map[string]string{
+					"resourceType": "namespace",
+					"resourceId":   "alice-workspace",
+					"permission":   "edit",
+				},
+				"bulk_check": map[string]interface{}{
+					"items": []map[string]string{
+						{"resourceType": "namespace", "resourceId": "alice-workspace", "permission": "edit"},
+						{"resourceType": "namespace", "resourceId": "alice-workspace", "permission": "admin"},
+						{"resourceType": "namespace", "resourceId": "alice-workspace", "permission": "view"},
+					},
+				},
+				"relationships": map[string]interface{}{
+					"resourceType": "namespace",
+					"resourceId":   "alice-workspace",
+					"limit":        100,
+					"count":        true,
+				},
+				"delete_relations": map[string]interface{}{
+					"resourceType": "namespace",
+					"resourceId":   "alice-workspace",
+					"confirm":      true,
+				},
+				"import_relations": `{"resourceType":"namespace","resourceId":"alice-workspace","relation":"creator","subjectType":"user","subjectId":"alice"}`,
+				"delete_user_data": map[string]interface{}{
+					"user":       "bob",
+					"reassignTo": "alice",
+					"confirm":    true,
+				},
+				"merge_users": map[string]interface{}{
+					"from":    "alice@corp.example",
+					"into":    "alice",
+					"confirm": true,
+				},
+				"user_permissions": map[string]interface{}{
+					"user":        "bob",
+					"permissions": map[string][]string{"namespace": {"admin", "view"}},
+					"limit":       100,
+				},
+				"batch": map[string]interface{}{
+					"operations": []map[string]interface{}{
+						{"op": "create-namespace", "params": map[string]string{"namespace": "alice-staging"}},
+						{"op": "grant-view", "params": map[string]string{"namespace": "alice-staging", "user": "bob"}},
+					},
+				},
+			},
+		}
+
+		writeJSON(w, http.StatusOK, api.Response{Success: true, Data: demo})
+	}
+	switch cfg.DemoAccess {
+	case DemoAccessDisabled:
+		// Left unregistered, so the endpoint list is not served at all
+	case DemoAccessAuthenticated:
+		mux.HandleFunc("/api/demo", withMethod(http.MethodGet, withAuth(kubeProxy, func(w http.ResponseWriter, r *http.Request, _ *auth.UserInfo) {
+			demoHandler(w, r)
+		})))
+	default:
+		mux.HandleFunc("/api/demo", withMethod(http.MethodGet, demoHandler))
+	}
+
+	maxBodyBytes := cfg.MaxRequestBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultMaxRequestBodyBytes
+	}
+
+	httpTimeouts := cfg.HTTPTimeouts
+	if httpTimeouts == (HTTPTimeouts{}) {
+		httpTimeouts = DefaultHTTPTimeouts()
+	}
+
+	server := &http.Server{
+		Addr:      listenAddr,
+		TLSConfig: tlsConfig,
+		Protocols: serverProtocols(tlsConfig != nil),
+		Handler: chain(withMetrics(mux),
+			func(next http.Handler) http.Handler { return withRequestTracking(requests, next) },
+			tracing.Middleware,
+			requestid.Middleware,
+			func(next http.Handler) http.Handler { return withRequestLogging(logger, mux, next) },
+			func(next http.Handler) http.Handler { return withCORS(cfg.CORS, next) },
+			func(next http.Handler) http.Handler { return withRateLimit(kubeProxy, cfg.RateLimit, next) },
+			func(next http.Handler) http.Handler {
+				return withMaxBodySize(maxBodyBytes, map[string]int64{"/api/relationships/import": maxImportBodyBytes}, next)
+			},
+			func(next http.Handler) http.Handler {
+				return withJSONContentType(map[string][]string{"/api/relationships/import": {"application/x-ndjson"}}, next)
+			},
+		),
+	}
+	httpTimeouts.apply(server)
+	server.RegisterOnShutdown(requests.drain)
+
+	return &Server{
+		proxy:     kubeProxy,
+		server:    server,
+		logger:    logger,
+		stopProxy: stopProxy,
+		requests:  requests,
+	}, nil
+}
+
+// waitForProxyReady pings SpiceDB with exponential backoff until it serves requests or the timeout elapses
+func waitForProxyReady(p *proxy.SpiceDBKubeProxy, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	backoff := readinessInitialBackoff
+	for attempt := 1; ; attempt++ {
+		checkCtx, checkCancel := context.WithTimeout(ctx, readinessCheckTimeout)
+		err := p.CheckReady(checkCtx)
+		checkCancel()
+		if err == nil {
+			return nil
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("proxy did not become ready within %s after %d attempts: %w", timeout, attempt, err)
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > readinessMaxBackoff {
+			backoff = readinessMaxBackoff
+		}
+	}
+}
+
+// statusForError maps an error returned by the proxy to the HTTP status reported to the client
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, proxy.ErrPermissionDenied), apierrors.IsForbidden(err), apierrors.IsUnauthorized(err):
+		return http.StatusForbidden
+	case apierrors.IsNotFound(err):
+		return http.StatusNotFound
+	case errors.Is(err, proxy.ErrOwnershipConflict), errors.Is(err, proxy.ErrNamespaceExists), errors.Is(err, proxy.ErrUserOwnsResources), errors.Is(err, proxy.ErrSchemaOrphansRelationships), apierrors.IsAlreadyExists(err), apierrors.IsConflict(err):
+		return http.StatusConflict
+	case errors.Is(err, proxy.ErrResourceNotAllowed), errors.Is(err, proxy.ErrInvalidSchema), errors.Is(err, proxy.ErrUnknownPermission), errors.Is(err, proxy.ErrTooManyNamespaces), errors.Is(err, proxy.ErrInvalidImport), apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return http.StatusBadRequest
+	case errors.Is(err, proxy.ErrSchemaNotInitialized):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, proxy.ErrAuthorizationTimeout), errors.Is(err, context.DeadlineExceeded), status.Code(err) == codes.DeadlineExceeded, apierrors.IsTimeout(err):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// codeForError returns the Response.Code for err replied with status, singling out
+// conflicts clients can act on from the code alone
+func codeForError(err error, status int) string {
+	if errors.Is(err, proxy.ErrNamespaceExists) {
+		return api.CodeAlreadyExists
+	}
+	if errors.Is(err, proxy.ErrAuthorizationTimeout) {
+		return api.CodeAuthorizationTimeout
+	}
+	return api.CodeForStatus(status)
+}
+
+// userIdentity describes how the proxy sees user, including the SpiceDB subject their name maps to
+func userIdentity(user *auth.UserInfo) map[string]interface{} {
+	subjectID := sanitizeUserName(user.Username)
+	return map[string]interface{}{
+		"username":  user.Username,
+		"groups":    user.Groups,
+		"uid":       user.UID,
+		"subjectId": subjectID,
+		"subject":   "user:" + subjectID,
+	}
+}
+
+// dryRunDecision reports the decision for a failed dry-run request, or nil for a real one
+func dryRunDecision(dryRun, allowed bool) interface{} {
+	if !dryRun {
+		return nil
+	}
+	return map[string]bool{"dryRun": true, "allowed": allowed}
+}
+
+// writeDecodeError reports a request body that could not be decoded, with 413 when it
+// exceeded the body size limit
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSON(w, http.StatusRequestEntityTooLarge, api.Response{Success: false, Error: fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit)})
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, api.Response{Success: false, Error: "Invalid JSON"})
+}
+
+// writeError reports err with the status and code statusForError and codeForError give it
+func writeError(w http.ResponseWriter, err error) {
+	status := statusForError(err)
+	writeJSON(w, status, api.Response{Success: false, Error: err.Error(), Code: codeForError(err, status)})
+}
+
+// writeJSON writes v with the given status. Failed api.Responses without an explicit
+// Code get the code for the status, so every handler reports codes consistently.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	if resp, ok := v.(api.Response); ok && !resp.Success && resp.Code == "" {
+		resp.Code = api.CodeForStatus(status)
+		v = resp
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to encode JSON response", "error", err)
+	}
+}
+
+// Start starts the HTTP server
+func (s *Server) Start() error {
+	if s.server.TLSConfig != nil {
+		s.logger.Info("starting server", "addr", s.server.Addr, "tls", true)
+		// The certificate is already loaded into TLSConfig
+		return s.server.ListenAndServeTLS("", "")
+	}
+	s.logger.Info("starting server", "addr", s.server.Addr, "tls", false)
+	return s.server.ListenAndServe()
+}
+
+// Stop gracefully stops the server, then the embedded proxy. It stops accepting requests,
+// asks watches to close, and waits until ctx ends for in-flight requests to finish, so
+// their SpiceDB writes and Kubernetes calls are not cut off by the proxy stopping.
+func (s *Server) Stop(ctx context.Context) error {
+	err := s.server.Shutdown(ctx)
+	defer s.stopProxy()
+
+	// Shutdown does not wait for hijacked WebSocket connections
+	if waitErr := s.requests.wait(ctx); waitErr != nil {
+		s.logger.Warn("in-flight requests did not finish before shutdown", "error", waitErr)
+		if err == nil {
+			err = waitErr
+		}
+	}
+
+	// In-flight requests have finished, so the proxy only drains its own workflows
+	if proxyErr := s.proxy.Stop(ctx); proxyErr != nil {
+		s.logger.Warn("embedded proxy did not stop cleanly", "error", proxyErr)
+		if err == nil {
+			err = proxyErr
+		}
+	}
+	return err
 }
 
 // GetProxy returns the SpiceDB proxy
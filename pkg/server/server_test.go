@@ -0,0 +1,340 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	authzv1 "k8s.io/api/authorization/v1"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/api"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/internal/fakekube"
+)
+
+// testAdmin is the only user Kubernetes RBAC grants every verb on every resource
+const testAdmin = "test-admin"
+
+// The embedded proxy configures process-wide logging when it is created, so every test in
+// the package shares one server backed by one fake Kubernetes API. Tests use namespaces of
+// their own to stay independent.
+var (
+	testKube   *fakekube.Server
+	testServer *Server
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	testKube = fakekube.New()
+	defer testKube.Close()
+	testKube.SetSubjectAccessReview(allowAllButClusterAdmin)
+
+	dir, err := os.MkdirTemp("", "server-test")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create temp dir: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	kubeConfigPath := filepath.Join(dir, "kubeconfig")
+	kubeConfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: fake
+  cluster:
+    server: %s
+    insecure-skip-tls-verify: true
+users:
+- name: fake
+  user:
+    token: fake
+contexts:
+- name: fake
+  context:
+    cluster: fake
+    user: fake
+current-context: fake
+`, testKube.URL)
+	if err := os.WriteFile(kubeConfigPath, []byte(kubeConfig), 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write kubeconfig: %v\n", err)
+		return 1
+	}
+
+	testServer, err = NewServer(Config{
+		ListenAddr:       "127.0.0.1:0",
+		KubeConfigSource: KubeConfigSourceFile,
+		KubeConfigPath:   kubeConfigPath,
+		WorkflowDBPath:   InMemoryWorkflowDBPath,
+		Logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		ClusterAdmins:    []string{testAdmin},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create server: %v\n", err)
+		return 1
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = testServer.Stop(ctx)
+	}()
+
+	return m.Run()
+}
+
+// allowAllButClusterAdmin allows every SubjectAccessReview except cluster-wide wildcards,
+// which only testAdmin passes
+func allowAllButClusterAdmin(review *authzv1.SubjectAccessReview) bool {
+	if review.Spec.User == testAdmin {
+		return true
+	}
+	attrs := review.Spec.ResourceAttributes
+	return attrs == nil || (attrs.Resource != "*" && attrs.Verb != "*")
+}
+
+// withSubjectAccessReview decides Kubernetes RBAC with fn for the rest of the test
+func withSubjectAccessReview(t testing.TB, fn func(review *authzv1.SubjectAccessReview) bool) {
+	t.Helper()
+	testKube.SetSubjectAccessReview(fn)
+	t.Cleanup(func() { testKube.SetSubjectAccessReview(allowAllButClusterAdmin) })
+}
+
+// call sends a JSON request as user through the server's handler chain and decodes the response
+func call(t testing.TB, method, path, user string, body interface{}) (int, api.Response) {
+	t.Helper()
+	return callWithGroups(t, method, path, user, nil, body)
+}
+
+// callWithGroups is call for a user in groups
+func callWithGroups(t testing.TB, method, path, user string, groups []string, body interface{}) (int, api.Response) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshal request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if user != "" {
+		req.Header.Set("X-Remote-User", user)
+	}
+	if len(groups) > 0 {
+		req.Header.Set("X-Remote-Groups", strings.Join(groups, ", "))
+	}
+
+	rec := httptest.NewRecorder()
+	testServer.server.Handler.ServeHTTP(rec, req)
+
+	var resp api.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s %s: decode response %q: %v", method, path, rec.Body.String(), err)
+	}
+	return rec.Code, resp
+}
+
+// dataMap returns the response data as a JSON object
+func dataMap(t testing.TB, resp api.Response) map[string]interface{} {
+	t.Helper()
+	data, ok := resp.Data.(map[string]interface{})
+	if !ok {
+		t.Fatalf("response data %#v is not an object", resp.Data)
+	}
+	return data
+}
+
+// createNamespace creates namespace through the API as creator
+func createNamespace(t testing.TB, creator, namespace string) {
+	t.Helper()
+	if status, resp := call(t, http.MethodPost, "/api/namespaces/create", creator, api.CreateNamespaceRequest{Namespace: namespace}); status != http.StatusOK {
+		t.Fatalf("create namespace %s as %s: %d %s", namespace, creator, status, resp.Error)
+	}
+}
+
+func TestCheckPermissionOfOtherSubject(t *testing.T) {
+	createNamespace(t, "check-alice", "check-ns")
+
+	own := api.CheckPermissionRequest{ResourceType: "namespace", ResourceID: "check-ns", Permission: "view", FullyConsistent: true}
+	status, resp := call(t, http.MethodPost, "/api/permissions/check", "check-alice", own)
+	if status != http.StatusOK || dataMap(t, resp)["allowed"] != true {
+		t.Fatalf("own check = %d %+v, want allowed", status, resp)
+	}
+
+	// Naming yourself explicitly is still your own check
+	self := own
+	self.SubjectType, self.SubjectID = "user", "check-alice"
+	if status, resp := call(t, http.MethodPost, "/api/permissions/check", "check-alice", self); status != http.StatusOK {
+		t.Errorf("explicit self check = %d %s, want 200", status, resp.Error)
+	}
+
+	other := own
+	other.SubjectID = "check-alice"
+	if status, resp := call(t, http.MethodPost, "/api/permissions/check", "check-mallory", other); status != http.StatusForbidden {
+		t.Errorf("check of another user = %d %+v, want 403", status, resp)
+	}
+	other.SubjectType, other.SubjectID = "group", "check-team"
+	if status, _ := call(t, http.MethodPost, "/api/permissions/check", "check-mallory", other); status != http.StatusForbidden {
+		t.Errorf("check of a group = %d, want 403", status)
+	}
+
+	other.SubjectType, other.SubjectID = "user", "check-alice"
+	status, resp = call(t, http.MethodPost, "/api/permissions/check", testAdmin, other)
+	if status != http.StatusOK || dataMap(t, resp)["allowed"] != true {
+		t.Errorf("admin check of alice = %d %+v, want allowed", status, resp)
+	}
+}
+
+func TestBulkCheckOfOtherSubject(t *testing.T) {
+	createNamespace(t, "bulk-alice", "bulk-ns")
+
+	req := api.BulkCheckRequest{
+		Items:           []api.BulkCheckItem{{ResourceType: "namespace", ResourceID: "bulk-ns", Permission: "view"}},
+		FullyConsistent: true,
+	}
+	allowed := func(resp api.Response) interface{} {
+		results, ok := dataMap(t, resp)["results"].([]interface{})
+		if !ok || len(results) != 1 {
+			t.Fatalf("results = %#v, want one", dataMap(t, resp)["results"])
+		}
+		return results[0].(map[string]interface{})["allowed"]
+	}
+
+	if status, resp := call(t, http.MethodPost, "/api/permissions/bulk-check", "bulk-alice", req); status != http.StatusOK || allowed(resp) != true {
+		t.Fatalf("own bulk check = %d %+v, want allowed", status, resp)
+	}
+
+	other := req
+	other.SubjectType, other.SubjectID = "user", "bulk-alice"
+	if status, resp := call(t, http.MethodPost, "/api/permissions/bulk-check", "bulk-mallory", other); status != http.StatusForbidden {
+		t.Errorf("bulk check of another user = %d %+v, want 403", status, resp)
+	}
+	other.SubjectType, other.SubjectID = "group", "bulk-team"
+	if status, _ := call(t, http.MethodPost, "/api/permissions/bulk-check", "bulk-mallory", other); status != http.StatusForbidden {
+		t.Errorf("bulk check of a group = %d, want 403", status)
+	}
+
+	other.SubjectType, other.SubjectID = "user", "bulk-alice"
+	if status, resp := call(t, http.MethodPost, "/api/permissions/bulk-check", testAdmin, other); status != http.StatusOK || allowed(resp) != true {
+		t.Errorf("admin bulk check of alice = %d %+v, want allowed", status, resp)
+	}
+}
+
+func TestGroupsReachKubernetesRBAC(t *testing.T) {
+	// Only members of platform-admins may create namespaces
+	withSubjectAccessReview(t, func(review *authzv1.SubjectAccessReview) bool {
+		attrs := review.Spec.ResourceAttributes
+		if attrs != nil && attrs.Resource == "namespaces" && attrs.Verb == "create" {
+			return slices.Contains(review.Spec.Groups, "platform-admins")
+		}
+		return allowAllButClusterAdmin(review)
+	})
+
+	req := api.CreateNamespaceRequest{Namespace: "groups-ns"}
+	if status, resp := callWithGroups(t, http.MethodPost, "/api/namespaces/create", "groups-carol", []string{"developers"}, req); status != http.StatusForbidden {
+		t.Fatalf("create as a developer = %d %+v, want 403", status, resp)
+	}
+	if testKube.Has("namespaces", "", "groups-ns") {
+		t.Fatal("namespace created without the admin group")
+	}
+
+	if status, resp := callWithGroups(t, http.MethodPost, "/api/namespaces/create", "groups-carol", []string{"developers", "platform-admins"}, req); status != http.StatusOK {
+		t.Fatalf("create as a platform admin = %d %s, want 200", status, resp.Error)
+	}
+	if !testKube.Has("namespaces", "", "groups-ns") {
+		t.Error("namespace not created for a platform admin")
+	}
+}
+
+// createPod creates a pod through the API as username
+func createPod(t testing.TB, username, namespace, name string) {
+	t.Helper()
+	req := api.CreatePodRequest{Namespace: namespace, Name: name, Image: "nginx"}
+	if status, resp := call(t, http.MethodPost, "/api/pods/create", username, req); status != http.StatusOK {
+		t.Fatalf("create pod %s/%s as %s: %d %s", namespace, name, username, status, resp.Error)
+	}
+}
+
+func TestDeletePod(t *testing.T) {
+	createNamespace(t, "podapi-alice", "podapi")
+	createPod(t, "podapi-alice", "podapi", "web")
+	if _, err := testServer.proxy.GrantViewPermission(context.Background(), "podapi", "podapi-viewer", time.Time{}); err != nil {
+		t.Fatalf("grant view: %v", err)
+	}
+
+	req := api.DeletePodRequest{Namespace: "podapi", Name: "web"}
+	if status, resp := call(t, http.MethodPost, "/api/pods/delete", "podapi-viewer", req); status != http.StatusForbidden || resp.Code != api.CodePermissionDenied {
+		t.Errorf("delete as viewer = %d %q, want 403 %q", status, resp.Code, api.CodePermissionDenied)
+	}
+	if !testKube.Has("pods", "podapi", "web") {
+		t.Fatal("pod was deleted by a viewer")
+	}
+
+	missing := api.DeletePodRequest{Namespace: "podapi", Name: "ghost"}
+	if status, resp := call(t, http.MethodPost, "/api/pods/delete", "podapi-alice", missing); status != http.StatusNotFound {
+		t.Errorf("delete of a missing pod = %d %+v, want 404", status, resp)
+	}
+
+	if status, resp := call(t, http.MethodPost, "/api/pods/delete", "podapi-alice", req); status != http.StatusOK {
+		t.Fatalf("delete as creator = %d %s, want 200", status, resp.Error)
+	}
+	if testKube.Has("pods", "podapi", "web") {
+		t.Error("pod still exists after its creator deleted it")
+	}
+}
+
+// contains reports whether the JSON array value holds s
+func contains(value interface{}, s string) bool {
+	items, _ := value.([]interface{})
+	for _, item := range items {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReadsAtLeastAsFreshAsGrant(t *testing.T) {
+	createNamespace(t, "fresh-alice", "fresh-ns")
+
+	status, resp := call(t, http.MethodPost, "/api/namespaces/grant-view", "fresh-alice", api.GrantViewPermissionRequest{Namespace: "fresh-ns", User: "fresh-bob"})
+	if status != http.StatusOK {
+		t.Fatalf("grant view = %d %s, want 200", status, resp.Error)
+	}
+	token, _ := dataMap(t, resp)["written_at"].(string)
+	if token == "" {
+		t.Fatalf("grant view returned no written_at: %+v", resp)
+	}
+
+	// Every read given the token sees the grant straight away
+	status, resp = call(t, http.MethodPost, "/api/namespaces/list", "fresh-bob", api.ListNamespacesRequest{Fast: true, AtLeastAsFresh: token})
+	if status != http.StatusOK || !contains(dataMap(t, resp)["namespaces"], "fresh-ns") {
+		t.Errorf("fast list as bob at the grant = %d %+v, want fresh-ns", status, resp)
+	}
+
+	status, resp = call(t, http.MethodPost, "/api/namespaces/viewers", "fresh-alice", api.ListNamespaceViewersRequest{Namespace: "fresh-ns", AtLeastAsFresh: token})
+	if status != http.StatusOK || !contains(dataMap(t, resp)["viewers"], "fresh-bob") {
+		t.Errorf("viewers at the grant = %d %+v, want fresh-bob", status, resp)
+	}
+
+	check := api.CheckPermissionRequest{ResourceType: "namespace", ResourceID: "fresh-ns", Permission: "view", AtLeastAsFresh: token}
+	status, resp = call(t, http.MethodPost, "/api/permissions/check", "fresh-bob", check)
+	if status != http.StatusOK || dataMap(t, resp)["allowed"] != true {
+		t.Errorf("check as bob at the grant = %d %+v, want allowed", status, resp)
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"time"
+)
+
+// HTTPTimeouts bounds how long the HTTP server waits on a client, so slow or idle clients
+// cannot hold connections open indefinitely. Zero leaves that phase unbounded.
+type HTTPTimeouts struct {
+	// ReadHeader bounds reading a request's headers, cutting off clients that trickle them in
+	ReadHeader time.Duration
+	// Read bounds reading a whole request, body included
+	Read time.Duration
+	// Write bounds writing a response from the end of its request headers. Watches and
+	// exports stream for as long as the client reads, so it is unbounded by default.
+	Write time.Duration
+	// Idle bounds how long a keep-alive connection waits for its next request
+	Idle time.Duration
+}
+
+// DefaultHTTPTimeouts returns the HTTP server timeouts used when none are configured
+func DefaultHTTPTimeouts() HTTPTimeouts {
+	return HTTPTimeouts{
+		ReadHeader: 10 * time.Second,
+		Read:       5 * time.Minute,
+		Idle:       2 * time.Minute,
+	}
+}
+
+// apply sets the timeouts on server
+func (t HTTPTimeouts) apply(server *http.Server) {
+	server.ReadHeaderTimeout = t.ReadHeader
+	server.ReadTimeout = t.Read
+	server.WriteTimeout = t.Write
+	server.IdleTimeout = t.Idle
+}
+
+// serverProtocols is HTTP/1.1, plus HTTP/2 when the server uses TLS. HTTP/2 is negotiated
+// through ALPN, so plaintext clients keep using HTTP/1.1.
+func serverProtocols(tls bool) *http.Protocols {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(tls)
+	return protocols
+}
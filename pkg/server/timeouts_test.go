@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadHeaderTimeoutCutsOffSlowClients(t *testing.T) {
+	if got, want := testServer.server.ReadHeaderTimeout, DefaultHTTPTimeouts().ReadHeader; got != want {
+		t.Errorf("server ReadHeaderTimeout = %v, want the default %v", got, want)
+	}
+
+	const readHeader = 200 * time.Millisecond
+	server := &http.Server{Handler: testServer.server.Handler}
+	HTTPTimeouts{ReadHeader: readHeader}.apply(server)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(ln)
+	defer server.Close()
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		// Fail rather than hang if the server never gives up on the client
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+		return conn
+	}
+
+	// A client that sends its headers in time is served
+	conn := dial()
+	if _, err := io.WriteString(conn, "GET /healthz HTTP/1.1\r\nHost: test\r\nConnection: close\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("read response to a complete request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("complete request = %d, want 200", resp.StatusCode)
+	}
+
+	// A client that starts a request but never ends its headers is disconnected
+	conn = dial()
+	start := time.Now()
+	if _, err := io.WriteString(conn, "GET /healthz HTTP/1.1\r\nHost: test\r\nX-Slow: "); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := io.ReadAll(conn)
+	elapsed := time.Since(start)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		t.Fatalf("server still holds the connection after %v", elapsed)
+	}
+	if elapsed < readHeader {
+		t.Errorf("connection closed after %v, before the %v header timeout", elapsed, readHeader)
+	}
+	// The server closes the connection, at most answering 408 first
+	if len(reply) > 0 && !strings.HasPrefix(string(reply), "HTTP/1.1 408") {
+		t.Errorf("slow client got %q, want the connection closed", reply)
+	}
+}
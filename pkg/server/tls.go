@@ -0,0 +1,71 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// Client certificate verification modes for TLSConfig.ClientAuth
+const (
+	// ClientAuthOptional verifies client certificates when presented but does not require them
+	ClientAuthOptional = "optional"
+	// ClientAuthRequire rejects connections without a client certificate signed by the client CA
+	ClientAuthRequire = "require"
+)
+
+// TLSConfig enables HTTPS. Setting ClientCAFile also enables client certificate authentication.
+type TLSConfig struct {
+	// CertFile and KeyFile hold the PEM encoded server certificate and private key
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is an optional PEM bundle of CAs trusted to sign client certificates
+	ClientCAFile string
+	// ClientAuth is ClientAuthOptional (default) or ClientAuthRequire; only used with ClientCAFile
+	ClientAuth string
+}
+
+// buildTLSConfig loads the server certificate and client CA bundle described by cfg
+func buildTLSConfig(cfg *TLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, fmt.Errorf("both a TLS certificate and key file are required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.ClientCAFile == "" {
+		if cfg.ClientAuth == ClientAuthRequire {
+			return nil, fmt.Errorf("requiring client certificates needs a client CA file")
+		}
+		return tlsConfig, nil
+	}
+
+	caPEM, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file %s: %w", cfg.ClientCAFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", cfg.ClientCAFile)
+	}
+	tlsConfig.ClientCAs = pool
+
+	switch cfg.ClientAuth {
+	case "", ClientAuthOptional:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("invalid client auth mode %q: must be %q or %q", cfg.ClientAuth, ClientAuthOptional, ClientAuthRequire)
+	}
+	return tlsConfig, nil
+}
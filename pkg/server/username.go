@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	serviceAccountPrefix = "system:serviceaccount:"
+	// serviceAccountIDPrefix marks SpiceDB IDs derived from service accounts. Kubernetes namespace
+	// and service account names cannot contain '_', so sa_<namespace>_<name> is unambiguous.
+	serviceAccountIDPrefix = "sa_"
+	// escapeChar starts a two digit hex escape for bytes that are not valid in SpiceDB object IDs
+	escapeChar = '='
+)
+
+// sanitizeUserName converts user names to be valid SpiceDB object IDs
+// For service accounts, keep the namespace so identities stay distinct (e.g., sa_spicedb-proxy_testuser
+// from system:serviceaccount:spicedb-proxy:testuser)
+// For other users, hex-escape invalid characters (e.g., alice@example.com becomes alice=40example=2Ecom)
+// The mapping is reversible with userNameFromSubjectID
+func sanitizeUserName(userName string) string {
+	// Check if this is a service account name
+	if strings.HasPrefix(userName, serviceAccountPrefix) {
+		// Split system:serviceaccount:namespace:name
+		parts := strings.Split(strings.TrimPrefix(userName, serviceAccountPrefix), ":")
+		if len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+			return serviceAccountIDPrefix + escapeSubjectID(parts[0]) + "_" + escapeSubjectID(parts[1])
+		}
+	}
+
+	id := escapeSubjectID(userName)
+	// Keep human users named like sa_* from colliding with service account IDs
+	if strings.HasPrefix(id, serviceAccountIDPrefix) {
+		id = "sa=5F" + strings.TrimPrefix(id, serviceAccountIDPrefix)
+	}
+	return id
+}
+
+// userNameFromSubjectID reverses sanitizeUserName, returning the Kubernetes user name for a SpiceDB user ID
+func userNameFromSubjectID(id string) string {
+	if rest, ok := strings.CutPrefix(id, serviceAccountIDPrefix); ok {
+		if namespace, name, ok := strings.Cut(rest, "_"); ok {
+			return serviceAccountPrefix + unescapeSubjectID(namespace) + ":" + unescapeSubjectID(name)
+		}
+	}
+	return unescapeSubjectID(id)
+}
+
+// escapeSubjectID hex-escapes every byte outside SpiceDB's object ID alphabet [a-zA-Z0-9/_|\-+],
+// including the escape character itself
+func escapeSubjectID(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isSubjectIDByte(c) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%c%02X", escapeChar, c)
+	}
+	return b.String()
+}
+
+// unescapeSubjectID decodes the escapes written by escapeSubjectID, leaving malformed escapes as is
+func unescapeSubjectID(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == escapeChar && i+2 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isSubjectIDByte(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	case c == '/', c == '_', c == '|', c == '-', c == '+':
+		return true
+	default:
+		return false
+	}
+}
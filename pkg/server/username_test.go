@@ -0,0 +1,71 @@
+package server
+
+import (
+	"regexp"
+	"testing"
+)
+
+// spiceDBObjectID is SpiceDB's object ID syntax
+var spiceDBObjectID = regexp.MustCompile(`^(([a-zA-Z0-9/_|\-=+]{1,})|\*)$`)
+
+func TestSanitizeUserName(t *testing.T) {
+	tests := []struct {
+		name string
+		user string
+		want string
+	}{
+		{"service account", "system:serviceaccount:spicedb-proxy:testuser", "sa_spicedb-proxy_testuser"},
+		{"service account in another namespace", "system:serviceaccount:other:testuser", "sa_other_testuser"},
+		{"email", "alice@example.com", "alice=40example=2Ecom"},
+		{"already valid", "alice", "alice"},
+		{"already valid with punctuation", "team/alice_b-c+d|e", "team/alice_b-c+d|e"},
+		{"escape character", "a=b", "a=3Db"},
+		{"user named like a service account ID", "sa_ns_name", "sa=5Fns_name"},
+		{"malformed service account", "system:serviceaccount:only-namespace", "system=3Aserviceaccount=3Aonly-namespace"},
+		{"spaces and unicode", "Zoë Doe", "Zo=C3=AB=20Doe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizeUserName(tt.user)
+			if got != tt.want {
+				t.Errorf("sanitizeUserName(%q) = %q, want %q", tt.user, got, tt.want)
+			}
+			if !spiceDBObjectID.MatchString(got) {
+				t.Errorf("sanitizeUserName(%q) = %q is not a valid SpiceDB object ID", tt.user, got)
+			}
+			if back := userNameFromSubjectID(got); back != tt.user {
+				t.Errorf("userNameFromSubjectID(%q) = %q, want %q", got, back, tt.user)
+			}
+		})
+	}
+}
+
+func TestSanitizeUserNameIsInjective(t *testing.T) {
+	// Pairs that a lossy mapping, such as dropping the namespace or the escape itself, would merge
+	users := []string{
+		"system:serviceaccount:a:b",
+		"system:serviceaccount:c:b",
+		"sa_a_b",
+		"sa=5Fa_b",
+		"a@b",
+		"a=40b",
+		"a.b",
+		"a=2Eb",
+	}
+	seen := make(map[string]string, len(users))
+	for _, user := range users {
+		id := sanitizeUserName(user)
+		if other, ok := seen[id]; ok {
+			t.Errorf("%q and %q both map to %q", other, user, id)
+		}
+		seen[id] = user
+	}
+}
+
+func TestUserNameFromSubjectIDLeavesMalformedEscapes(t *testing.T) {
+	for _, id := range []string{"a=", "a=4", "a=ZZb"} {
+		if got := userNameFromSubjectID(id); got != id {
+			t.Errorf("userNameFromSubjectID(%q) = %q, want it unchanged", id, got)
+		}
+	}
+}
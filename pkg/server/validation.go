@@ -0,0 +1,36 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// validateNamespaceName checks that name can be created as a Kubernetes namespace and used as
+// the SpiceDB object ID the create rule writes for it, so callers get a clear error up front
+// rather than a Kubernetes validation failure or a rejected relationship write
+func validateNamespaceName(name string) error {
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return fmt.Errorf("invalid namespace name %q: %s", name, strings.Join(errs, "; "))
+	}
+	for i := 0; i < len(name); i++ {
+		if !isSubjectIDByte(name[i]) {
+			return fmt.Errorf("invalid namespace name %q: %q is not allowed in a SpiceDB object ID", name, name[i])
+		}
+	}
+	return nil
+}
+
+// validateGroupName checks that name can be used as a SpiceDB group object ID
+func validateGroupName(name string) error {
+	if name == "" {
+		return fmt.Errorf("group is required")
+	}
+	for i := 0; i < len(name); i++ {
+		if !isSubjectIDByte(name[i]) {
+			return fmt.Errorf("invalid group name %q: %q is not allowed in a SpiceDB object ID", name, name[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/api"
+)
+
+func TestValidateNamespaceName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"team-a", true},
+		{"a", true},
+		{"0team", true},
+		{strings.Repeat("a", 63), true},
+		{strings.Repeat("a", 64), false},
+		{"", false},
+		{"Team-A", false},
+		{"TEAM", false},
+		{"team_a", false},
+		{"team.a", false},
+		{"team/a", false},
+		{"team a", false},
+		{"team#view", false},
+		{"tëam", false},
+		{"-team", false},
+		{"team-", false},
+	}
+	for _, tt := range tests {
+		err := validateNamespaceName(tt.name)
+		if tt.valid && err != nil {
+			t.Errorf("validateNamespaceName(%q) = %v, want nil", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("validateNamespaceName(%q) = nil, want an error", tt.name)
+		}
+	}
+}
+
+func TestCreateNamespaceRejectsInvalidName(t *testing.T) {
+	for _, name := range []string{"Invalid-NS", strings.Repeat("n", 64), "ns_with_underscores"} {
+		status, resp := call(t, http.MethodPost, "/api/namespaces/create", "valid-alice", api.CreateNamespaceRequest{Namespace: name})
+		if status != http.StatusBadRequest || !strings.Contains(resp.Error, "invalid namespace name") {
+			t.Errorf("create %q = %d %q, want 400 invalid namespace name", name, status, resp.Error)
+		}
+		if testKube.Has("namespaces", "", name) {
+			t.Errorf("namespace %q was created", name)
+		}
+	}
+}
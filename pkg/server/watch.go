@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/auth"
+	"github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/proxy"
+)
+
+const (
+	// watchBufferSize is the number of updates queued for a client before it is considered too slow
+	watchBufferSize = 256
+	watchWriteWait  = 10 * time.Second
+	watchPingPeriod = 30 * time.Second
+	// watchPongWait must exceed watchPingPeriod so a healthy client always answers in time
+	watchPongWait = 60 * time.Second
+)
+
+// errWatchClientTooSlow stops a watch whose client is not reading updates fast enough
+var errWatchClientTooSlow = errors.New("client is not keeping up with relationship updates")
+
+var watchUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// watchHandler streams SpiceDB relationship changes to cluster admins over a WebSocket.
+// Updates are queued per client, and a client that falls watchBufferSize updates behind is
+// disconnected so it never blocks the upstream watch. The change stream reveals every grant,
+// so it must be registered behind withClusterAdmin. The watch is closed with a going-away
+// status once draining is done.
+func watchHandler(kubeProxy *proxy.SpiceDBKubeProxy, logger *slog.Logger, draining <-chan struct{}) authedHandler {
+	return func(w http.ResponseWriter, r *http.Request, user *auth.UserInfo) {
+		var objectTypes []string
+		if types := r.URL.Query().Get("types"); types != "" {
+			objectTypes = strings.Split(types, ",")
+		}
+
+		conn, err := watchUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade has already replied with an HTTP error
+			logger.DebugContext(r.Context(), "websocket upgrade failed", "error", err)
+			return
+		}
+		defer conn.Close()
+
+		// The hijacked connection no longer cancels the request context, so the
+		// reader below cancels the watch when the client goes away
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		go readWatchClient(conn, cancel)
+		go func() {
+			select {
+			case <-draining:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		updates := make(chan proxy.RelationshipUpdate, watchBufferSize)
+		writerDone := make(chan struct{})
+		go func() {
+			defer close(writerDone)
+			writeWatchUpdates(conn, updates, cancel)
+		}()
+
+		err = kubeProxy.WatchRelationships(ctx, objectTypes, func(update proxy.RelationshipUpdate) error {
+			select {
+			case updates <- update:
+				return nil
+			default:
+				return errWatchClientTooSlow
+			}
+		})
+		close(updates)
+		<-writerDone
+
+		code, reason := websocket.CloseNormalClosure, "watch ended"
+		select {
+		case <-draining:
+			code, reason = websocket.CloseGoingAway, "server shutting down"
+		default:
+		}
+		switch {
+		case errors.Is(err, errWatchClientTooSlow):
+			code, reason = websocket.CloseTryAgainLater, err.Error()
+		case err != nil:
+			code, reason = websocket.CloseInternalServerErr, "watch failed"
+			logger.ErrorContext(r.Context(), "relationship watch failed", "user", user.Username, "error", err)
+		}
+		// The client may already be gone, in which case there is nobody to tell
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(watchWriteWait))
+	}
+}
+
+// readWatchClient discards client messages, answering pings and tracking pongs, and
+// calls cancel once the client closes the connection or stops responding
+func readWatchClient(conn *websocket.Conn, cancel context.CancelFunc) {
+	defer cancel()
+
+	_ = conn.SetReadDeadline(time.Now().Add(watchPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(watchPongWait))
+	})
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// writeWatchUpdates sends queued updates as JSON text frames with periodic pings until
+// updates is closed, calling cancel if a write fails
+func writeWatchUpdates(conn *websocket.Conn, updates <-chan proxy.RelationshipUpdate, cancel context.CancelFunc) {
+	ticker := time.NewTicker(watchPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(watchWriteWait))
+			if err := conn.WriteJSON(update); err != nil {
+				cancel()
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(watchWriteWait)); err != nil {
+				cancel()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, set at build time with
+//
+//	-ldflags "-X github.com/clyang82/spicedb-kubeapi-proxy-integration/pkg/version.Version=..."
+var (
+	Version   = "dev"
+	GitCommit = "unknown"
+	BuildDate = "unknown"
+)
+
+// upstreamProxyModule is the embedded proxy whose version is reported alongside the build
+const upstreamProxyModule = "github.com/authzed/spicedb-kubeapi-proxy"
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"gitCommit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+	// ProxyVersion is the spicedb-kubeapi-proxy module version compiled into the binary
+	ProxyVersion string `json:"proxyVersion"`
+}
+
+// Get returns the build information of the running binary
+func Get() Info {
+	return Info{
+		Version:      Version,
+		GitCommit:    GitCommit,
+		BuildDate:    BuildDate,
+		GoVersion:    runtime.Version(),
+		ProxyVersion: moduleVersion(upstreamProxyModule),
+	}
+}
+
+// moduleVersion returns the version of a dependency from the embedded build info,
+// following replace directives, or "unknown" when it is not available
+func moduleVersion(path string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != path {
+			continue
+		}
+		if dep.Replace != nil && dep.Replace.Version != "" {
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+	return "unknown"
+}
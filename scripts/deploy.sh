@@ -78,6 +78,9 @@ oc create namespace $NAMESPACE --dry-run=client -o yaml | oc apply -f -
 log_info "Applying RBAC configuration..."
 oc apply -f deployment/rbac.yaml
 
+log_info "Installing TestResource CRD..."
+oc apply -f deployment/testresource-crd.yaml
+
 log_info "Deploying application..."
 oc apply -f deployment/deployment.yaml
 